@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/kingledion/go-tools/tree"
+)
+
+// orders maps the names of the -order flag to traversals.
+var orders = map[string]tree.TraversalType{
+	"bfs": tree.TraverseBreadthFirst,
+	"dfs": tree.TraverseDepthFirst,
+}
+
+// convert reads a tree in one format and writes it in another, as set by its
+// flags.
+func convert[K comparable](args []string, stdin io.Reader, stdout io.Writer, parse func(string) (K, error)) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	from := fs.String("from", "jsonl", "")
+	to := fs.String("to", "jsonl", "")
+	orderName := fs.String("order", "bfs", "")
+	selection := fs.String("select", "", "")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: convert: %v", errUsage, err)
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("%w: wrong number of arguments to convert", errUsage)
+	}
+
+	order, ok := orders[*orderName]
+	if !ok {
+		return fmt.Errorf("%w: unknown order %q", errUsage, *orderName)
+	}
+	var sel *selector
+	if *selection != "" {
+		var err error
+		if sel, err = parseSelector(*selection); err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+	}
+
+	var write func(*tree.Tree[K, json.RawMessage], tree.TraversalType, io.Writer) error
+	switch *to {
+	case "jsonl":
+		write = writeJSONL[K]
+	case "json":
+		write = writeNested[K]
+	case "csv":
+		write = writeCSV[K]
+	case "dot":
+		write = writeDOT[K]
+	case "gob":
+		write = writeGob[K]
+	default:
+		return fmt.Errorf("%w: unknown output format %q", errUsage, *to)
+	}
+
+	var read func([]byte, func(string) (K, error)) ([]record[K], error)
+	switch *from {
+	case "jsonl":
+		read = func(in []byte, _ func(string) (K, error)) ([]record[K], error) {
+			return readRecords[K](in)
+		}
+	case "json":
+		read = func(in []byte, _ func(string) (K, error)) ([]record[K], error) {
+			return readNested[K](in)
+		}
+	case "csv":
+		read = readCSV[K]
+	case "gob":
+		read = func(in []byte, _ func(string) (K, error)) ([]record[K], error) {
+			return readGob[K](in)
+		}
+	default:
+		return fmt.Errorf("%w: unknown input format %q", errUsage, *from)
+	}
+
+	in, err := readInput(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	recs, err := read(in, parse)
+	if err != nil {
+		return err
+	}
+	if sel != nil {
+		for i := range recs {
+			if recs[i].Data, err = sel.apply(recs[i].Data); err != nil {
+				return fmt.Errorf("error selecting data of node %v: %w", recs[i].Primary, err)
+			}
+		}
+	}
+
+	t, err := build(recs)
+	if err != nil {
+		return err
+	}
+	return write(t, order, stdout)
+}
+
+// build links nodes given in any order into a tree, with tree.Link.
+func build[K comparable](recs []record[K]) (*tree.Tree[K, json.RawMessage], error) {
+	t, err := tree.Link(links(recs))
+	if err != nil {
+		return nil, fmt.Errorf("%w; run validate for details", err)
+	}
+	return t, nil
+}
+
+// links returns nodes as the records of tree.Link.
+func links[K comparable](recs []record[K]) []tree.Record[K, json.RawMessage] {
+	links := make([]tree.Record[K, json.RawMessage], len(recs))
+	for i, r := range recs {
+		links[i] = tree.Record[K, json.RawMessage]{
+			ID:           r.Primary,
+			ParentID:     r.ParentID,
+			NoParent:     r.NoParent,
+			SiblingIndex: r.SiblingIndex,
+			Data:         r.Data,
+		}
+	}
+	return links
+}
+
+// records returns the nodes of a tree in the given order, with their position
+// among their siblings.
+func records[K comparable](t *tree.Tree[K, json.RawMessage], order tree.TraversalType) []record[K] {
+	var recs []record[K]
+	// a node is visited after its parent, which records its position
+	positions := map[K]int{}
+	for n := range t.Traverse(order) {
+		r := record[K]{Primary: n.GetID(), ParentID: n.GetParentID(), NoParent: !n.HasParentID(), Data: n.GetData()}
+		r.SiblingIndex = positions[n.GetID()]
+		delete(positions, n.GetID())
+		for i, c := range n.GetChildren() {
+			positions[c.GetID()] = i
+		}
+		recs = append(recs, r)
+	}
+	return recs
+}
+
+// writeJSONL writes a tree in the format of Tree.Serialize.
+func writeJSONL[K comparable](t *tree.Tree[K, json.RawMessage], order tree.TraversalType, out io.Writer) error {
+	stream, errs := t.Serialize(order)
+	defer stream.Close()
+	if _, err := io.Copy(out, stream); err != nil {
+		return err
+	}
+	return <-errs
+}
+
+// nested is a node of a tree in the json format, holding its children.
+type nested[K comparable] struct {
+	ID K `json:"id"`
+	// Parent is only set on the root, if it has a parent key
+	Parent   *K              `json:"parent,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Children []*nested[K]    `json:"children,omitempty"`
+}
+
+// writeNested writes a tree as a single nested JSON object, or null if the
+// tree is empty. The order has no effect.
+func writeNested[K comparable](t *tree.Tree[K, json.RawMessage], _ tree.TraversalType, out io.Writer) error {
+	var nest func(n tree.Node[K, json.RawMessage]) *nested[K]
+	nest = func(n tree.Node[K, json.RawMessage]) *nested[K] {
+		v := &nested[K]{ID: n.GetID(), Data: n.GetData()}
+		for _, c := range n.GetChildren() {
+			v.Children = append(v.Children, nest(c))
+		}
+		return v
+	}
+
+	var root *nested[K]
+	if r := t.Root(); r != nil {
+		root = nest(r)
+		if r.HasParentID() {
+			p := r.GetParentID()
+			root.Parent = &p
+		}
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(root)
+}
+
+// readNested reads a tree written by writeNested.
+func readNested[K comparable](in []byte) ([]record[K], error) {
+	var root *nested[K]
+	if err := json.Unmarshal(in, &root); err != nil {
+		return nil, fmt.Errorf("error reading json: %w", err)
+	}
+	if root == nil {
+		return nil, nil
+	}
+
+	var recs []record[K]
+	var flatten func(n *nested[K], parent K, index int)
+	flatten = func(n *nested[K], parent K, index int) {
+		recs = append(recs, record[K]{Primary: n.ID, ParentID: parent, SiblingIndex: index, Data: n.Data})
+		for i, c := range n.Children {
+			flatten(c, n.ID, i)
+		}
+	}
+	var parent K
+	if root.Parent != nil {
+		parent = *root.Parent
+	}
+	flatten(root, parent, 0)
+	recs[0].NoParent = root.Parent == nil
+	return recs, nil
+}
+
+// csvHeader is the header of the csv format.
+var csvHeader = []string{"id", "parent", "data"}
+
+// writeCSV writes a tree as an adjacency list, in the given order, with the
+// data of each node as JSON. The parent of a root without a parent key is
+// left empty.
+func writeCSV[K comparable](t *tree.Tree[K, json.RawMessage], order tree.TraversalType, out io.Writer) error {
+	w := csv.NewWriter(out)
+	w.Write(csvHeader)
+	for _, r := range records(t, order) {
+		var data []byte
+		if len(r.Data) > 0 && string(r.Data) != "null" {
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, r.Data); err != nil {
+				return err
+			}
+			data = compact.Bytes()
+		}
+		var parent string
+		if !r.NoParent {
+			parent = fmt.Sprint(r.ParentID)
+		}
+		w.Write([]string{fmt.Sprint(r.Primary), parent, string(data)})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// readCSV reads an adjacency list. The columns are found by the names in the
+// header, so they may be in any order and other columns are ignored; the data
+// column is optional. An empty parent marks a node without a parent key.
+func readCSV[K comparable](in []byte, parse func(string) (K, error)) ([]record[K], error) {
+	r := csv.NewReader(bytes.NewReader(in))
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading csv: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	id, ok := columns["id"]
+	if !ok {
+		return nil, errors.New("error reading csv: no id column")
+	}
+	parent, ok := columns["parent"]
+	if !ok {
+		return nil, errors.New("error reading csv: no parent column")
+	}
+	data, hasData := columns["data"]
+
+	var recs []record[K]
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return recs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading csv: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+
+		var rec record[K]
+		if rec.Primary, err = parse(row[id]); err != nil {
+			return nil, fmt.Errorf("error reading csv line %d: invalid id %q: %w", line, row[id], err)
+		}
+		if row[parent] == "" {
+			rec.NoParent = true
+		} else if rec.ParentID, err = parse(row[parent]); err != nil {
+			return nil, fmt.Errorf("error reading csv line %d: invalid parent %q: %w", line, row[parent], err)
+		}
+		if hasData && row[data] != "" {
+			rec.Data = json.RawMessage(row[data])
+			if !json.Valid(rec.Data) {
+				rec.Data, _ = json.Marshal(row[data])
+			}
+		}
+		recs = append(recs, rec)
+	}
+}
+
+// writeDOT writes a tree as a Graphviz digraph, declaring the nodes in the
+// given order.
+func writeDOT[K comparable](t *tree.Tree[K, json.RawMessage], order tree.TraversalType, out io.Writer) error {
+	fmt.Fprintln(out, "digraph tree {")
+	var edges []string
+	for n := range t.Traverse(order) {
+		id := strconv.Quote(fmt.Sprint(n.GetID()))
+		fmt.Fprintf(out, "  %s [label=%s];\n", id, strconv.Quote(describe(n)))
+		if p := n.GetParent(); p != nil {
+			edges = append(edges, fmt.Sprintf("  %s -> %s;\n", strconv.Quote(fmt.Sprint(p.GetID())), id))
+		}
+	}
+	for _, e := range edges {
+		fmt.Fprint(out, e)
+	}
+	_, err := fmt.Fprintln(out, "}")
+	return err
+}
+
+// writeGob writes the nodes of a tree as a gob stream, in the given order.
+func writeGob[K comparable](t *tree.Tree[K, json.RawMessage], order tree.TraversalType, out io.Writer) error {
+	encoder := gob.NewEncoder(out)
+	for _, r := range records(t, order) {
+		if err := encoder.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readGob reads the nodes written by writeGob.
+func readGob[K comparable](in []byte) ([]record[K], error) {
+	decoder := gob.NewDecoder(bytes.NewReader(in))
+	var recs []record[K]
+	for {
+		var r record[K]
+		err := decoder.Decode(&r)
+		if err == io.EOF {
+			return recs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading gob node %d: %w", len(recs)+1, err)
+		}
+		recs = append(recs, r)
+	}
+}
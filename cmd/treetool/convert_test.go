@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvert(t *testing.T) {
+
+	var tests = map[string]struct {
+		args []string
+		in   string
+		exp  string
+	}{
+		"jsonl depth first": {
+			args: []string{"-order", "dfs"},
+			in:   sample,
+			exp: `{"Primary":1,"ParentID":0,"SiblingIndex":0,"Data":"root"}
+{"Primary":2,"ParentID":1,"SiblingIndex":0,"Data":"a"}
+{"Primary":6,"ParentID":2,"SiblingIndex":0,"Data":"e"}
+{"Primary":3,"ParentID":1,"SiblingIndex":1,"Data":"b"}
+{"Primary":4,"ParentID":3,"SiblingIndex":0,"Data":"c"}
+{"Primary":5,"ParentID":3,"SiblingIndex":1,"Data":"d"}
+`,
+		},
+		"jsonl in any order": {
+			in: `{"Primary":5,"ParentID":3,"SiblingIndex":1,"Data":"d"}
+{"Primary":4,"ParentID":3,"SiblingIndex":0,"Data":"c"}
+{"Primary":3,"ParentID":1,"SiblingIndex":0,"Data":"b"}
+{"Primary":1,"ParentID":0,"SiblingIndex":0,"Data":"root"}
+`,
+			exp: `{"Primary":1,"ParentID":0,"SiblingIndex":0,"Data":"root"}
+{"Primary":3,"ParentID":1,"SiblingIndex":0,"Data":"b"}
+{"Primary":4,"ParentID":3,"SiblingIndex":0,"Data":"c"}
+{"Primary":5,"ParentID":3,"SiblingIndex":1,"Data":"d"}
+`,
+		},
+		"to json": {
+			args: []string{"-to", "json"},
+			in: `{"Primary":2,"ParentID":7,"Data":{"n":1}}
+{"Primary":3,"ParentID":2}
+`,
+			exp: `{
+  "id": 2,
+  "parent": 7,
+  "data": {
+    "n": 1
+  },
+  "children": [
+    {
+      "id": 3
+    }
+  ]
+}
+`,
+		},
+		"from json": {
+			args: []string{"-from", "json"},
+			in:   `{"id":2,"parent":7,"children":[{"id":3,"data":"b"},{"id":4,"children":[{"id":5}]}]}`,
+			exp: `{"Primary":2,"ParentID":7,"SiblingIndex":0,"Data":null}
+{"Primary":3,"ParentID":2,"SiblingIndex":0,"Data":"b"}
+{"Primary":4,"ParentID":2,"SiblingIndex":1,"Data":null}
+{"Primary":5,"ParentID":4,"SiblingIndex":0,"Data":null}
+`,
+		},
+		"empty json": {
+			args: []string{"-to", "json"},
+			exp:  "null\n",
+		},
+		"to csv": {
+			args: []string{"-to", "csv", "-order", "dfs"},
+			in:   sample + `{"Primary":7,"ParentID":6,"Data":{"a":[1, 2]}}`,
+			exp: `id,parent,data
+1,0,"""root"""
+2,1,"""a"""
+6,2,"""e"""
+7,6,"{""a"":[1,2]}"
+3,1,"""b"""
+4,3,"""c"""
+5,3,"""d"""
+`,
+		},
+		"from csv": {
+			args: []string{"-from", "csv"},
+			in: `name,parent,id,data
+c,3,4,"{""x"": 1}"
+b,1,3,plain
+a,0,1,
+`,
+			exp: `{"Primary":1,"ParentID":0,"SiblingIndex":0,"Data":null}
+{"Primary":3,"ParentID":1,"SiblingIndex":0,"Data":"plain"}
+{"Primary":4,"ParentID":3,"SiblingIndex":0,"Data":{"x":1}}
+`,
+		},
+		"to dot": {
+			args: []string{"-to", "dot"},
+			in:   sample,
+			exp: `digraph tree {
+  "1" [label="1 \"root\""];
+  "2" [label="2 \"a\""];
+  "3" [label="3 \"b\""];
+  "6" [label="6 \"e\""];
+  "4" [label="4 \"c\""];
+  "5" [label="5 \"d\""];
+  "1" -> "2";
+  "1" -> "3";
+  "2" -> "6";
+  "3" -> "4";
+  "3" -> "5";
+}
+`,
+		},
+		"no parent": {
+			in: `{"Primary":1,"ParentID":0,"Data":"a"}
+{"Primary":0,"ParentID":null,"Data":"root"}
+`,
+			exp: `{"Primary":0,"ParentID":null,"SiblingIndex":0,"Data":"root"}
+{"Primary":1,"ParentID":0,"SiblingIndex":0,"Data":"a"}
+`,
+		},
+		"no parent to csv": {
+			args: []string{"-to", "csv"},
+			in:   `{"Primary":0,"ParentID":null}` + "\n" + `{"Primary":1,"ParentID":0}`,
+			exp:  "id,parent,data\n0,,\n1,0,\n",
+		},
+		"no parent from csv": {
+			args: []string{"-from", "csv"},
+			in:   "id,parent\n1,0\n0,\n",
+			exp: `{"Primary":0,"ParentID":null,"SiblingIndex":0,"Data":null}
+{"Primary":1,"ParentID":0,"SiblingIndex":0,"Data":null}
+`,
+		},
+		"no parent from json": {
+			args: []string{"-from", "json"},
+			in:   `{"id":0,"children":[{"id":1}]}`,
+			exp: `{"Primary":0,"ParentID":null,"SiblingIndex":0,"Data":null}
+{"Primary":1,"ParentID":0,"SiblingIndex":0,"Data":null}
+`,
+		},
+		"select": {
+			args: []string{"-select", "name=$.meta.name,size"},
+			in: `{"Primary":1,"ParentID":0,"Data":{"meta":{"name":"r"},"size":2,"other":0}}
+{"Primary":2,"ParentID":1,"Data":{"size":1}}
+`,
+			exp: `{"Primary":1,"ParentID":0,"SiblingIndex":0,"Data":{"name":"r","size":2}}
+{"Primary":2,"ParentID":1,"SiblingIndex":0,"Data":{"size":1}}
+`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			status, out, errOut := runTool(tt.in, append([]string{"-key", "int", "convert"}, tt.args...)...)
+			assert.Equal(t, 0, status, errOut)
+			assert.Equal(t, tt.exp, out)
+		})
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+
+	for _, format := range []string{"jsonl", "json", "csv", "gob"} {
+		t.Run(format, func(t *testing.T) {
+			var encoded, decoded bytes.Buffer
+			status := run([]string{"-key", "uint", "convert", "-to", format, "-order", "dfs"},
+				strings.NewReader(sample), &encoded, &decoded)
+			assert.Equal(t, 0, status)
+
+			decoded.Reset()
+			status = run([]string{"-key", "uint", "convert", "-from", format},
+				&encoded, &decoded, &decoded)
+			assert.Equal(t, 0, status)
+			assert.Equal(t, sample, decoded.String())
+		})
+	}
+}
+
+func TestConvertError(t *testing.T) {
+
+	var tests = map[string]struct {
+		args      []string
+		in        string
+		expStatus int
+		expErr    string
+	}{
+		"unknown input format": {
+			args:      []string{"-from", "dot"},
+			expStatus: 2,
+			expErr:    `unknown input format "dot"`,
+		},
+		"unknown output format": {
+			args:      []string{"-to", "yaml"},
+			expStatus: 2,
+			expErr:    `unknown output format "yaml"`,
+		},
+		"unknown order": {
+			args:      []string{"-order", "random"},
+			expStatus: 2,
+			expErr:    `unknown order "random"`,
+		},
+		"unknown flag": {
+			args:      []string{"-depth", "2"},
+			expStatus: 2,
+			expErr:    "convert: flag provided but not defined: -depth",
+		},
+		"invalid selector": {
+			args:      []string{"-select", "a,"},
+			expStatus: 2,
+			expErr:    `invalid selector "a,"`,
+		},
+		"extra argument": {
+			args:      []string{"a", "b"},
+			expStatus: 2,
+			expErr:    "wrong number of arguments to convert",
+		},
+		"duplicate": {
+			in:        sample + `{"Primary":3,"ParentID":1}`,
+			expStatus: 1,
+			expErr:    "duplicate primary key: 3",
+		},
+		"orphan": {
+			in:        sample + `{"Primary":7,"ParentID":9}`,
+			expStatus: 1,
+			expErr:    "multiple root nodes",
+		},
+		"cycle": {
+			in:        sample + `{"Primary":7,"ParentID":8}` + "\n" + `{"Primary":8,"ParentID":7}`,
+			expStatus: 1,
+			expErr:    "2 nodes unreachable from the root",
+		},
+		"csv without parent": {
+			args:      []string{"-from", "csv"},
+			in:        "id,data\n1,\n",
+			expStatus: 1,
+			expErr:    "no parent column",
+		},
+		"csv invalid key": {
+			args:      []string{"-from", "csv"},
+			in:        "id,parent\n1,0\nx,1\n",
+			expStatus: 1,
+			expErr:    `error reading csv line 3: invalid id "x"`,
+		},
+		"malformed gob": {
+			args:      []string{"-from", "gob"},
+			in:        "not gob",
+			expStatus: 1,
+			expErr:    "error reading gob node 1",
+		},
+		"malformed json": {
+			args:      []string{"-from", "json"},
+			in:        "[",
+			expStatus: 1,
+			expErr:    "error reading json",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			status, _, errOut := runTool(tt.in, append([]string{"-key", "int", "convert"}, tt.args...)...)
+			assert.Equal(t, tt.expStatus, status)
+			assert.Contains(t, errOut, tt.expErr)
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/kingledion/go-tools/tree"
+)
+
+// change is a difference between two trees in a single node.
+type change[K comparable] struct {
+	kind string // removed, added, moved or modified
+	id   K
+	// from and to are the node in the first and second tree; from is nil
+	// for an added node and to is nil for a removed one
+	from, to tree.Node[K, json.RawMessage]
+}
+
+// diff prints the differences between two trees, as set by its flags.
+func diff[K comparable](args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", "text", "")
+	color := fs.String("color", "auto", "")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: diff: %v", errUsage, err)
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("%w: wrong number of arguments to diff", errUsage)
+	}
+
+	var write func([]change[K], io.Writer) error
+	switch *format {
+	case "text":
+		switch *color {
+		case "auto":
+			write = writeText[K](isTerminal(stdout) && os.Getenv("NO_COLOR") == "")
+		case "always":
+			write = writeText[K](true)
+		case "never":
+			write = writeText[K](false)
+		default:
+			return fmt.Errorf("%w: unknown color setting %q", errUsage, *color)
+		}
+	case "patch":
+		write = writePatch[K]
+	default:
+		return fmt.Errorf("%w: unknown diff format %q", errUsage, *format)
+	}
+
+	trees, err := readTrees[K](fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	changes, err := compare(trees[0], trees[1])
+	if err != nil {
+		return err
+	}
+	if err := write(changes, stdout); err != nil {
+		return err
+	}
+	if len(changes) > 0 {
+		return errDiffer
+	}
+	return nil
+}
+
+// readTrees reads a tree from each of the named files.
+func readTrees[K comparable](names []string, stdin io.Reader) ([]*tree.Tree[K, json.RawMessage], error) {
+	ins, err := readInputs(names, stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	trees := make([]*tree.Tree[K, json.RawMessage], len(ins))
+	for i, in := range ins {
+		recs, err := readRecords[K](in)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", names[i], err)
+		}
+		if trees[i], err = build(recs); err != nil {
+			return nil, fmt.Errorf("%s: %w", names[i], err)
+		}
+	}
+	return trees, nil
+}
+
+// compare returns the changes that turn tree a into tree b: the nodes removed
+// from a in its breadth first order, then the nodes added to b, moved to
+// another parent in b and modified in b, each in the breadth first order of b.
+func compare[K comparable](a, b *tree.Tree[K, json.RawMessage]) ([]change[K], error) {
+	var removed, added, moved, modified []change[K]
+	for n := range a.Traverse(tree.TraverseBreadthFirst) {
+		if _, ok := b.Find(n.GetID()); !ok {
+			removed = append(removed, change[K]{kind: "removed", id: n.GetID(), from: n})
+		}
+	}
+
+	for n := range b.Traverse(tree.TraverseBreadthFirst) {
+		id := n.GetID()
+		old, ok := a.Find(id)
+		if !ok {
+			added = append(added, change[K]{kind: "added", id: id, to: n})
+			continue
+		}
+		if old.HasParentID() != n.HasParentID() || old.GetParentID() != n.GetParentID() {
+			moved = append(moved, change[K]{kind: "moved", id: id, from: old, to: n})
+		}
+		equal, err := jsonEqual(old.GetData(), n.GetData())
+		if err != nil {
+			return nil, fmt.Errorf("error comparing data of node %v: %w", id, err)
+		}
+		if !equal {
+			modified = append(modified, change[K]{kind: "modified", id: id, from: old, to: n})
+		}
+	}
+
+	changes := append(removed, added...)
+	changes = append(changes, moved...)
+	return append(changes, modified...), nil
+}
+
+// jsonEqual reports whether two JSON documents hold the same value. No data
+// is the same as null.
+func jsonEqual(a, b json.RawMessage) (bool, error) {
+	var va, vb any
+	for _, d := range []struct {
+		data json.RawMessage
+		v    *any
+	}{{a, &va}, {b, &vb}} {
+		if len(d.data) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(d.data))
+		decoder.UseNumber()
+		if err := decoder.Decode(d.v); err != nil {
+			return false, err
+		}
+	}
+	return reflect.DeepEqual(va, vb), nil
+}
+
+// compactData formats data on a single line, as null if there is none.
+func compactData(data json.RawMessage) string {
+	if len(data) == 0 {
+		return "null"
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return string(data)
+	}
+	return compact.String()
+}
+
+// colors are the ANSI escape sequences colouring each kind of change.
+var colors = map[string]string{
+	"removed":  "\x1b[31m",
+	"added":    "\x1b[32m",
+	"moved":    "\x1b[36m",
+	"modified": "\x1b[33m",
+}
+
+const colorReset = "\x1b[0m"
+
+// writeText returns a function writing changes one per line, coloured by
+// their kind if color is set.
+func writeText[K comparable](color bool) func([]change[K], io.Writer) error {
+	return func(changes []change[K], out io.Writer) error {
+		for _, c := range changes {
+			var detail string
+			switch c.kind {
+			case "removed":
+				detail = compactData(c.from.GetData())
+			case "added":
+				detail = fmt.Sprintf("%s (parent %s)", compactData(c.to.GetData()), parentText(c.to))
+			case "moved":
+				detail = fmt.Sprintf("parent %s -> %s", parentText(c.from), parentText(c.to))
+			case "modified":
+				detail = fmt.Sprintf("%s -> %s", compactData(c.from.GetData()), compactData(c.to.GetData()))
+			}
+
+			line := fmt.Sprintf("%-8s %v %s", c.kind, c.id, detail)
+			if color {
+				line = colors[c.kind] + line + colorReset
+			}
+			if _, err := fmt.Fprintln(out, line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// patchOp is an operation of a JSON Patch.
+type patchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// patchNode is the value of a node in the document a patch applies to.
+type patchNode[K comparable] struct {
+	Parent any             `json:"parent"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// writePatch writes changes as a JSON Patch applying to an object that holds
+// each node under its key.
+func writePatch[K comparable](changes []change[K], out io.Writer) error {
+	ops := []patchOp{}
+	for _, c := range changes {
+		path := "/" + escapePointer(fmt.Sprint(c.id))
+		switch c.kind {
+		case "removed":
+			ops = append(ops, patchOp{Op: "remove", Path: path})
+		case "added":
+			ops = append(ops, patchOp{Op: "add", Path: path, Value: patchNode[K]{
+				Parent: parentJSON(c.to),
+				Data:   c.to.GetData(),
+			}})
+		case "moved":
+			ops = append(ops, patchOp{Op: "replace", Path: path + "/parent", Value: parentJSON(c.to)})
+		case "modified":
+			ops = append(ops, patchOp{Op: "replace", Path: path + "/data", Value: c.to.GetData()})
+		}
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(ops)
+}
+
+// parentText formats the parent key of a node, as none if it has none.
+func parentText[K comparable](n tree.Node[K, json.RawMessage]) string {
+	if !n.HasParentID() {
+		return "none"
+	}
+	return fmt.Sprint(n.GetParentID())
+}
+
+// parentJSON returns the parent key of a node as a patch value, null if it
+// has none.
+func parentJSON[K comparable](n tree.Node[K, json.RawMessage]) any {
+	if !n.HasParentID() {
+		return json.RawMessage("null")
+	}
+	return n.GetParentID()
+}
+
+// escapePointer escapes a key as a token of a JSON Pointer (RFC 6901).
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
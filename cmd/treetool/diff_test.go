@@ -0,0 +1,218 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// changed is sample with node 4 removed, 7 added, 6 moved and 3 modified.
+const changed = `{"Primary":1,"ParentID":0,"Data":"root"}
+{"Primary":2,"ParentID":1,"Data":"a"}
+{"Primary":3,"ParentID":1,"Data":"B"}
+{"Primary":6,"ParentID":3,"Data":"e"}
+{"Primary":5,"ParentID":3,"Data":"d"}
+{"Primary":7,"ParentID":5,"Data":{"x":1}}
+`
+
+// writeFiles writes each of the given contents to a file in a temporary
+// directory, returning their names.
+func writeFiles(t *testing.T, contents ...string) []string {
+	dir := t.TempDir()
+	names := make([]string, len(contents))
+	for i, c := range contents {
+		names[i] = filepath.Join(dir, string(rune('a'+i))+".jsonl")
+		assert.NoError(t, os.WriteFile(names[i], []byte(c), 0o644))
+	}
+	return names
+}
+
+func TestDiff(t *testing.T) {
+
+	var tests = map[string]struct {
+		flags     []string
+		a, b      string
+		expStatus int
+		expOut    string
+	}{
+		"text": {
+			a:         sample,
+			b:         changed,
+			expStatus: 1,
+			expOut: `removed  4 "c"
+added    7 {"x":1} (parent 5)
+moved    6 parent 2 -> 3
+modified 3 "b" -> "B"
+`,
+		},
+		"color": {
+			flags:     []string{"-color", "always"},
+			a:         sample,
+			b:         changed,
+			expStatus: 1,
+			expOut: "\x1b[31mremoved  4 \"c\"\x1b[0m\n" +
+				"\x1b[32madded    7 {\"x\":1} (parent 5)\x1b[0m\n" +
+				"\x1b[36mmoved    6 parent 2 -> 3\x1b[0m\n" +
+				"\x1b[33mmodified 3 \"b\" -> \"B\"\x1b[0m\n",
+		},
+		"no color when not a terminal": {
+			flags:     []string{"-color", "auto"},
+			a:         sample,
+			b:         `{"Primary":1,"ParentID":0,"Data":"root"}`,
+			expStatus: 1,
+			expOut:    "removed  2 \"a\"\nremoved  3 \"b\"\nremoved  6 \"e\"\nremoved  4 \"c\"\nremoved  5 \"d\"\n",
+		},
+		"patch": {
+			flags:     []string{"-format", "patch"},
+			a:         sample,
+			b:         changed,
+			expStatus: 1,
+			expOut: `[
+  {
+    "op": "remove",
+    "path": "/4"
+  },
+  {
+    "op": "add",
+    "path": "/7",
+    "value": {
+      "parent": 5,
+      "data": {
+        "x": 1
+      }
+    }
+  },
+  {
+    "op": "replace",
+    "path": "/6/parent",
+    "value": 3
+  },
+  {
+    "op": "replace",
+    "path": "/3/data",
+    "value": "B"
+  }
+]
+`,
+		},
+		"no parent": {
+			a:         `{"Primary":1,"ParentID":0}`,
+			b:         `{"Primary":1,"ParentID":null}`,
+			expStatus: 1,
+			expOut:    "moved    1 parent 0 -> none\n",
+		},
+		"no parent patch": {
+			flags:     []string{"-format", "patch"},
+			a:         `{"Primary":1,"ParentID":0}`,
+			b:         `{"Primary":1,"ParentID":null}`,
+			expStatus: 1,
+			expOut: `[
+  {
+    "op": "replace",
+    "path": "/1/parent",
+    "value": null
+  }
+]
+`,
+		},
+		"same trees": {
+			a: sample,
+			b: sample,
+		},
+		"same patch": {
+			flags:  []string{"-format", "patch"},
+			a:      sample,
+			b:      sample,
+			expOut: "[]\n",
+		},
+		"same data": {
+			a: `{"Primary":1,"ParentID":0,"Data":{"a":1,"b":[1,2]}}` + "\n" + `{"Primary":2,"ParentID":1}`,
+			b: `{"Primary":2,"ParentID":1,"Data":null}` + "\n" + `{"Primary":1,"ParentID":0,"Data":{ "b":[1, 2], "a":1 }}`,
+		},
+		"empty": {
+			a:         "",
+			b:         `{"Primary":1,"ParentID":0}`,
+			expStatus: 1,
+			expOut:    "added    1 null (parent 0)\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			status, out, errOut := runTool("", append(append([]string{"-key", "int", "diff"}, tt.flags...), writeFiles(t, tt.a, tt.b)...)...)
+			assert.Equal(t, tt.expStatus, status)
+			assert.Equal(t, tt.expOut, out)
+			assert.Empty(t, errOut)
+		})
+	}
+}
+
+func TestDiffStrings(t *testing.T) {
+
+	files := writeFiles(t,
+		`{"Primary":"a/b","ParentID":"","Data":1}`,
+		`{"Primary":"a/b","ParentID":"","Data":2}`+"\n"+`{"Primary":"c~","ParentID":"a/b"}`,
+	)
+	status, out, _ := runTool("", "diff", "-format", "patch", files[0], files[1])
+	assert.Equal(t, 1, status)
+	assert.Contains(t, out, `"path": "/c~0"`)
+	assert.Contains(t, out, `"path": "/a~1b/data"`)
+}
+
+func TestDiffError(t *testing.T) {
+
+	files := writeFiles(t, sample, sample+`{"Primary":3,"ParentID":1}`)
+
+	var tests = map[string]struct {
+		args      []string
+		stdin     string
+		expStatus int
+		expErr    string
+	}{
+		"stdin": {
+			args:   []string{"-", files[0]},
+			stdin:  changed,
+			expErr: "",
+		},
+		"stdin twice": {
+			args:      []string{"-", "-"},
+			expStatus: 2,
+			expErr:    "standard input given more than once",
+		},
+		"one file": {
+			args:      []string{files[0]},
+			expStatus: 2,
+			expErr:    "wrong number of arguments to diff",
+		},
+		"unknown format": {
+			args:      []string{"-format", "html", files[0], files[0]},
+			expStatus: 2,
+			expErr:    `unknown diff format "html"`,
+		},
+		"unknown color": {
+			args:      []string{"-color", "sometimes", files[0], files[0]},
+			expStatus: 2,
+			expErr:    `unknown color setting "sometimes"`,
+		},
+		"invalid tree": {
+			args:      []string{files[0], files[1]},
+			expStatus: 1,
+			expErr:    files[1] + ": tree: duplicate primary key: 3",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			status, _, errOut := runTool(tt.stdin, append([]string{"-key", "int", "diff"}, tt.args...)...)
+			if tt.expErr == "" {
+				assert.Equal(t, 1, status)
+				assert.Empty(t, errOut)
+				return
+			}
+			assert.Equal(t, tt.expStatus, status)
+			assert.Contains(t, errOut, tt.expErr)
+		})
+	}
+}
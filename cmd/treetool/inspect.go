@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kingledion/go-tools/tree"
+)
+
+// record is a node as serialized by Tree.Serialize, with its data kept as
+// raw JSON.
+type record[K comparable] struct {
+	Primary  K
+	ParentID K
+	// NoParent is set on a root without a parent key, serialized as a null
+	// ParentID
+	NoParent     bool `json:"-"`
+	SiblingIndex int
+	Data         json.RawMessage
+}
+
+// UnmarshalJSON decodes a serialized node, setting NoParent if its ParentID
+// is null or missing.
+func (r *record[K]) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Primary      K
+		ParentID     *K
+		SiblingIndex int
+		Data         json.RawMessage
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*r = record[K]{Primary: v.Primary, SiblingIndex: v.SiblingIndex, Data: v.Data}
+	if v.ParentID == nil {
+		r.NoParent = true
+	} else {
+		r.ParentID = *v.ParentID
+	}
+	return nil
+}
+
+// readRecords decodes the serialized nodes of a tree without linking them.
+func readRecords[K comparable](in []byte) ([]record[K], error) {
+	decoder := json.NewDecoder(bytes.NewReader(in))
+	var recs []record[K]
+	for {
+		var r record[K]
+		err := decoder.Decode(&r)
+		if err == io.EOF {
+			return recs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading node %d: %w", len(recs)+1, err)
+		}
+		recs = append(recs, r)
+	}
+}
+
+// load deserializes a tree, keeping the data of its nodes as raw JSON. The
+// nodes may be in any order, as for validate; a tree that validate would
+// report problems in fails to load, instead of losing nodes.
+func load[K comparable](in []byte) (*tree.Tree[K, json.RawMessage], error) {
+	recs, err := readRecords[K](in)
+	if err != nil {
+		return nil, err
+	}
+	return build(recs)
+}
+
+// describe formats a node as its key followed by its data, if it has any.
+func describe[K comparable](n tree.Node[K, json.RawMessage]) string {
+	data := n.GetData()
+	if len(data) == 0 || string(data) == "null" {
+		return fmt.Sprint(n.GetID())
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return fmt.Sprintf("%v %s", n.GetID(), data)
+	}
+	return fmt.Sprintf("%v %s", n.GetID(), compact.Bytes())
+}
+
+// depths returns the depth of each node of a tree, with the root at depth
+// zero, and the nodes in depth first order.
+func depths[K comparable](t *tree.Tree[K, json.RawMessage]) (map[K]int, []tree.Node[K, json.RawMessage]) {
+	depth := map[K]int{}
+	var order []tree.Node[K, json.RawMessage]
+	for n := range t.Traverse(tree.TraverseDepthFirst) {
+		if p := n.GetParent(); p != nil {
+			depth[n.GetID()] = depth[p.GetID()] + 1
+		}
+		order = append(order, n)
+	}
+	return depth, order
+}
+
+// printTree prints each node of a tree on a line, indented by its depth.
+func printTree[K comparable](in []byte, out io.Writer) error {
+	t, err := load[K](in)
+	if err != nil {
+		return err
+	}
+
+	depth, order := depths(t)
+	for _, n := range order {
+		fmt.Fprintf(out, "%s%s\n", strings.Repeat("  ", depth[n.GetID()]), describe(n))
+	}
+	return nil
+}
+
+// stats prints the number of nodes and leaves of a tree, its depth and the
+// number of nodes with each number of children.
+func stats[K comparable](in []byte, out io.Writer) error {
+	t, err := load[K](in)
+	if err != nil {
+		return err
+	}
+
+	depth, order := depths(t)
+	maxDepth := 0
+	fanout := map[int]int{}
+	for _, n := range order {
+		maxDepth = max(maxDepth, depth[n.GetID()])
+		fanout[len(n.GetChildren())]++
+	}
+
+	fmt.Fprintf(out, "nodes:  %d\n", len(order))
+	fmt.Fprintf(out, "leaves: %d\n", fanout[0])
+	fmt.Fprintf(out, "depth:  %d\n", maxDepth)
+	if len(order) == 0 {
+		return nil
+	}
+
+	children := make([]int, 0, len(fanout))
+	for c := range fanout {
+		children = append(children, c)
+	}
+	sort.Ints(children)
+	fmt.Fprintln(out, "fan-out:")
+	for _, c := range children {
+		fmt.Fprintf(out, "  %d: %d\n", c, fanout[c])
+	}
+	return nil
+}
+
+// validate reports the nodes of a serialized tree that are lost when it is
+// deserialized: nodes whose key is repeated, orphans whose parent is missing,
+// and nodes in cycles. The root is the first node that is a root by
+// tree.Record.IsRoot, as for build.
+func validate[K comparable](in []byte, out io.Writer) error {
+	recs, err := readRecords[K](in)
+	if err != nil {
+		return err
+	}
+
+	problems := 0
+	report := func(format string, args ...any) {
+		fmt.Fprintf(out, format+"\n", args...)
+		problems++
+	}
+
+	// the first record of each key is the one that is kept
+	count := map[K]int{}
+	var keys []K
+	parent := map[K]K{}
+	noParent := map[K]bool{}
+	first := map[K]tree.Record[K, json.RawMessage]{}
+	for _, r := range links(recs) {
+		if count[r.ID] == 0 {
+			keys = append(keys, r.ID)
+			parent[r.ID] = r.ParentID
+			noParent[r.ID] = r.NoParent
+			first[r.ID] = r
+		}
+		count[r.ID]++
+	}
+	for _, k := range keys {
+		if count[k] > 1 {
+			report("duplicate: %v appears %d times", k, count[k])
+		}
+	}
+
+	var root K
+	hasRoot := false
+	orphans := map[K]bool{}
+	children := map[K][]K{}
+	has := func(k K) bool {
+		_, ok := parent[k]
+		return ok
+	}
+	for _, k := range keys {
+		p := parent[k]
+		if !first[k].IsRoot(has) {
+			children[p] = append(children[p], k)
+			continue
+		}
+		if !hasRoot {
+			root, hasRoot = k, true
+			continue
+		}
+		if noParent[k] {
+			report("orphan: %v has no parent", k)
+		} else {
+			report("orphan: %v has missing parent %v", k, p)
+		}
+		orphans[k] = true
+	}
+	if !hasRoot && len(keys) > 0 {
+		report("no root: every node has a parent")
+	}
+
+	// nodes that cannot be reached from the root or an orphan are in, or
+	// below, a cycle
+	reached := map[K]bool{}
+	pending := []K{}
+	if hasRoot {
+		pending = append(pending, root)
+	}
+	for k := range orphans {
+		pending = append(pending, k)
+	}
+	for len(pending) > 0 {
+		k := pending[0]
+		pending = pending[1:]
+		reached[k] = true
+		pending = append(pending, children[k]...)
+	}
+
+	for _, k := range keys {
+		if reached[k] {
+			continue
+		}
+		// follow the parents until a node repeats; it is on the cycle
+		onPath := map[K]bool{}
+		c := k
+		for !onPath[c] && !reached[c] {
+			onPath[c] = true
+			c = parent[c]
+		}
+		if reached[c] {
+			continue
+		}
+
+		cycle := []string{fmt.Sprint(c)}
+		reached[c] = true
+		for p := parent[c]; p != c; p = parent[p] {
+			cycle = append(cycle, fmt.Sprint(p))
+			reached[p] = true
+		}
+		cycle = append(cycle, fmt.Sprint(c))
+		report("cycle: %s", strings.Join(cycle, " -> "))
+
+		// the nodes below the cycle are lost with it, but are not reported
+		// separately
+		for b := k; !reached[b]; b = parent[b] {
+			reached[b] = true
+		}
+	}
+
+	if problems > 0 {
+		fmt.Fprintf(out, "%d problems in %d nodes\n", problems, len(recs))
+		return errInvalid
+	}
+	fmt.Fprintf(out, "ok: %d nodes\n", len(recs))
+	return nil
+}
+
+// find prints a node and its ancestors, from its parent up to the root.
+func find[K comparable](in []byte, id K, out io.Writer) error {
+	t, err := load[K](in)
+	if err != nil {
+		return err
+	}
+
+	parents, ok := t.FindParents(id)
+	if !ok {
+		return fmt.Errorf("node %v not found", id)
+	}
+	n, _ := t.Find(id)
+
+	fmt.Fprintln(out, describe(n))
+	for i, p := range parents {
+		fmt.Fprintf(out, "%s^ %s\n", strings.Repeat("  ", i), describe(p))
+	}
+	return nil
+}
+
+// subtree writes the subtree below a node, including the node itself, in the
+// serialized form of a tree.
+func subtree[K comparable](in []byte, id K, out io.Writer) error {
+	t, err := load[K](in)
+	if err != nil {
+		return err
+	}
+
+	sub, ok := t.Remove(id)
+	if !ok {
+		return fmt.Errorf("node %v not found", id)
+	}
+
+	return writeJSONL(sub, tree.TraverseBreadthFirst, out)
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrint(t *testing.T) {
+
+	var tests = map[string]struct {
+		in  string
+		exp string
+	}{
+		"tree": {
+			in:  sample,
+			exp: "1 \"root\"\n  2 \"a\"\n    6 \"e\"\n  3 \"b\"\n    4 \"c\"\n    5 \"d\"\n",
+		},
+		"compacts data": {
+			in:  `{"Primary":1,"ParentID":0,"Data":{ "name": "root",  "size": 3 }}`,
+			exp: "1 {\"name\":\"root\",\"size\":3}\n",
+		},
+		"no data": {
+			in:  `{"Primary":1,"ParentID":0}` + "\n" + `{"Primary":2,"ParentID":1,"Data":null}`,
+			exp: "1\n  2\n",
+		},
+		"out of order": {
+			in:  `{"Primary":2,"ParentID":1}` + "\n" + `{"Primary":3,"ParentID":2}` + "\n" + `{"Primary":1,"ParentID":0}`,
+			exp: "1\n  2\n    3\n",
+		},
+		"empty": {
+			in:  "",
+			exp: "",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.NoError(t, printTree[int64]([]byte(tt.in), &out))
+			assert.Equal(t, tt.exp, out.String())
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+
+	var tests = map[string]struct {
+		in  string
+		exp string
+	}{
+		"tree": {
+			in:  sample,
+			exp: "nodes:  6\nleaves: 3\ndepth:  2\nfan-out:\n  0: 3\n  1: 1\n  2: 2\n",
+		},
+		"single node": {
+			in:  `{"Primary":1,"ParentID":0}`,
+			exp: "nodes:  1\nleaves: 1\ndepth:  0\nfan-out:\n  0: 1\n",
+		},
+		"out of order": {
+			in:  `{"Primary":3,"ParentID":1}` + "\n" + `{"Primary":2,"ParentID":1}` + "\n" + `{"Primary":1,"ParentID":0}`,
+			exp: "nodes:  3\nleaves: 2\ndepth:  1\nfan-out:\n  0: 2\n  2: 1\n",
+		},
+		"empty": {
+			in:  "",
+			exp: "nodes:  0\nleaves: 0\ndepth:  0\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.NoError(t, stats[uint64]([]byte(tt.in), &out))
+			assert.Equal(t, tt.exp, out.String())
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	var tests = map[string]struct {
+		in       string
+		exp      string
+		expValid bool
+	}{
+		"valid": {
+			in:       sample,
+			exp:      "ok: 6 nodes\n",
+			expValid: true,
+		},
+		"root is its own parent": {
+			in:       `{"Primary":1,"ParentID":1}` + "\n" + `{"Primary":2,"ParentID":1}`,
+			exp:      "ok: 2 nodes\n",
+			expValid: true,
+		},
+		"root without parent": {
+			in:       `{"Primary":1,"ParentID":0}` + "\n" + `{"Primary":0,"ParentID":null}`,
+			exp:      "ok: 2 nodes\n",
+			expValid: true,
+		},
+		"two roots without parents": {
+			in:  `{"Primary":1,"ParentID":null}` + "\n" + `{"Primary":2,"ParentID":null}`,
+			exp: "orphan: 2 has no parent\n1 problems in 2 nodes\n",
+		},
+		"empty": {
+			in:       "",
+			exp:      "ok: 0 nodes\n",
+			expValid: true,
+		},
+		"duplicate": {
+			in:  sample + `{"Primary":4,"ParentID":2}`,
+			exp: "duplicate: 4 appears 2 times\n1 problems in 7 nodes\n",
+		},
+		"orphan": {
+			in:  sample + `{"Primary":7,"ParentID":9}`,
+			exp: "orphan: 7 has missing parent 9\n1 problems in 7 nodes\n",
+		},
+		"cycle": {
+			in: sample + `{"Primary":7,"ParentID":8}` + "\n" + `{"Primary":8,"ParentID":7}` + "\n" +
+				`{"Primary":9,"ParentID":8}`,
+			exp: "cycle: 7 -> 8 -> 7\n1 problems in 9 nodes\n",
+		},
+		"no root": {
+			in:  `{"Primary":1,"ParentID":2}` + "\n" + `{"Primary":2,"ParentID":1}`,
+			exp: "no root: every node has a parent\ncycle: 1 -> 2 -> 1\n2 problems in 2 nodes\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := validate[int]([]byte(tt.in), &out)
+			if tt.expValid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, errInvalid)
+			}
+			assert.Equal(t, tt.exp, out.String())
+		})
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+
+	var tests = map[string]struct {
+		in     string
+		expErr string
+	}{
+		"duplicate": {
+			in:     sample + `{"Primary":4,"ParentID":1}`,
+			expErr: "duplicate primary key: 4",
+		},
+		"orphan": {
+			in:     sample + `{"Primary":7,"ParentID":9}`,
+			expErr: "multiple root nodes",
+		},
+		"cycle": {
+			in:     sample + `{"Primary":7,"ParentID":8}` + "\n" + `{"Primary":8,"ParentID":7}`,
+			expErr: "2 nodes unreachable from the root",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			in := []byte(tt.in)
+			var out bytes.Buffer
+			for _, err := range []error{
+				printTree[int](in, &out),
+				stats[int](in, &out),
+				find(in, 1, &out),
+				subtree(in, 1, &out),
+			} {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expErr)
+			}
+			assert.Empty(t, out.String())
+		})
+	}
+}
+
+func TestValidateMalformed(t *testing.T) {
+
+	var out bytes.Buffer
+	err := validate[int]([]byte(sample+"{"), &out)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error reading node 7")
+}
+
+func TestFind(t *testing.T) {
+
+	var tests = map[string]struct {
+		id    int
+		exp   string
+		expOK bool
+	}{
+		"root": {
+			id:    1,
+			exp:   "1 \"root\"\n",
+			expOK: true,
+		},
+		"leaf": {
+			id:    4,
+			exp:   "4 \"c\"\n^ 3 \"b\"\n  ^ 1 \"root\"\n",
+			expOK: true,
+		},
+		"missing": {
+			id: 7,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := find([]byte(sample), tt.id, &out)
+			if tt.expOK {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, "node 7 not found")
+			}
+			assert.Equal(t, tt.exp, out.String())
+		})
+	}
+}
+
+func TestSubtree(t *testing.T) {
+
+	var tests = map[string]struct {
+		id    int
+		exp   string
+		expOK bool
+	}{
+		"inner node": {
+			id: 3,
+			exp: `{"Primary":3,"ParentID":1,"SiblingIndex":0,"Data":"b"}` + "\n" +
+				`{"Primary":4,"ParentID":3,"SiblingIndex":0,"Data":"c"}` + "\n" +
+				`{"Primary":5,"ParentID":3,"SiblingIndex":1,"Data":"d"}` + "\n",
+			expOK: true,
+		},
+		"leaf": {
+			id:    6,
+			exp:   `{"Primary":6,"ParentID":2,"SiblingIndex":0,"Data":"e"}` + "\n",
+			expOK: true,
+		},
+		"missing": {
+			id: 7,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := subtree([]byte(sample), tt.id, &out)
+			if tt.expOK {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, "node 7 not found")
+			}
+			assert.Equal(t, tt.exp, out.String())
+		})
+	}
+}
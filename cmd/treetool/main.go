@@ -0,0 +1,283 @@
+/*
+Command treetool inspects trees serialized with Tree.Serialize.
+
+Usage:
+
+	treetool [-key string|int|uint] <command> [arguments] [file]
+	treetool [-key string|int|uint] diff [flags] <file> <file>
+	treetool [-key string|int|uint] merge [-order bfs|dfs] <base> <graft>
+
+The commands are:
+
+	print         print the tree, one node per line indented by depth
+	stats         print the node count, depth and fan-out histogram
+	validate      report orphans, duplicate keys and cycles
+	find <id>     print a node and its chain of ancestors
+	subtree <id>  write the subtree below a node, serialized
+	convert       write the tree in another format
+	diff          print the differences between two trees
+	merge         merge a tree into another and write the result
+
+The tree is read from the file, or from standard input if the file is
+omitted or is "-". Node keys are decoded as the type given by -key, which is
+string by default. The data of the nodes is kept as raw JSON. The nodes may
+be in any order; every command but validate fails on a tree that validate
+reports problems in, rather than leave out the nodes it cannot place.
+
+Convert takes its own flags before the file:
+
+	-from format    format of the input: jsonl, json, csv or gob (default jsonl)
+	-to format      format of the output: jsonl, json, csv, dot or gob (default jsonl)
+	-order order    order of the nodes in the output: bfs or dfs (default bfs)
+	-select fields  project the data of each node onto the given fields
+
+The formats are:
+
+	jsonl  one node per line, as written by Tree.Serialize; a root without
+	       a parent key has a null "ParentID"
+	json   a single object nesting each node in its parent, with the
+	       fields "id", "data" and "children"; the root also has "parent"
+	       if it has a parent key
+	csv    an adjacency list with the columns "id", "parent" and "data",
+	       holding the data as JSON; data that is not valid JSON is read
+	       as a string, and an empty parent marks a root without a parent
+	       key
+	dot    a Graphviz digraph labelled with the key and data of each node
+	gob    a stream of gob-encoded nodes
+
+Nodes may be read in any order, and siblings keep the order in which they
+were read unless the input records their position. The tree must be valid;
+use validate to find why it is not.
+
+Diff compares two trees node by node, matching nodes by key, and prints the
+nodes that were removed, added, moved to another parent or modified, in that
+order. Its flags are:
+
+	-format format  text, or patch for a JSON Patch (RFC 6902) turning the
+	                first tree into the second (default text)
+	-color when     colour the text: auto, always or never (default auto,
+	                which colours it if the output is a terminal and NO_COLOR
+	                is not set)
+
+The patch applies to a tree written as an object holding each node under its
+key, as an object with the fields "parent" and "data". Data are compared as
+JSON values, so a change in formatting or in the order of the fields of an
+object is not a modification. The exit status of diff is 1 if the trees
+differ.
+
+Merge grafts the second tree onto the first with Tree.Merge, under the parent
+of its root, and writes the result in the jsonl format in the order given by
+-order. It fails if the root of the second tree has no parent key, if the
+parent is missing from the first tree or if the trees share keys, and reports
+which.
+
+A selector is a comma-separated list of fields, each a path into the data
+optionally preceded by a name and "=", such as
+
+	treetool convert -select 'name=$.meta.name,size,tag=$.tags[0]'
+
+Paths start at "$", which may be omitted, and are followed by ".field",
+"['field']" or "[index]" steps. The data becomes an object holding each field
+under its name, or the last field name of its path, which must then not end
+with an index; fields missing from the data are left out. A single path with
+no name replaces the data with its value, or with null if it is missing.
+
+The exit status is 1 if the command fails or validate finds a problem, and 2
+if the command line is invalid.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+const usage = `usage: treetool [-key string|int|uint] <command> [arguments] [file]
+       treetool [-key string|int|uint] diff [flags] <file> <file>
+       treetool [-key string|int|uint] merge [-order bfs|dfs] <base> <graft>
+
+commands:
+  print         print the tree, one node per line indented by depth
+  stats         print the node count, depth and fan-out histogram
+  validate      report orphans, duplicate keys and cycles
+  find <id>     print a node and its chain of ancestors
+  subtree <id>  write the subtree below a node, serialized
+  convert       write the tree in another format
+  diff          print the differences between two trees
+  merge         merge a tree into another and write the result
+
+convert flags:
+  -from format    format of the input: jsonl, json, csv or gob (default jsonl)
+  -to format      format of the output: jsonl, json, csv, dot or gob (default jsonl)
+  -order order    order of the nodes in the output: bfs or dfs (default bfs)
+  -select fields  project the data of each node, e.g. 'name=$.meta.name,size'
+
+diff flags:
+  -format format  text or patch (default text)
+  -color when     auto, always or never (default auto)
+
+merge flags:
+  -order order    order of the nodes in the output: bfs or dfs (default bfs)
+
+flags:
+`
+
+var (
+	// errUsage is returned for an invalid command line.
+	errUsage = errors.New("invalid command line")
+	// errInvalid is returned by validate once it has reported the problems
+	// of a tree.
+	errInvalid = errors.New("tree is invalid")
+	// errDiffer is returned by diff once it has printed the differences
+	// between two trees.
+	errDiffer = errors.New("trees differ")
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the tool with the given arguments and standard streams, returning
+// the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("treetool", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	key := fs.String("key", "string", "type of the node keys: string, int or uint")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var err error
+	switch *key {
+	case "string":
+		err = dispatch(fs.Args(), stdin, stdout, func(s string) (string, error) {
+			return s, nil
+		})
+	case "int":
+		err = dispatch(fs.Args(), stdin, stdout, func(s string) (int64, error) {
+			return strconv.ParseInt(s, 10, 64)
+		})
+	case "uint":
+		err = dispatch(fs.Args(), stdin, stdout, func(s string) (uint64, error) {
+			return strconv.ParseUint(s, 10, 64)
+		})
+	default:
+		err = fmt.Errorf("%w: unknown key type %q", errUsage, *key)
+	}
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "treetool: %v\n", err)
+		fs.Usage()
+		return 2
+	case errors.Is(err, errInvalid), errors.Is(err, errDiffer):
+		return 1
+	default:
+		fmt.Fprintf(stderr, "treetool: %v\n", err)
+		return 1
+	}
+}
+
+// commandArgs is the number of arguments of each command, not counting the
+// input file.
+var commandArgs = map[string]int{
+	"print":    0,
+	"stats":    0,
+	"validate": 0,
+	"find":     1,
+	"subtree":  1,
+}
+
+// dispatch runs a command on a tree with keys of type K, parsing the keys
+// given as arguments with parse.
+func dispatch[K comparable](args []string, stdin io.Reader, stdout io.Writer, parse func(string) (K, error)) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: no command", errUsage)
+	}
+	name, args := args[0], args[1:]
+
+	// these commands parse their own flags and arguments
+	switch name {
+	case "convert":
+		return convert(args, stdin, stdout, parse)
+	case "diff":
+		return diff[K](args, stdin, stdout)
+	case "merge":
+		return merge[K](args, stdin, stdout)
+	}
+
+	n, ok := commandArgs[name]
+	if !ok {
+		return fmt.Errorf("%w: unknown command %q", errUsage, name)
+	}
+	if len(args) < n || len(args) > n+1 {
+		return fmt.Errorf("%w: wrong number of arguments to %s", errUsage, name)
+	}
+
+	ids := make([]K, n)
+	for i, arg := range args[:n] {
+		id, err := parse(arg)
+		if err != nil {
+			return fmt.Errorf("%w: invalid key %q: %v", errUsage, arg, err)
+		}
+		ids[i] = id
+	}
+
+	in, err := readInput(args[n:], stdin)
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case "print":
+		return printTree[K](in, stdout)
+	case "stats":
+		return stats[K](in, stdout)
+	case "validate":
+		return validate[K](in, stdout)
+	case "find":
+		return find(in, ids[0], stdout)
+	default:
+		return subtree(in, ids[0], stdout)
+	}
+}
+
+// readInput reads the whole of the file named by the remaining arguments, or
+// of standard input if there is none or it is "-".
+func readInput(args []string, stdin io.Reader) ([]byte, error) {
+	if len(args) == 0 || args[0] == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(args[0])
+}
+
+// readInputs reads the whole of each named file, reading standard input for
+// the name "-", which may be given only once.
+func readInputs(names []string, stdin io.Reader) ([][]byte, error) {
+	ins := make([][]byte, len(names))
+	usedStdin := false
+	for i, name := range names {
+		if name == "-" {
+			if usedStdin {
+				return nil, fmt.Errorf("%w: standard input given more than once", errUsage)
+			}
+			usedStdin = true
+		}
+		in, err := readInput(names[i:i+1], stdin)
+		if err != nil {
+			return nil, err
+		}
+		ins[i] = in
+	}
+	return ins, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sample is a serialized tree with this shape:
+//
+//	1
+//	├── 2
+//	│   └── 6
+//	└── 3
+//	    ├── 4
+//	    └── 5
+const sample = `{"Primary":1,"ParentID":0,"SiblingIndex":0,"Data":"root"}
+{"Primary":2,"ParentID":1,"SiblingIndex":0,"Data":"a"}
+{"Primary":3,"ParentID":1,"SiblingIndex":1,"Data":"b"}
+{"Primary":6,"ParentID":2,"SiblingIndex":0,"Data":"e"}
+{"Primary":4,"ParentID":3,"SiblingIndex":0,"Data":"c"}
+{"Primary":5,"ParentID":3,"SiblingIndex":1,"Data":"d"}
+`
+
+// runTool runs the tool with the given arguments and standard input.
+func runTool(stdin string, args ...string) (status int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	status = run(args, strings.NewReader(stdin), &out, &errOut)
+	return status, out.String(), errOut.String()
+}
+
+func TestRun(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "tree.jsonl")
+	assert.NoError(t, os.WriteFile(file, []byte(sample), 0o644))
+
+	var tests = map[string]struct {
+		args      []string
+		stdin     string
+		expStatus int
+		expOut    string
+		expErr    string
+	}{
+		"file": {
+			args:   []string{"-key", "uint", "find", "6", file},
+			expOut: "6 \"e\"\n^ 2 \"a\"\n  ^ 1 \"root\"\n",
+		},
+		"stdin": {
+			args:   []string{"-key", "int", "find", "6"},
+			stdin:  sample,
+			expOut: "6 \"e\"\n^ 2 \"a\"\n  ^ 1 \"root\"\n",
+		},
+		"stdin dash": {
+			args:   []string{"-key", "int", "find", "6", "-"},
+			stdin:  sample,
+			expOut: "6 \"e\"\n^ 2 \"a\"\n  ^ 1 \"root\"\n",
+		},
+		"string keys": {
+			args:   []string{"find", "b"},
+			stdin:  `{"Primary":"r","ParentID":""}` + "\n" + `{"Primary":"b","ParentID":"r"}`,
+			expOut: "b\n^ r\n",
+		},
+		"missing file": {
+			args:      []string{"print", filepath.Join(t.TempDir(), "missing")},
+			expStatus: 1,
+			expErr:    "no such file",
+		},
+		"invalid tree": {
+			args:      []string{"-key", "int", "validate"},
+			stdin:     `{"Primary":1,"ParentID":0}` + "\n" + `{"Primary":1,"ParentID":0}`,
+			expStatus: 1,
+			expOut:    "duplicate: 1 appears 2 times",
+		},
+		"no command": {
+			expStatus: 2,
+			expErr:    "no command",
+		},
+		"unknown command": {
+			args:      []string{"grow"},
+			expStatus: 2,
+			expErr:    `unknown command "grow"`,
+		},
+		"unknown key type": {
+			args:      []string{"-key", "float", "print"},
+			expStatus: 2,
+			expErr:    `unknown key type "float"`,
+		},
+		"unknown flag": {
+			args:      []string{"-depth", "2", "print"},
+			expStatus: 2,
+			expErr:    "flag provided but not defined",
+		},
+		"missing argument": {
+			args:      []string{"find"},
+			expStatus: 2,
+			expErr:    "wrong number of arguments to find",
+		},
+		"extra argument": {
+			args:      []string{"print", "a", "b"},
+			expStatus: 2,
+			expErr:    "wrong number of arguments to print",
+		},
+		"invalid key": {
+			args:      []string{"-key", "uint", "find", "-1"},
+			expStatus: 2,
+			expErr:    `invalid key "-1"`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			status, out, errOut := runTool(tt.stdin, tt.args...)
+			assert.Equal(t, tt.expStatus, status)
+			assert.Contains(t, out, tt.expOut)
+			assert.Contains(t, errOut, tt.expErr)
+			if tt.expStatus == 2 {
+				assert.Contains(t, errOut, "usage: treetool")
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kingledion/go-tools/tree"
+)
+
+// merge grafts a tree onto another with Tree.Merge and writes the result, as
+// set by its flags.
+func merge[K comparable](args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	orderName := fs.String("order", "bfs", "")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: merge: %v", errUsage, err)
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("%w: wrong number of arguments to merge", errUsage)
+	}
+	order, ok := orders[*orderName]
+	if !ok {
+		return fmt.Errorf("%w: unknown order %q", errUsage, *orderName)
+	}
+
+	trees, err := readTrees[K](fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	base, graft := trees[0], trees[1]
+
+	// Merge only reports whether it succeeded, so check the reasons it fails
+	// first to report them
+	if root := graft.Root(); root != nil {
+		if !root.HasParentID() {
+			return fmt.Errorf("missing attach point: graft root %v has no parent", root.GetID())
+		}
+		attach := root.GetParentID()
+		if _, ok := base.Find(attach); !ok {
+			return fmt.Errorf("missing attach point: parent %v of graft root %v is not in the base tree", attach, root.GetID())
+		}
+
+		var duplicates []string
+		for n := range graft.Traverse(tree.TraverseBreadthFirst) {
+			if _, ok := base.Find(n.GetID()); ok {
+				duplicates = append(duplicates, fmt.Sprint(n.GetID()))
+			}
+		}
+		if len(duplicates) > 0 {
+			return fmt.Errorf("duplicate keys in the base and graft trees: %s", strings.Join(duplicates, ", "))
+		}
+
+		if !base.Merge(graft) {
+			return errors.New("merge failed")
+		}
+	}
+
+	return writeJSONL(base, order, stdout)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+
+	var tests = map[string]struct {
+		flags     []string
+		base      string
+		graft     string
+		expStatus int
+		expOut    string
+		expErr    string
+	}{
+		"merge": {
+			base:  sample,
+			graft: `{"Primary":8,"ParentID":7}` + "\n" + `{"Primary":7,"ParentID":2,"Data":"f"}`,
+			expOut: sample[:strings.Index(sample, `{"Primary":6`)] +
+				`{"Primary":6,"ParentID":2,"SiblingIndex":0,"Data":"e"}
+{"Primary":7,"ParentID":2,"SiblingIndex":1,"Data":"f"}
+{"Primary":4,"ParentID":3,"SiblingIndex":0,"Data":"c"}
+{"Primary":5,"ParentID":3,"SiblingIndex":1,"Data":"d"}
+{"Primary":8,"ParentID":7,"SiblingIndex":0,"Data":null}
+`,
+		},
+		"depth first": {
+			flags: []string{"-order", "dfs"},
+			base:  `{"Primary":1,"ParentID":0}` + "\n" + `{"Primary":2,"ParentID":1}`,
+			graft: `{"Primary":3,"ParentID":1}` + "\n" + `{"Primary":4,"ParentID":3}`,
+			expOut: `{"Primary":1,"ParentID":0,"SiblingIndex":0,"Data":null}
+{"Primary":2,"ParentID":1,"SiblingIndex":0,"Data":null}
+{"Primary":3,"ParentID":1,"SiblingIndex":1,"Data":null}
+{"Primary":4,"ParentID":3,"SiblingIndex":0,"Data":null}
+`,
+		},
+		"empty graft": {
+			base:   sample,
+			expOut: sample,
+		},
+		"missing attach point": {
+			base:      sample,
+			graft:     `{"Primary":7,"ParentID":9}`,
+			expStatus: 1,
+			expErr:    "missing attach point: parent 9 of graft root 7 is not in the base tree",
+		},
+		"graft without parent": {
+			base:      sample,
+			graft:     `{"Primary":7,"ParentID":null}`,
+			expStatus: 1,
+			expErr:    "missing attach point: graft root 7 has no parent",
+		},
+		"empty base": {
+			graft:     `{"Primary":7,"ParentID":9}`,
+			expStatus: 1,
+			expErr:    "missing attach point",
+		},
+		"duplicate keys": {
+			base:      sample,
+			graft:     `{"Primary":7,"ParentID":1}` + "\n" + `{"Primary":5,"ParentID":7}` + "\n" + `{"Primary":3,"ParentID":7}`,
+			expStatus: 1,
+			expErr:    "duplicate keys in the base and graft trees: 5, 3",
+		},
+		"invalid graft": {
+			base:      sample,
+			graft:     `{"Primary":7,"ParentID":1}` + "\n" + `{"Primary":8,"ParentID":9}`,
+			expStatus: 1,
+			expErr:    "multiple root nodes",
+		},
+		"unknown order": {
+			flags:     []string{"-order", "random"},
+			expStatus: 2,
+			expErr:    `unknown order "random"`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			args := append(append([]string{"-key", "int", "merge"}, tt.flags...), writeFiles(t, tt.base, tt.graft)...)
+			status, out, errOut := runTool("", args...)
+			assert.Equal(t, tt.expStatus, status)
+			assert.Equal(t, tt.expOut, out)
+			assert.Contains(t, errOut, tt.expErr)
+		})
+	}
+}
+
+func TestMergeArguments(t *testing.T) {
+
+	status, _, errOut := runTool(sample, "-key", "int", "merge", "-")
+	assert.Equal(t, 2, status)
+	assert.Contains(t, errOut, "wrong number of arguments to merge")
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// selector projects the data of a node onto a set of fields picked by paths,
+// as given to convert with -select.
+type selector struct {
+	fields []field
+	// value is set if the selector is a single path with no name, whose value
+	// replaces the data
+	value bool
+}
+
+// field is a value picked from the data of a node, and the name it is given
+// in the projection.
+type field struct {
+	name string
+	path []step
+}
+
+// step is a step of a path: the field of an object, or the element of an
+// array if the field is empty.
+type step struct {
+	field string
+	index int
+}
+
+// parseSelector parses a comma-separated list of paths, each optionally
+// preceded by a name and "=".
+func parseSelector(s string) (*selector, error) {
+	p := &selectorParser{s: s}
+	sel := &selector{}
+	for {
+		f, named, err := p.parseField()
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		if len(sel.fields) == 0 && !named && p.done() {
+			sel.value = true
+		} else if f.name == "" {
+			return nil, fmt.Errorf("invalid selector %q: path %s needs a name", s, formatPath(f.path))
+		}
+		sel.fields = append(sel.fields, f)
+
+		if p.done() {
+			return sel, nil
+		}
+		p.pos++ // the comma
+	}
+}
+
+// apply returns the projection of the given data.
+func (sel *selector) apply(data json.RawMessage) (json.RawMessage, error) {
+	var v any
+	if len(data) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&v); err != nil {
+			return nil, err
+		}
+	}
+
+	if sel.value {
+		got, ok := lookup(v, sel.fields[0].path)
+		if !ok {
+			return json.RawMessage("null"), nil
+		}
+		return json.Marshal(got)
+	}
+
+	// build the object by hand to keep the fields in order
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for _, f := range sel.fields {
+		got, ok := lookup(v, f.path)
+		if !ok {
+			continue
+		}
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(f.name)
+		value, err := json.Marshal(got)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// lookup follows a path into a value decoded from JSON.
+func lookup(v any, path []step) (any, bool) {
+	for _, s := range path {
+		switch c := v.(type) {
+		case map[string]any:
+			if s.field == "" {
+				return nil, false
+			}
+			var ok bool
+			if v, ok = c[s.field]; !ok {
+				return nil, false
+			}
+		case []any:
+			if s.field != "" || s.index >= len(c) {
+				return nil, false
+			}
+			v = c[s.index]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// formatPath formats a path as it is written in a selector.
+func formatPath(path []step) string {
+	var b strings.Builder
+	b.WriteByte('$')
+	for _, s := range path {
+		if s.field == "" {
+			fmt.Fprintf(&b, "[%d]", s.index)
+		} else {
+			fmt.Fprintf(&b, "[%q]", s.field)
+		}
+	}
+	return b.String()
+}
+
+type selectorParser struct {
+	s   string
+	pos int
+}
+
+func (p *selectorParser) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *selectorParser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// parseField parses a field up to the next comma or the end of the selector.
+// The name of a field with no explicit name is the last field of its path.
+func (p *selectorParser) parseField() (f field, named bool, err error) {
+	start := p.pos
+	if name := p.ident(); name != "" && p.peek() == '=' {
+		f.name = name
+		named = true
+		p.pos++
+	} else {
+		p.pos = start
+	}
+
+	if f.path, err = p.parsePath(); err != nil {
+		return f, named, err
+	}
+	if !named && len(f.path) > 0 {
+		f.name = f.path[len(f.path)-1].field
+	}
+	return f, named, nil
+}
+
+// parsePath parses a path, which starts with "$" or with a bare field name.
+func (p *selectorParser) parsePath() ([]step, error) {
+	var path []step
+	switch {
+	case p.peek() == '$':
+		p.pos++
+	case isIdentByte(p.peek()):
+		path = append(path, step{field: p.ident()})
+	default:
+		return nil, p.errorf("expected a path")
+	}
+
+	for !p.done() && p.peek() != ',' {
+		switch p.peek() {
+		case '.':
+			p.pos++
+			name := p.ident()
+			if name == "" {
+				return nil, p.errorf("expected a field name")
+			}
+			path = append(path, step{field: name})
+		case '[':
+			p.pos++
+			s, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, s)
+		default:
+			return nil, p.errorf("unexpected %q", p.peek())
+		}
+	}
+	return path, nil
+}
+
+// parseBracket parses a quoted field name or an index, and the closing
+// bracket.
+func (p *selectorParser) parseBracket() (step, error) {
+	var s step
+	if q := p.peek(); q == '\'' || q == '"' {
+		end := strings.IndexByte(p.s[p.pos+1:], q)
+		if end < 0 {
+			return s, p.errorf("unterminated field name")
+		}
+		s.field = p.s[p.pos+1 : p.pos+1+end]
+		if s.field == "" {
+			return s, p.errorf("empty field name")
+		}
+		p.pos += end + 2
+	} else {
+		start := p.pos
+		for p.peek() >= '0' && p.peek() <= '9' {
+			p.pos++
+		}
+		i, err := strconv.Atoi(p.s[start:p.pos])
+		if err != nil {
+			return s, p.errorf("expected an index or a quoted field name")
+		}
+		s.index = i
+	}
+
+	if p.peek() != ']' {
+		return s, p.errorf("expected ]")
+	}
+	p.pos++
+	return s, nil
+}
+
+// ident reads a field name made of letters, digits, '_' and '-'.
+func (p *selectorParser) ident() string {
+	start := p.pos
+	for isIdentByte(p.peek()) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '-' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (p *selectorParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelector(t *testing.T) {
+
+	data := json.RawMessage(`{"meta":{"name":"x","odd key":true},"tags":["a","b"],"size":3,"big":12345678901234567890}`)
+
+	var tests = map[string]struct {
+		sel string
+		exp string
+	}{
+		"single value": {
+			sel: "$.meta.name",
+			exp: `"x"`,
+		},
+		"bare path": {
+			sel: "meta.name",
+			exp: `"x"`,
+		},
+		"whole data": {
+			sel: "$",
+			// objects taken from the data are encoded with their keys sorted
+			exp: `{"big":12345678901234567890,"meta":{"name":"x","odd key":true},"size":3,"tags":["a","b"]}`,
+		},
+		"index": {
+			sel: "$.tags[1]",
+			exp: `"b"`,
+		},
+		"quoted field": {
+			sel: `$.meta['odd key']`,
+			exp: `true`,
+		},
+		"double quoted field": {
+			sel: `$["meta"]["name"]`,
+			exp: `"x"`,
+		},
+		"missing value": {
+			sel: "$.tags[2]",
+			exp: `null`,
+		},
+		"named value": {
+			sel: "n=$.meta.name",
+			exp: `{"n":"x"}`,
+		},
+		"fields": {
+			sel: "size,name=$.meta.name,first=$.tags[0],big",
+			exp: `{"size":3,"name":"x","first":"a","big":12345678901234567890}`,
+		},
+		"missing fields": {
+			sel: "size,missing,none=$.meta.none,deep=$.size.deep",
+			exp: `{"size":3}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			sel, err := parseSelector(tt.sel)
+			assert.NoError(t, err)
+			got, err := sel.apply(data)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.exp, string(got))
+		})
+	}
+}
+
+func TestSelectorNoData(t *testing.T) {
+
+	sel, err := parseSelector("a,b")
+	assert.NoError(t, err)
+	got, err := sel.apply(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `{}`, string(got))
+}
+
+func TestParseSelectorError(t *testing.T) {
+
+	var tests = map[string]struct {
+		sel string
+		exp string
+	}{
+		"empty field": {
+			sel: "a,,b",
+			exp: "at offset 2: expected a path",
+		},
+		"empty step": {
+			sel: "a..b",
+			exp: "at offset 2: expected a field name",
+		},
+		"unnamed index": {
+			sel: "a,$.tags[0]",
+			exp: `path $["tags"][0] needs a name`,
+		},
+		"unnamed root": {
+			sel: "$,a",
+			exp: "path $ needs a name",
+		},
+		"unclosed bracket": {
+			sel: "$.tags[0",
+			exp: "at offset 8: expected ]",
+		},
+		"unterminated name": {
+			sel: "$['tags",
+			exp: "unterminated field name",
+		},
+		"bad index": {
+			sel: "$.tags[x]",
+			exp: "expected an index or a quoted field name",
+		},
+		"trailing text": {
+			sel: "$.tags x",
+			exp: `at offset 6: unexpected ' '`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseSelector(tt.sel)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.exp)
+		})
+	}
+}
@@ -2,10 +2,7 @@ module github.com/kingledion/go-tools
 
 go 1.21
 
-require (
-	github.com/phf/go-queue v0.0.0-20170504031614-9abe38d0371d
-	github.com/stretchr/testify v1.7.0
-)
+require github.com/stretchr/testify v1.7.0
 
 require (
 	github.com/davecgh/go-spew v1.1.0 // indirect
@@ -0,0 +1,139 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// PopBatch pops up to limit items from a queue. It blocks until the first
+// item is available, and then collects items until it has limit of them,
+// maxWait has passed since the first item, the queue is empty and closed, or
+// the context is done. If maxWait is not positive, only the items already
+// queued are collected, without waiting. Items are returned in the order they
+// were popped.
+//
+// If no item was popped, an error is returned: ErrClosed if the queue is
+// closed and empty, or the error of the context if it is done first. Once the
+// first item is popped, the batch ends without an error when the wait is
+// over or the queue is closed; any other error of the queue, such as an item
+// of a Disk queue that cannot be decoded, is returned together with the items
+// popped before it.
+func PopBatch[T any](ctx context.Context, q Blocking[T], limit int, maxWait time.Duration) ([]T, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	first, err := q.PopCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	batch := []T{first}
+
+	wait := ctx
+	if maxWait > 0 {
+		var cancel context.CancelFunc
+		wait, cancel = context.WithTimeout(ctx, maxWait)
+		defer cancel()
+	}
+	for len(batch) < limit && wait.Err() == nil {
+		// take the items that are already queued without setting up a wait
+		elem, ok, err := tryPop(q)
+		if err != nil {
+			return batch, err
+		}
+		if !ok {
+			if maxWait <= 0 {
+				break
+			}
+			if elem, err = q.PopCtx(wait); err != nil {
+				if errors.Is(err, ErrClosed) || (wait.Err() != nil && errors.Is(err, wait.Err())) {
+					break
+				}
+				return batch, err
+			}
+		}
+		batch = append(batch, elem)
+	}
+	return batch, nil
+}
+
+// tryPop removes and returns an item from a queue without blocking, as
+// TryPop does, but returns the error of a queue that fails to pop an item it
+// holds, such as a Disk queue whose item cannot be decoded.
+func tryPop[T any](q Blocking[T]) (T, bool, error) {
+	if p, ok := q.(interface{ tryPop() (T, bool, error) }); ok {
+		return p.tryPop()
+	}
+	elem, ok := q.TryPop()
+	return elem, ok, nil
+}
+
+// PushBatch pushes items to a queue in order. It stops at the first item that
+// cannot be pushed, returning the number of items pushed and the error of
+// the push that failed.
+func PushBatch[T any](ctx context.Context, q Blocking[T], items []T) (int, error) {
+	for i, elem := range items {
+		if err := q.PushCtx(ctx, elem); err != nil {
+			return i, err
+		}
+	}
+	return len(items), nil
+}
+
+// Consume pops items from a queue with the given number of workers, calling
+// fn for each item in the goroutine of the worker that popped it. It returns
+// once the queue is closed and drained, or the context is done; in either
+// case it waits for the items being processed to finish first. The context
+// passed to fn is not cancelled with ctx, so that the items already popped
+// are processed in full.
+//
+// Cancelling the context stops consumption without draining the queue: each
+// worker finishes the item it is processing and pops no more, so the items
+// still queued are left in the queue for a later call.
+//
+// The errors returned by fn do not stop the workers. They are collected, and
+// returned joined by errors.Join together with the error of the context if it
+// was done. A worker stops if popping fails for another reason than the
+// queue being closed, and its error is collected too.
+func Consume[T any](ctx context.Context, q Blocking[T], workers int, fn func(ctx context.Context, elem T) error) error {
+	if workers <= 0 {
+		workers = 1
+	}
+	work := context.WithoutCancel(ctx)
+
+	var mu sync.Mutex
+	var errs []error
+	collect := func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// PopCtx may still return an item once the context is done
+			for ctx.Err() == nil {
+				elem, err := q.PopCtx(ctx)
+				if err != nil {
+					// a queue that fails to pop for any other reason than
+					// being closed or cancelled stops the worker
+					if !errors.Is(err, ErrClosed) && ctx.Err() == nil {
+						collect(err)
+					}
+					return
+				}
+				if err := fn(work, elem); err != nil {
+					collect(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(append(errs, ctx.Err())...)
+}
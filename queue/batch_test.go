@@ -0,0 +1,244 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPopBatch(t *testing.T) {
+
+	var tests = map[string]struct {
+		push     []int
+		close    bool
+		limit    int
+		maxWait  time.Duration
+		expBatch []int
+		expErr   error
+	}{
+		"up to limit": {
+			push:     []int{1, 2, 3, 4},
+			limit:    3,
+			expBatch: []int{1, 2, 3},
+		},
+		"fewer than limit after wait": {
+			push:     []int{1, 2},
+			limit:    3,
+			expBatch: []int{1, 2},
+		},
+		"closed": {
+			push:     []int{1},
+			close:    true,
+			limit:    3,
+			expBatch: []int{1},
+		},
+		"closed and empty": {
+			close:  true,
+			limit:  3,
+			expErr: ErrClosed,
+		},
+		"no wait": {
+			push:     []int{1, 2, 3, 4},
+			limit:    3,
+			maxWait:  -1,
+			expBatch: []int{1, 2, 3},
+		},
+		"no wait for fewer than limit": {
+			push:     []int{1, 2},
+			limit:    3,
+			maxWait:  -1,
+			expBatch: []int{1, 2},
+		},
+		"zero limit": {
+			push:  []int{1},
+			limit: 0,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for qname, q := range blocking(t) {
+				t.Run(qname, func(t *testing.T) {
+					n, err := PushBatch(context.Background(), q, tt.push)
+					assert.NoError(t, err)
+					assert.Equal(t, len(tt.push), n)
+					if tt.close {
+						assert.NoError(t, q.Close())
+					}
+
+					maxWait := tt.maxWait
+					if maxWait == 0 {
+						maxWait = 10 * time.Millisecond
+					}
+					got, err := PopBatch(context.Background(), q, tt.limit, maxWait)
+					assert.Equal(t, tt.expBatch, got)
+					assert.ErrorIs(t, err, tt.expErr)
+				})
+			}
+		})
+	}
+}
+
+func TestPopBatchWaits(t *testing.T) {
+
+	q := NewBlockingFIFO[int]()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := PopBatch[int](ctx, q, 2, time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "no first item")
+
+	// items pushed within the wait join the batch
+	go func() {
+		q.Push(1)
+		time.Sleep(5 * time.Millisecond)
+		q.Push(2)
+	}()
+	got, err := PopBatch[int](context.Background(), q, 2, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, got)
+}
+
+// failingQueue is a BlockingFIFO that fails to pop negative items, as a Disk
+// queue fails to pop items it cannot decode. TryPop never returns an item, so
+// that every item is popped with PopCtx.
+type failingQueue struct {
+	*BlockingFIFO[int]
+}
+
+func (q failingQueue) PopCtx(ctx context.Context) (int, error) {
+	elem, err := q.BlockingFIFO.PopCtx(ctx)
+	if err == nil && elem < 0 {
+		return 0, errors.New("cannot decode item")
+	}
+	return elem, err
+}
+
+func (q failingQueue) TryPop() (int, bool) {
+	return 0, false
+}
+
+func TestPopBatchError(t *testing.T) {
+
+	q := failingQueue{NewBlockingFIFO[int]()}
+	_, err := PushBatch[int](context.Background(), q, []int{1, 2, -1, 3})
+	assert.NoError(t, err)
+
+	got, err := PopBatch[int](context.Background(), q, 4, time.Second)
+	assert.EqualError(t, err, "cannot decode item")
+	assert.Equal(t, []int{1, 2}, got)
+
+	got, err = PopBatch[int](context.Background(), q, 4, 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3}, got)
+}
+
+func TestPopBatchDecodeError(t *testing.T) {
+
+	q, err := OpenDisk[string](t.TempDir(), WithCodec[string](stringCodec{}))
+	assert.NoError(t, err)
+	defer q.Close()
+	_, err = PushBatch[string](context.Background(), q, []string{"a", "b", "", "c"})
+	assert.NoError(t, err)
+
+	// the item that cannot be decoded is drained without waiting
+	got, err := PopBatch[string](context.Background(), q, 4, 0)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "error decoding item")
+	}
+	assert.Equal(t, []string{"a", "b"}, got)
+
+	got, err = PopBatch[string](context.Background(), q, 4, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c"}, got)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestPushBatch(t *testing.T) {
+
+	q := New[int](2)
+	n, err := PushBatch[int](context.Background(), q, []int{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	n, err = PushBatch[int](ctx, q, []int{3})
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "push to full queue")
+	assert.Equal(t, 0, n)
+
+	assert.NoError(t, q.Close())
+	n, err = PushBatch[int](context.Background(), q, []int{3, 4})
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.Equal(t, 0, n)
+}
+
+func TestConsume(t *testing.T) {
+
+	errOdd := errors.New("odd")
+
+	for name, q := range blocking(t) {
+		t.Run(name, func(t *testing.T) {
+			var sum atomic.Int64
+			done := make(chan error)
+			go func() {
+				done <- Consume(context.Background(), q, 4, func(_ context.Context, v int) error {
+					sum.Add(int64(v))
+					if v%2 == 1 {
+						return errOdd
+					}
+					return nil
+				})
+			}()
+
+			for i := 1; i <= 100; i++ {
+				assert.NoError(t, q.Push(i))
+			}
+			assert.NoError(t, q.Close())
+
+			err := <-done
+			assert.ErrorIs(t, err, errOdd)
+			assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 50)
+			assert.Equal(t, int64(5050), sum.Load())
+		})
+	}
+}
+
+func TestConsumeCancel(t *testing.T) {
+
+	q := NewBlockingFIFO[int]()
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, q.Push(i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var started sync.WaitGroup
+	started.Add(2)
+	release := make(chan struct{})
+	var processed atomic.Int64
+
+	done := make(chan error)
+	go func() {
+		done <- Consume(ctx, q, 2, func(ctx context.Context, v int) error {
+			started.Done()
+			<-release
+			processed.Add(1)
+			// items being processed are finished even though consumption
+			// was cancelled
+			return ctx.Err()
+		})
+	}()
+
+	// cancel while each worker is processing an item
+	started.Wait()
+	cancel()
+	close(release)
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Equal(t, int64(2), processed.Load())
+	assert.Equal(t, 8, q.Len(), "the queued items are not drained")
+}
@@ -0,0 +1,38 @@
+package queue
+
+import "time"
+
+// Clock tells the time for the queues that schedule items, and creates the
+// timers they wait on. The system clock is used unless another is given with
+// WithClock, typically so that tests can advance time without sleeping.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event created by a Clock. Its channel receives the time
+// once the duration given to NewTimer has passed. Stop prevents the timer from
+// firing, and returns false if it already has.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// systemClock is the Clock backed by package time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
@@ -0,0 +1,119 @@
+package queue
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a Clock whose time only moves when advanced by a test.
+type fakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func newFakeClock() *fakeClock {
+	c := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{clock: c, at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance moves the time forward, firing the timers that become due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = pending
+}
+
+// BlockUntil waits until a timer due no later than the given time is
+// pending, so that a test knows a goroutine is waiting on the clock for it.
+func (c *fakeClock) BlockUntil(at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for !c.pending(at) {
+		c.cond.Wait()
+	}
+}
+
+func (c *fakeClock) pending(at time.Time) bool {
+	for _, t := range c.timers {
+		if !t.at.After(at) {
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	c     chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, p := range t.clock.timers {
+		if p == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func TestSystemClock(t *testing.T) {
+
+	o := newOptions(nil)
+	c := o.clock
+
+	before := time.Now()
+	assert.False(t, c.Now().Before(before))
+
+	timer := c.NewTimer(time.Millisecond)
+	fired := <-timer.C()
+	assert.False(t, fired.Before(before))
+	assert.False(t, timer.Stop(), "stop fired timer")
+
+	timer = c.NewTimer(time.Hour)
+	assert.True(t, timer.Stop())
+}
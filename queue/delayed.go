@@ -0,0 +1,175 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// delayedItem is an item of a Delayed queue with the time it becomes due.
+// Items due at the same time are ordered by seq, the order they were pushed.
+type delayedItem[T any] struct {
+	elem  T
+	due   time.Time
+	seq   uint64
+	stamp int64
+}
+
+// Delayed is an unbounded queue whose items become visible only once they are
+// due, at a time given when each is pushed. Items are popped in the order
+// they become due, and items due at the same time in the order they were
+// pushed. It is safe for concurrent use.
+//
+// Push never blocks, while Pop blocks until an item is due or the queue is
+// closed. Time is told by the clock given with WithClock, or by the system
+// clock.
+type Delayed[T any] struct {
+	gate
+	observer
+	clock Clock
+	items *Priority[delayedItem[T]]
+	seq   uint64
+}
+
+// NewDelayed creates and returns an empty Delayed queue. The queue is observed
+// by the hooks given with WithHooks.
+func NewDelayed[T any](opts ...Option) *Delayed[T] {
+	o := newOptions(opts)
+	return &Delayed[T]{
+		gate:     newGate(),
+		observer: observer{o.hooks},
+		clock:    o.clock,
+		items: NewPriority(func(a, b delayedItem[T]) bool {
+			if a.due.Equal(b.due) {
+				return a.seq < b.seq
+			}
+			return a.due.Before(b.due)
+		}),
+	}
+}
+
+// PushAt adds an item that becomes due at the given time. An item whose time
+// has already passed is due immediately. Returns ErrClosed if the queue is
+// closed.
+func (q *Delayed[T]) PushAt(elem T, at time.Time) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		q.dropped()
+		return ErrClosed
+	}
+	q.items.Push(delayedItem[T]{elem: elem, due: at, seq: q.seq, stamp: q.stamp()})
+	q.seq++
+	q.mu.Unlock()
+
+	q.pushed()
+	// wake a blocked popper to wait for the new item if it is due earlier
+	q.signal()
+	return nil
+}
+
+// PushAfter adds an item that becomes due once the given duration has
+// passed. Returns ErrClosed if the queue is closed.
+func (q *Delayed[T]) PushAfter(elem T, d time.Duration) error {
+	return q.PushAt(elem, q.clock.Now().Add(d))
+}
+
+// Push adds an item that is due immediately. Returns ErrClosed if the queue
+// is closed.
+func (q *Delayed[T]) Push(elem T) error {
+	return q.PushAfter(elem, 0)
+}
+
+// PushCtx adds an item that is due immediately. Since the queue is unbounded
+// this never blocks, but the item is not added, and the error of the context
+// is returned, if the context is already done.
+func (q *Delayed[T]) PushCtx(ctx context.Context, elem T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return q.Push(elem)
+}
+
+// TryPush adds an item that is due immediately. Since the queue is unbounded,
+// this only fails if the queue is closed.
+func (q *Delayed[T]) TryPush(elem T) bool {
+	return q.Push(elem) == nil
+}
+
+// Pop removes and returns the first item to become due, blocking until it is
+// due. Once the queue is closed, Pop continues to return the remaining items
+// as they become due and then returns ErrClosed.
+func (q *Delayed[T]) Pop() (T, error) {
+	return q.PopCtx(context.Background())
+}
+
+// PopCtx removes and returns the first item to become due, blocking until it
+// is due. Returns the error of the context if it is done before an item is
+// due. A closed queue behaves as for Pop.
+func (q *Delayed[T]) PopCtx(ctx context.Context) (T, error) {
+	var start int64
+	defer func() { q.blocked(start) }()
+	for {
+		elem, ok, next, closed := q.pop()
+		if ok {
+			return elem, nil
+		}
+		if closed && next < 0 {
+			return elem, ErrClosed
+		}
+		start = q.waiting(start)
+		if err := q.waitDue(ctx, q.clock, next, closed); err != nil {
+			return elem, err
+		}
+	}
+}
+
+// TryPop removes and returns the first item to become due if it is already
+// due. Returns false if no item is due.
+func (q *Delayed[T]) TryPop() (T, bool) {
+	elem, ok, _, _ := q.pop()
+	return elem, ok
+}
+
+// pop removes the first item to become due if it is due. Otherwise it returns
+// how long until that item is due, or a negative duration if the queue is
+// empty.
+func (q *Delayed[T]) pop() (elem T, ok bool, next time.Duration, closed bool) {
+	q.mu.Lock()
+	closed = q.closed
+	next = -1
+	head, found := q.items.Peek()
+	if found {
+		next = head.due.Sub(q.clock.Now())
+		if next <= 0 {
+			q.items.Pop()
+			elem, ok = head.elem, true
+		}
+	}
+	more := q.items.Len() > 0
+	q.mu.Unlock()
+
+	if ok {
+		q.popped(head.stamp)
+	}
+	// pass the token on to the next blocked popper, which waits for the
+	// next item to become due
+	if ok && more {
+		q.signal()
+	}
+	return
+}
+
+// Len returns the number of items in the queue, whether they are due or not.
+func (q *Delayed[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+// Close closes the queue. Future calls to Push fail with ErrClosed, and
+// blocked calls to Pop return ErrClosed once no items are left. Closing a
+// queue more than once has no effect.
+func (q *Delayed[T]) Close() error {
+	q.close()
+	return nil
+}
@@ -0,0 +1,153 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelayed(t *testing.T) {
+
+	type push struct {
+		elem  string
+		after time.Duration
+	}
+
+	var tests = map[string]struct {
+		push    []push
+		advance time.Duration
+		expPop  []string
+		expLen  int
+	}{
+		"none due": {
+			push:    []push{{"a", time.Second}},
+			advance: time.Second - 1,
+			expPop:  []string{},
+			expLen:  1,
+		},
+		"due order": {
+			push:    []push{{"a", 3 * time.Second}, {"b", time.Second}, {"c", 2 * time.Second}},
+			advance: 3 * time.Second,
+			expPop:  []string{"b", "c", "a"},
+		},
+		"same time in push order": {
+			push:    []push{{"a", time.Second}, {"b", time.Second}, {"c", 0}, {"d", time.Second}},
+			advance: time.Second,
+			expPop:  []string{"c", "a", "b", "d"},
+		},
+		"partly due": {
+			push:    []push{{"a", time.Minute}, {"b", -time.Minute}, {"c", time.Second}},
+			advance: time.Second,
+			expPop:  []string{"b", "c"},
+			expLen:  1,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := newFakeClock()
+			q := NewDelayed[string](WithClock(c))
+			for _, p := range tt.push {
+				assert.NoError(t, q.PushAfter(p.elem, p.after))
+			}
+			c.Advance(tt.advance)
+
+			gotPop := []string{}
+			for v, ok := q.TryPop(); ok; v, ok = q.TryPop() {
+				gotPop = append(gotPop, v)
+			}
+			assert.Equal(t, tt.expPop, gotPop)
+			assert.Equal(t, tt.expLen, q.Len())
+		})
+	}
+}
+
+func TestDelayedPopBlocks(t *testing.T) {
+
+	c := newFakeClock()
+	q := NewDelayed[int](WithClock(c))
+	start := c.Now()
+
+	popped := make(chan int)
+	go func() {
+		for {
+			v, err := q.Pop()
+			if err != nil {
+				close(popped)
+				return
+			}
+			popped <- v
+		}
+	}()
+
+	assert.NoError(t, q.PushAt(1, start.Add(10*time.Second)))
+	c.BlockUntil(start.Add(10 * time.Second))
+
+	// an item due earlier wakes the popper to wait for it instead
+	assert.NoError(t, q.PushAt(2, start.Add(2*time.Second)))
+	c.BlockUntil(start.Add(2 * time.Second))
+
+	select {
+	case v := <-popped:
+		t.Fatalf("popped %d before it was due", v)
+	default:
+	}
+
+	c.Advance(2 * time.Second)
+	assert.Equal(t, 2, <-popped)
+
+	c.BlockUntil(start.Add(10 * time.Second))
+	c.Advance(8 * time.Second)
+	assert.Equal(t, 1, <-popped)
+
+	assert.NoError(t, q.Close())
+	_, ok := <-popped
+	assert.False(t, ok)
+}
+
+func TestDelayedClose(t *testing.T) {
+
+	c := newFakeClock()
+	q := NewDelayed[int](WithClock(c))
+	assert.NoError(t, q.PushAfter(1, time.Second))
+
+	assert.NoError(t, q.Close())
+	assert.ErrorIs(t, q.PushAfter(2, 0), ErrClosed)
+	assert.False(t, q.TryPush(2))
+
+	// the remaining item is still popped once it is due
+	popped := make(chan error)
+	go func() {
+		v, err := q.Pop()
+		assert.Equal(t, 1, v)
+		popped <- err
+	}()
+
+	c.BlockUntil(c.Now().Add(time.Second))
+	c.Advance(time.Second)
+	assert.NoError(t, <-popped)
+
+	_, err := q.Pop()
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestDelayedPopCtx(t *testing.T) {
+
+	c := newFakeClock()
+	q := NewDelayed[int](WithClock(c))
+	assert.NoError(t, q.PushAfter(1, time.Second))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	popped := make(chan error)
+	go func() {
+		_, err := q.PopCtx(ctx)
+		popped <- err
+	}()
+
+	c.BlockUntil(c.Now().Add(time.Second))
+	cancel()
+	assert.ErrorIs(t, <-popped, context.Canceled)
+	assert.Equal(t, 1, q.Len())
+}
@@ -0,0 +1,152 @@
+package queue
+
+// minDequeCap is the smallest capacity of the ring buffer of a Deque once it
+// holds any items. The buffer never shrinks below it.
+const minDequeCap = 8
+
+// Deque is an unbounded double-ended queue backed by a ring buffer. Items can
+// be pushed and popped at both ends in amortized constant time, and any item
+// can be read by its position in constant time. The buffer doubles in size
+// when it is full and halves when it is no more than a quarter full; apart
+// from the unused part of the buffer there is no overhead per item.
+//
+// A Deque is not safe for concurrent use.
+//
+// The zero value is an empty deque ready to use.
+type Deque[T any] struct {
+	buf  []T
+	head int
+	n    int
+}
+
+// NewDeque creates and returns an empty Deque.
+func NewDeque[T any]() *Deque[T] {
+	return &Deque[T]{}
+}
+
+// PushBack adds an item to the back of the deque.
+func (d *Deque[T]) PushBack(elem T) {
+	d.grow()
+	d.buf[d.index(d.n)] = elem
+	d.n++
+}
+
+// PushFront adds an item to the front of the deque.
+func (d *Deque[T]) PushFront(elem T) {
+	d.grow()
+	d.head = (d.head - 1) & (len(d.buf) - 1)
+	d.buf[d.head] = elem
+	d.n++
+}
+
+// PopFront removes and returns the item at the front of the deque. Returns
+// false if the deque is empty.
+func (d *Deque[T]) PopFront() (T, bool) {
+	var zero T
+	if d.n == 0 {
+		return zero, false
+	}
+
+	elem := d.buf[d.head]
+	d.buf[d.head] = zero // release the reference held by the buffer
+	d.head = d.index(1)
+	d.n--
+	d.shrink()
+	return elem, true
+}
+
+// PopBack removes and returns the item at the back of the deque. Returns
+// false if the deque is empty.
+func (d *Deque[T]) PopBack() (T, bool) {
+	var zero T
+	if d.n == 0 {
+		return zero, false
+	}
+
+	i := d.index(d.n - 1)
+	elem := d.buf[i]
+	d.buf[i] = zero // release the reference held by the buffer
+	d.n--
+	d.shrink()
+	return elem, true
+}
+
+// Front returns the item at the front of the deque without removing it.
+// Returns false if the deque is empty.
+func (d *Deque[T]) Front() (T, bool) {
+	if d.n == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.buf[d.head], true
+}
+
+// Back returns the item at the back of the deque without removing it.
+// Returns false if the deque is empty.
+func (d *Deque[T]) Back() (T, bool) {
+	if d.n == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.buf[d.index(d.n-1)], true
+}
+
+// At returns the item at position i of the deque, counting from zero at the
+// front. It panics if i is out of range.
+func (d *Deque[T]) At(i int) T {
+	if i < 0 || i >= d.n {
+		panic("queue: deque index out of range")
+	}
+	return d.buf[d.index(i)]
+}
+
+// Range calls fn for each item of the deque from front to back, with the
+// position of the item. Iteration stops if fn returns false. The deque must
+// not be modified during iteration.
+func (d *Deque[T]) Range(fn func(i int, elem T) bool) {
+	for i := 0; i < d.n; i++ {
+		if !fn(i, d.buf[d.index(i)]) {
+			return
+		}
+	}
+}
+
+// Len returns the number of items in the deque.
+func (d *Deque[T]) Len() int {
+	return d.n
+}
+
+// index returns the position in the buffer of the item at position i of the
+// deque.
+func (d *Deque[T]) index(i int) int {
+	return (d.head + i) & (len(d.buf) - 1)
+}
+
+func (d *Deque[T]) grow() {
+	if d.n == len(d.buf) {
+		d.resize(max(2*len(d.buf), minDequeCap))
+	}
+}
+
+func (d *Deque[T]) shrink() {
+	if len(d.buf) > minDequeCap && d.n <= len(d.buf)/4 {
+		d.resize(len(d.buf) / 2)
+	}
+}
+
+// resize moves the items of the deque to a new buffer of the given capacity,
+// which must be a power of two no smaller than the number of items.
+func (d *Deque[T]) resize(capacity int) {
+	buf := make([]T, capacity)
+	if d.n > 0 {
+		end := d.head + d.n
+		if end <= len(d.buf) {
+			copy(buf, d.buf[d.head:end])
+		} else {
+			k := copy(buf, d.buf[d.head:])
+			copy(buf[k:], d.buf[:end-len(d.buf)])
+		}
+	}
+	d.buf = buf
+	d.head = 0
+}
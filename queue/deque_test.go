@@ -0,0 +1,163 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeque(t *testing.T) {
+
+	var tests = map[string]struct {
+		ops    []string // "<v" pushes v to the front, ">v" to the back; "<" and ">" pop
+		expPop []int
+		expAll []int
+		expCap int
+	}{
+		"empty": {
+			ops:    []string{"<", ">"},
+			expPop: []int{},
+			expAll: []int{},
+		},
+		"push back pop front": {
+			ops:    []string{">1", ">2", ">3", "<", "<"},
+			expPop: []int{1, 2},
+			expAll: []int{3},
+			expCap: minDequeCap,
+		},
+		"push back pop back": {
+			ops:    []string{">1", ">2", ">3", ">", ">"},
+			expPop: []int{3, 2},
+			expAll: []int{1},
+			expCap: minDequeCap,
+		},
+		"push front": {
+			ops:    []string{"<1", "<2", ">3", "<4"},
+			expPop: []int{},
+			expAll: []int{4, 2, 1, 3},
+			expCap: minDequeCap,
+		},
+		"grow while wrapped": {
+			ops:    []string{"<1", "<2", "<3", ">4", ">5", ">6", ">7", ">8", "<9", ">", "<"},
+			expPop: []int{8, 9},
+			expAll: []int{3, 2, 1, 4, 5, 6, 7},
+			expCap: 2 * minDequeCap,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var d Deque[int]
+			gotPop := []int{}
+			for _, op := range tt.ops {
+				var v int
+				var ok bool
+				switch {
+				case op == "<":
+					v, ok = d.PopFront()
+				case op == ">":
+					v, ok = d.PopBack()
+				case op[0] == '<':
+					d.PushFront(int(op[1] - '0'))
+				default:
+					d.PushBack(int(op[1] - '0'))
+				}
+				if ok {
+					gotPop = append(gotPop, v)
+				}
+			}
+
+			gotAll := []int{}
+			for i := 0; i < d.Len(); i++ {
+				gotAll = append(gotAll, d.At(i))
+			}
+
+			assert.Equal(t, tt.expPop, gotPop)
+			assert.Equal(t, tt.expAll, gotAll)
+			assert.Equal(t, tt.expCap, len(d.buf))
+		})
+	}
+}
+
+func TestDequeFrontBack(t *testing.T) {
+
+	d := NewDeque[string]()
+
+	_, ok := d.Front()
+	assert.False(t, ok)
+	_, ok = d.Back()
+	assert.False(t, ok)
+
+	d.PushBack("b")
+	d.PushFront("a")
+	d.PushBack("c")
+
+	front, ok := d.Front()
+	assert.True(t, ok)
+	assert.Equal(t, "a", front)
+	back, ok := d.Back()
+	assert.True(t, ok)
+	assert.Equal(t, "c", back)
+	assert.Equal(t, 3, d.Len())
+
+	assert.Panics(t, func() { d.At(3) })
+	assert.Panics(t, func() { d.At(-1) })
+}
+
+func TestDequeGrowShrink(t *testing.T) {
+
+	d := NewDeque[int]()
+	for i := 0; i < 500; i++ {
+		d.PushBack(i)
+		d.PushFront(-i - 1)
+	}
+	assert.Equal(t, 1000, d.Len())
+	assert.Equal(t, 1024, len(d.buf))
+	assert.Equal(t, -500, d.At(0))
+	assert.Equal(t, 499, d.At(999))
+
+	for i := 0; i < 495; i++ {
+		d.PopFront()
+		d.PopBack()
+	}
+	assert.Equal(t, 10, d.Len())
+	assert.Equal(t, 32, len(d.buf))
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, i-5, d.At(i))
+	}
+
+	for d.Len() > 0 {
+		d.PopBack()
+	}
+	assert.Equal(t, minDequeCap, len(d.buf))
+}
+
+func TestDequeRange(t *testing.T) {
+
+	var d Deque[int]
+	for i := 1; i <= 5; i++ {
+		d.PushBack(i)
+	}
+
+	var got []int
+	d.Range(func(i int, elem int) bool {
+		assert.Equal(t, i+1, elem)
+		got = append(got, elem)
+		return elem < 3
+	})
+	assert.Equal(t, []int{1, 2, 3}, got)
+}
+
+func TestDequeReleasesItems(t *testing.T) {
+
+	d := NewDeque[*int]()
+	v := 1
+	d.PushBack(&v)
+	d.PushBack(&v)
+	d.PopFront()
+	d.PopBack()
+
+	for _, elem := range d.buf {
+		assert.Nil(t, elem)
+	}
+}
@@ -0,0 +1,696 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCorrupt is returned when a segment file of a Disk queue holds a record
+// that cannot be read. Only the end of the last segment is expected to hold
+// such a record, left by a write that was torn by a crash, and it is removed
+// when the queue is opened.
+var ErrCorrupt = errors.New("queue: corrupt segment")
+
+// errTorn is returned by readRecord for a record that is incomplete or does
+// not match its checksum.
+var errTorn = errors.New("torn record")
+
+const (
+	defaultSegmentSize = 64 << 20
+
+	segmentExt = ".seg"
+	cursorFile = "cursor"
+
+	// recordHeader is the size of the length and checksum of a record
+	recordHeader = 8
+	// cursorSize is the size of the segment, offset and checksum of a cursor
+	cursorSize = 20
+)
+
+// Codec converts the items of a Disk queue to and from bytes.
+type Codec[T any] interface {
+	Encode(elem T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// JSONCodec is the Codec that stores items as JSON. It is the default codec
+// of a Disk queue.
+type JSONCodec[T any] struct{}
+
+// Encode returns the JSON encoding of an item.
+func (JSONCodec[T]) Encode(elem T) ([]byte, error) {
+	return json.Marshal(elem)
+}
+
+// Decode returns the item encoded as JSON in data.
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var elem T
+	err := json.Unmarshal(data, &elem)
+	return elem, err
+}
+
+// SyncPolicy determines when a Disk queue flushes its files to stable
+// storage. Pushed items are written to the operating system immediately, so
+// they survive a crash of the process under every policy; the policy decides
+// what survives a crash of the machine, and which pops are repeated after a
+// crash of either.
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes the queue on every push and pop, so that a crash
+	// loses no pushed item and repeats no pop.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval flushes the queue at a fixed interval. A crash of the
+	// machine loses the items pushed since the last flush, and a crash
+	// repeats the pops since the last flush.
+	SyncInterval
+	// SyncNever leaves flushing segments to the operating system, and
+	// records the position of the queue only when a segment is consumed or
+	// the queue is closed. A crash repeats the pops since then.
+	SyncNever
+)
+
+// Disk is an unbounded first-in first-out queue whose items are stored in
+// files, so that they survive the queue being closed and reopened, and to the
+// extent of its SyncPolicy, a crash. It is safe for concurrent use, and
+// implements Blocking like the queues held in memory.
+//
+// Items are appended to segment files in a directory, each record holding
+// the length, checksum and encoding of an item. A segment is deleted once
+// all of its items have been popped, and a new one is started once it
+// exceeds the segment size. The position of the next item to pop is kept in
+// a cursor file.
+//
+// Only one Disk may use a directory at a time.
+type Disk[T any] struct {
+	gate
+	observer
+	dir         string
+	codec       Codec[T]
+	policy      SyncPolicy
+	segmentSize int64
+
+	// the last segment, appended to by Push
+	w      *os.File
+	wseg   uint64
+	wsize  int64
+	wdirty bool // written since the last flush
+
+	// the first segment, read by Pop; it is opened when needed
+	r      *os.File
+	rseg   uint64
+	roff   int64
+	rend   int64 // size of the first segment once it is no longer written
+	rdirty bool  // read since the cursor was last written
+
+	n   int
+	err error // error of the last failed flush, reported by Close
+
+	// the stamps of the items pushed since the queue was opened, kept for
+	// the hooks; the items before them are untimed
+	stamps  Deque[int64]
+	untimed int
+
+	flushed chan struct{} // closed once the flush loop of SyncInterval ends
+}
+
+// OpenDisk opens the queue stored in a directory, creating the directory if
+// needed. A record torn by a crash at the end of the last segment is
+// removed; other unreadable records fail with ErrCorrupt.
+//
+// The queue is configured with WithCodec, WithSync, WithSegmentSize, and for
+// SyncInterval, WithClock. It is observed by the hooks given with WithHooks;
+// the latency of the items pushed before the queue was opened is unknown, and
+// reported as zero.
+func OpenDisk[T any](dir string, opts ...Option) (*Disk[T], error) {
+	o := newOptions(opts)
+
+	var codec Codec[T] = JSONCodec[T]{}
+	if o.codec != nil {
+		c, ok := o.codec.(Codec[T])
+		if !ok {
+			return nil, fmt.Errorf("queue: codec %T does not match the item type", o.codec)
+		}
+		codec = c
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating queue directory: %w", err)
+	}
+
+	q := &Disk[T]{
+		gate:        newGate(),
+		observer:    observer{o.hooks},
+		dir:         dir,
+		codec:       codec,
+		policy:      o.sync,
+		segmentSize: o.segmentSize,
+	}
+	if err := q.recover(); err != nil {
+		return nil, err
+	}
+	q.untimed = q.n
+
+	if q.policy == SyncInterval {
+		q.flushed = make(chan struct{})
+		go q.flushEvery(o.clock, o.syncInterval)
+	}
+	return q, nil
+}
+
+// recover restores the state of the queue from its directory.
+func (q *Disk[T]) recover() error {
+	segs, err := q.segments()
+	if err != nil {
+		return fmt.Errorf("error reading queue directory: %w", err)
+	}
+
+	if len(segs) == 0 {
+		q.w, err = q.createSegment(0)
+		return err
+	}
+
+	// start from the first segment if the cursor is lost
+	q.rseg, q.roff = segs[0], 0
+	if seg, off, ok := q.readCursor(); ok && seg >= segs[0] && seg <= segs[len(segs)-1] {
+		q.rseg, q.roff = seg, off
+	}
+
+	for i, seg := range segs {
+		if seg < q.rseg {
+			// consumed, but not deleted before the queue was closed
+			if err := os.Remove(q.segmentPath(seg)); err != nil {
+				return fmt.Errorf("error deleting queue segment: %w", err)
+			}
+			continue
+		}
+
+		var from int64
+		if seg == q.rseg {
+			from = q.roff
+		}
+		last := i == len(segs)-1
+		end, count, torn, err := scanSegment(q.segmentPath(seg), from)
+		if err != nil {
+			return fmt.Errorf("error reading queue segment: %w", err)
+		}
+		if torn && !last {
+			return fmt.Errorf("%w: %s at offset %d", ErrCorrupt, q.segmentPath(seg), end)
+		}
+		if torn {
+			if err := os.Truncate(q.segmentPath(seg), end); err != nil {
+				return fmt.Errorf("error truncating queue segment: %w", err)
+			}
+		}
+		if seg == q.rseg && end < q.roff {
+			// the records under the cursor were lost
+			q.roff = end
+		}
+		q.n += count
+
+		if last {
+			q.wseg, q.wsize = seg, end
+		}
+	}
+
+	q.w, err = os.OpenFile(q.segmentPath(q.wseg), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("error opening queue segment: %w", err)
+	}
+	return nil
+}
+
+// scanSegment reads the records of a segment file from an offset, returning
+// the offset following the last whole record and the number of records read.
+// Reports whether the records were followed by a torn record.
+func scanSegment(path string, from int64) (end int64, count int, torn bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if from > info.Size() {
+		return info.Size(), 0, true, nil
+	}
+
+	end = from
+	remaining := info.Size() - from
+	br := bufio.NewReader(io.NewSectionReader(f, from, remaining))
+	for {
+		payload, err := readRecord(br, remaining)
+		switch {
+		case err == io.EOF:
+			return end, count, false, nil
+		case errors.Is(err, errTorn):
+			return end, count, true, nil
+		case err != nil:
+			return end, count, false, err
+		}
+		size := int64(recordHeader + len(payload))
+		end += size
+		remaining -= size
+		count++
+	}
+}
+
+// readRecord reads a record holding no more than the given number of bytes.
+// Returns io.EOF if there is no record, and errTorn if the record is
+// incomplete or does not match its checksum.
+func readRecord(r io.Reader, remaining int64) ([]byte, error) {
+	var hdr [recordHeader]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errTorn
+		}
+		return nil, err
+	}
+
+	length := int64(binary.BigEndian.Uint32(hdr[:4]))
+	if length > remaining-recordHeader {
+		return nil, errTorn
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errTorn
+		}
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(hdr[4:]) {
+		return nil, errTorn
+	}
+	return payload, nil
+}
+
+// segments returns the numbers of the segment files of the queue in order.
+func (q *Disk[T]) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// entries are sorted by name, and so by number
+	var segs []uint64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), segmentExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		if seg, err := strconv.ParseUint(name, 10, 64); err == nil {
+			segs = append(segs, seg)
+		}
+	}
+	return segs, nil
+}
+
+func (q *Disk[T]) segmentPath(seg uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seg, segmentExt))
+}
+
+func (q *Disk[T]) createSegment(seg uint64) (*os.File, error) {
+	f, err := os.OpenFile(q.segmentPath(seg), os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error creating queue segment: %w", err)
+	}
+	return f, nil
+}
+
+// readCursor returns the segment and offset recorded in the cursor file.
+// Returns false if there is no valid cursor.
+func (q *Disk[T]) readCursor() (seg uint64, off int64, ok bool) {
+	data, err := os.ReadFile(filepath.Join(q.dir, cursorFile))
+	if err != nil || len(data) != cursorSize {
+		return 0, 0, false
+	}
+	if crc32.ChecksumIEEE(data[:16]) != binary.BigEndian.Uint32(data[16:]) {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint64(data[:8]), int64(binary.BigEndian.Uint64(data[8:16])), true
+}
+
+// writeCursor records the position of the next item to pop. The cursor is
+// replaced by renaming a new file over it, so that it is never torn.
+func (q *Disk[T]) writeCursor() error {
+	var data [cursorSize]byte
+	binary.BigEndian.PutUint64(data[:8], q.rseg)
+	binary.BigEndian.PutUint64(data[8:16], uint64(q.roff))
+	binary.BigEndian.PutUint32(data[16:], crc32.ChecksumIEEE(data[:16]))
+
+	path := filepath.Join(q.dir, cursorFile)
+	f, err := os.Create(path + ".tmp")
+	if err == nil {
+		_, err = f.Write(data[:])
+		if err == nil && q.policy != SyncNever {
+			err = f.Sync()
+		}
+		err = errors.Join(err, f.Close())
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing queue cursor: %w", err)
+	}
+	q.rdirty = false
+	return nil
+}
+
+// flush syncs the last segment, unless the policy is SyncNever, and writes
+// the cursor if items have been popped.
+func (q *Disk[T]) flush() error {
+	if q.wdirty && q.policy != SyncNever {
+		if err := q.w.Sync(); err != nil {
+			return fmt.Errorf("error syncing queue segment: %w", err)
+		}
+	}
+	q.wdirty = false
+	if q.rdirty {
+		return q.writeCursor()
+	}
+	return nil
+}
+
+// flushEvery flushes the queue at an interval until it is closed.
+func (q *Disk[T]) flushEvery(clock Clock, interval time.Duration) {
+	defer close(q.flushed)
+	for {
+		timer := clock.NewTimer(interval)
+		select {
+		case <-timer.C():
+		case <-q.done:
+			timer.Stop()
+			return
+		}
+
+		q.mu.Lock()
+		if err := q.flush(); err != nil {
+			q.err = err
+		}
+		q.mu.Unlock()
+	}
+}
+
+// Push adds an item to the back of the queue. Returns ErrClosed if the queue
+// is closed, or an error if the item cannot be encoded or written.
+func (q *Disk[T]) Push(elem T) error {
+	payload, err := q.codec.Encode(elem)
+	if err != nil {
+		return fmt.Errorf("error encoding item: %w", err)
+	}
+	rec := make([]byte, recordHeader+len(payload))
+	binary.BigEndian.PutUint32(rec[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(rec[4:8], crc32.ChecksumIEEE(payload))
+	copy(rec[recordHeader:], payload)
+
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		q.dropped()
+		return ErrClosed
+	}
+	err = q.write(rec)
+	q.mu.Unlock()
+
+	// the item may have been added even if it could not be flushed
+	q.signal()
+	return err
+}
+
+func (q *Disk[T]) write(rec []byte) error {
+	if q.wsize > 0 && q.wsize+int64(len(rec)) > q.segmentSize {
+		if err := q.roll(); err != nil {
+			q.dropped()
+			return err
+		}
+	}
+
+	if _, err := q.w.Write(rec); err != nil {
+		// drop whatever part of the record was written
+		q.w.Truncate(q.wsize)
+		q.dropped()
+		return fmt.Errorf("error writing queue segment: %w", err)
+	}
+	q.wsize += int64(len(rec))
+	q.wdirty = true
+	q.n++
+	if q.hooks != nil {
+		q.stamps.PushBack(q.stamp())
+	}
+	q.pushed()
+
+	if q.policy == SyncAlways {
+		return q.flush()
+	}
+	return nil
+}
+
+// roll starts a new last segment.
+func (q *Disk[T]) roll() error {
+	if err := q.flush(); err != nil {
+		return err
+	}
+	if err := q.w.Close(); err != nil {
+		return fmt.Errorf("error closing queue segment: %w", err)
+	}
+	if q.rseg == q.wseg {
+		q.rend = q.wsize
+	}
+
+	f, err := q.createSegment(q.wseg + 1)
+	if err != nil {
+		return err
+	}
+	q.w, q.wseg, q.wsize = f, q.wseg+1, 0
+	return nil
+}
+
+// PushCtx adds an item to the back of the queue. Since the queue is
+// unbounded this never blocks, but the item is not added, and the error of
+// the context is returned, if the context is already done.
+func (q *Disk[T]) PushCtx(ctx context.Context, elem T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return q.Push(elem)
+}
+
+// TryPush adds an item to the back of the queue. Returns false if the queue
+// is closed or the item cannot be written.
+func (q *Disk[T]) TryPush(elem T) bool {
+	return q.Push(elem) == nil
+}
+
+// Pop removes and returns the item at the front of the queue, blocking while
+// the queue is empty. Once the queue is closed, Pop continues to return the
+// remaining items and then returns ErrClosed. Returns an error if the item
+// cannot be read or decoded; an item that cannot be decoded is removed.
+func (q *Disk[T]) Pop() (T, error) {
+	return q.PopCtx(context.Background())
+}
+
+// PopCtx removes and returns the item at the front of the queue, blocking
+// while the queue is empty. Returns the error of the context if it is done
+// before an item is available. A closed queue behaves as for Pop.
+func (q *Disk[T]) PopCtx(ctx context.Context) (T, error) {
+	var start int64
+	defer func() { q.blocked(start) }()
+	for {
+		payload, ok, closed, err := q.pop()
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		if ok {
+			return q.decode(payload)
+		}
+		if closed {
+			var zero T
+			return zero, ErrClosed
+		}
+		start = q.waiting(start)
+		if err := q.wait(ctx); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
+
+// TryPop removes and returns the item at the front of the queue if there is
+// one. Returns false if the queue is empty, or the item cannot be read or
+// decoded.
+func (q *Disk[T]) TryPop() (T, bool) {
+	elem, ok, err := q.tryPop()
+	return elem, ok && err == nil
+}
+
+// tryPop removes and returns the item at the front of the queue if there is
+// one, as TryPop does, but returns the error if the item cannot be read or
+// decoded.
+func (q *Disk[T]) tryPop() (T, bool, error) {
+	payload, ok, _, err := q.pop()
+	if !ok || err != nil {
+		var zero T
+		return zero, false, err
+	}
+	elem, err := q.decode(payload)
+	return elem, err == nil, err
+}
+
+func (q *Disk[T]) decode(payload []byte) (T, error) {
+	elem, err := q.codec.Decode(payload)
+	if err != nil {
+		return elem, fmt.Errorf("error decoding item: %w", err)
+	}
+	return elem, nil
+}
+
+func (q *Disk[T]) pop() (payload []byte, ok bool, closed bool, err error) {
+	q.mu.Lock()
+	closed = q.closed
+	if q.n == 0 {
+		if closed {
+			err = q.release()
+		}
+		q.mu.Unlock()
+		return nil, false, closed, err
+	}
+
+	payload, err = q.read()
+	if err == nil && (q.policy == SyncAlways || closed) {
+		// the item has been popped even if the cursor is not recorded; it
+		// is popped again after a crash
+		if ferr := q.flush(); ferr != nil {
+			q.err = ferr
+		}
+	}
+	more := q.n > 0
+	q.mu.Unlock()
+
+	// pass the token on to the next blocked popper
+	if more {
+		q.signal()
+	}
+	return payload, err == nil, closed, err
+}
+
+// read reads the record at the front of the queue, deleting the segments
+// that have been consumed on the way.
+func (q *Disk[T]) read() ([]byte, error) {
+	var end int64
+	for {
+		if q.r == nil {
+			f, err := os.Open(q.segmentPath(q.rseg))
+			if err != nil {
+				return nil, fmt.Errorf("error opening queue segment: %w", err)
+			}
+			q.r = f
+			if q.rseg < q.wseg {
+				info, err := f.Stat()
+				if err != nil {
+					return nil, fmt.Errorf("error opening queue segment: %w", err)
+				}
+				q.rend = info.Size()
+			}
+		}
+
+		end = q.wsize
+		if q.rseg < q.wseg {
+			end = q.rend
+		}
+		if q.roff < end {
+			break
+		}
+
+		// the first segment is consumed; move the cursor past it before
+		// deleting it
+		q.r.Close()
+		q.r = nil
+		consumed := q.rseg
+		q.rseg, q.roff = q.rseg+1, 0
+		if err := q.writeCursor(); err != nil {
+			return nil, err
+		}
+		if err := os.Remove(q.segmentPath(consumed)); err != nil {
+			return nil, fmt.Errorf("error deleting queue segment: %w", err)
+		}
+	}
+
+	payload, err := readRecord(io.NewSectionReader(q.r, q.roff, end-q.roff), end-q.roff)
+	if err == io.EOF || errors.Is(err, errTorn) {
+		return nil, fmt.Errorf("%w: %s at offset %d", ErrCorrupt, q.segmentPath(q.rseg), q.roff)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading queue segment: %w", err)
+	}
+	q.roff += int64(recordHeader + len(payload))
+	q.rdirty = true
+	q.n--
+
+	var stamp int64
+	if q.untimed > 0 {
+		q.untimed--
+	} else {
+		stamp, _ = q.stamps.PopFront()
+	}
+	q.popped(stamp)
+	return payload, nil
+}
+
+// release closes the first segment once a closed queue has been drained.
+func (q *Disk[T]) release() error {
+	err := q.flush()
+	if q.r != nil {
+		err = errors.Join(err, q.r.Close())
+		q.r = nil
+	}
+	return err
+}
+
+// Len returns the number of items in the queue.
+func (q *Disk[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.n
+}
+
+// Close closes the queue, flushing its files. Future calls to Push fail with
+// ErrClosed, and blocked calls to Pop return ErrClosed once no items are
+// left. Items that are not popped stay in the directory for the queue to be
+// opened again. Returns the error of any flush of the queue that failed.
+// Closing a queue more than once has no effect.
+func (q *Disk[T]) Close() error {
+	q.close()
+	if q.flushed != nil {
+		<-q.flushed
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.w == nil {
+		return nil
+	}
+
+	err := errors.Join(q.err, q.flush(), q.w.Close())
+	q.w, q.err = nil, nil
+	if q.r != nil {
+		err = errors.Join(err, q.r.Close())
+		q.r = nil
+	}
+	return err
+}
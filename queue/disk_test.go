@@ -0,0 +1,293 @@
+package queue
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// openDisk opens a Disk queue of ints that is closed when the test ends.
+func openDisk(t *testing.T, dir string, opts ...Option) *Disk[int] {
+	q, err := OpenDisk[int](dir, opts...)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+// segmentFiles returns the names of the segment files in a directory.
+func segmentFiles(t *testing.T, dir string) []string {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	assert.NoError(t, err)
+	for i, n := range names {
+		names[i] = filepath.Base(n)
+	}
+	return names
+}
+
+func TestDiskReopen(t *testing.T) {
+
+	dir := t.TempDir()
+	q := openDisk(t, dir)
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, q.Push(i))
+	}
+	for _, exp := range []int{1, 2} {
+		got, err := q.Pop()
+		assert.NoError(t, err)
+		assert.Equal(t, exp, got)
+	}
+	assert.NoError(t, q.Close())
+	assert.NoError(t, q.Close(), "close twice")
+
+	q = openDisk(t, dir)
+	assert.Equal(t, 3, q.Len())
+	assert.NoError(t, q.Push(6))
+	for _, exp := range []int{3, 4, 5, 6} {
+		got, ok := q.TryPop()
+		assert.True(t, ok)
+		assert.Equal(t, exp, got)
+	}
+	_, ok := q.TryPop()
+	assert.False(t, ok)
+}
+
+func TestDiskSegments(t *testing.T) {
+
+	// each record of a small int takes 9 or 10 bytes, so a segment holds 3
+	dir := t.TempDir()
+	q := openDisk(t, dir, WithSegmentSize(30))
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, q.Push(i))
+	}
+	assert.Len(t, segmentFiles(t, dir), 4)
+
+	for i := 0; i < 7; i++ {
+		got, err := q.Pop()
+		assert.NoError(t, err)
+		assert.Equal(t, i, got)
+	}
+	assert.Equal(t, []string{"00000000000000000002.seg", "00000000000000000003.seg"}, segmentFiles(t, dir))
+
+	// the cursor survives reopening part way through a segment
+	assert.NoError(t, q.Close())
+	q = openDisk(t, dir, WithSegmentSize(30))
+	assert.Equal(t, 3, q.Len())
+	for i := 7; i < 10; i++ {
+		got, err := q.Pop()
+		assert.NoError(t, err)
+		assert.Equal(t, i, got)
+	}
+	assert.Equal(t, []string{"00000000000000000003.seg"}, segmentFiles(t, dir))
+}
+
+func TestDiskTornWrite(t *testing.T) {
+
+	var tests = map[string]struct {
+		tail []byte
+	}{
+		"partial header": {
+			tail: []byte{0, 0},
+		},
+		"partial payload": {
+			tail: []byte{0, 0, 0, 3, 0, 0, 0, 0, '1'},
+		},
+		"bad checksum": {
+			tail: []byte{0, 0, 0, 1, 0, 0, 0, 0, '1'},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			q := openDisk(t, dir)
+			assert.NoError(t, q.Push(1))
+			assert.NoError(t, q.Push(2))
+			assert.NoError(t, q.Close())
+
+			path := filepath.Join(dir, segmentFiles(t, dir)[0])
+			info, err := os.Stat(path)
+			assert.NoError(t, err)
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+			assert.NoError(t, err)
+			_, err = f.Write(tt.tail)
+			assert.NoError(t, err)
+			assert.NoError(t, f.Close())
+
+			q = openDisk(t, dir)
+			assert.Equal(t, 2, q.Len())
+			after, err := os.Stat(path)
+			assert.NoError(t, err)
+			assert.Equal(t, info.Size(), after.Size(), "torn record is truncated")
+
+			assert.NoError(t, q.Push(3))
+			for _, exp := range []int{1, 2, 3} {
+				got, err := q.Pop()
+				assert.NoError(t, err)
+				assert.Equal(t, exp, got)
+			}
+		})
+	}
+}
+
+func TestDiskCorrupt(t *testing.T) {
+
+	dir := t.TempDir()
+	q := openDisk(t, dir, WithSegmentSize(10))
+	assert.NoError(t, q.Push(1))
+	assert.NoError(t, q.Push(2))
+	assert.NoError(t, q.Close())
+
+	// a bad record in a segment other than the last cannot be a torn write
+	path := filepath.Join(dir, segmentFiles(t, dir)[0])
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	data[len(data)-1]++
+	assert.NoError(t, os.WriteFile(path, data, 0o644))
+
+	_, err = OpenDisk[int](dir)
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+// stringCodec stores strings as their bytes.
+type stringCodec struct{}
+
+func (stringCodec) Encode(s string) ([]byte, error) {
+	return []byte(s), nil
+}
+
+func (stringCodec) Decode(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", errors.New("empty")
+	}
+	return string(data), nil
+}
+
+func TestDiskCodec(t *testing.T) {
+
+	dir := t.TempDir()
+	q, err := OpenDisk[string](dir, WithCodec[string](stringCodec{}))
+	assert.NoError(t, err)
+	defer q.Close()
+
+	assert.NoError(t, q.Push("abc"))
+	assert.NoError(t, q.Push(""))
+
+	got, err := q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", got)
+	_, err = q.Pop()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "error decoding item")
+	}
+	assert.Equal(t, 0, q.Len())
+
+	data, err := os.ReadFile(filepath.Join(dir, segmentFiles(t, dir)[0]))
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", string(data[recordHeader:recordHeader+3]))
+
+	_, err = OpenDisk[int](t.TempDir(), WithCodec[string](stringCodec{}))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not match the item type")
+	}
+}
+
+func TestDiskSync(t *testing.T) {
+
+	var tests = map[string]struct {
+		policy  SyncPolicy
+		advance bool
+		expLen  int
+	}{
+		"always": {
+			policy: SyncAlways,
+			expLen: 2,
+		},
+		"interval before flush": {
+			policy: SyncInterval,
+			expLen: 3,
+		},
+		"interval after flush": {
+			policy:  SyncInterval,
+			advance: true,
+			expLen:  2,
+		},
+		"never": {
+			policy: SyncNever,
+			expLen: 3,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := newFakeClock()
+			dir := t.TempDir()
+			q := openDisk(t, dir, WithSync(tt.policy, time.Second), WithClock(c))
+			for i := 0; i < 3; i++ {
+				assert.NoError(t, q.Push(i))
+			}
+			_, err := q.Pop()
+			assert.NoError(t, err)
+
+			if tt.advance {
+				c.BlockUntil(c.Now().Add(time.Second))
+				c.Advance(time.Second)
+				// the next timer is started once the flush is done
+				c.BlockUntil(c.Now().Add(time.Second))
+			}
+
+			// open the directory again without closing the queue, as after
+			// a crash; pushes survive, and pops not yet recorded are repeated
+			crashed := openDisk(t, dir)
+			assert.Equal(t, tt.expLen, crashed.Len())
+			got, err := crashed.Pop()
+			assert.NoError(t, err)
+			assert.Equal(t, 3-tt.expLen, got)
+		})
+	}
+}
+
+func TestDiskCloseRecordsCursor(t *testing.T) {
+
+	dir := t.TempDir()
+	q := openDisk(t, dir, WithSync(SyncNever, 0))
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, q.Push(i))
+	}
+	_, err := q.Pop()
+	assert.NoError(t, err)
+	assert.NoError(t, q.Close())
+
+	// pops after closing drain the queue and are recorded too
+	got, err := q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, got)
+
+	q = openDisk(t, dir)
+	assert.Equal(t, 2, q.Len())
+	got, err = q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, got)
+}
+
+func TestDiskManyItems(t *testing.T) {
+
+	dir := t.TempDir()
+	q := openDisk(t, dir, WithSync(SyncNever, 0), WithSegmentSize(1<<10))
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, q.Push(i))
+	}
+	for i := 0; i < 1000; i++ {
+		got, err := q.Pop()
+		assert.NoError(t, err)
+		if got != i {
+			t.Fatalf("popped %d, expected %d", got, i)
+		}
+	}
+	assert.Len(t, segmentFiles(t, dir), 1)
+}
@@ -0,0 +1,158 @@
+package queue
+
+import "context"
+
+// FIFO is an unbounded first-in first-out queue backed by a ring buffer. The
+// buffer doubles in size when it is full and halves when it is no more than
+// a quarter full, so that Push and Pop take amortized constant time.
+//
+// A FIFO is not safe for concurrent use; it is the fast path for use within a
+// single goroutine. Use BlockingFIFO to share a queue between goroutines.
+//
+// The zero value is an empty queue ready to use.
+type FIFO[T any] struct {
+	items Deque[T]
+}
+
+// NewFIFO creates and returns an empty FIFO.
+func NewFIFO[T any]() *FIFO[T] {
+	return &FIFO[T]{}
+}
+
+// Push adds an item to the back of the queue.
+func (q *FIFO[T]) Push(elem T) {
+	q.items.PushBack(elem)
+}
+
+// Pop removes and returns the item at the front of the queue. Returns false
+// if the queue is empty.
+func (q *FIFO[T]) Pop() (T, bool) {
+	return q.items.PopFront()
+}
+
+// Peek returns the item at the front of the queue without removing it.
+// Returns false if the queue is empty.
+func (q *FIFO[T]) Peek() (T, bool) {
+	return q.items.Front()
+}
+
+// Len returns the number of items in the queue.
+func (q *FIFO[T]) Len() int {
+	return q.items.Len()
+}
+
+// BlockingFIFO is an unbounded first-in first-out queue that is safe for
+// concurrent use. Push never blocks, while Pop blocks until an item is
+// available or the queue is closed.
+type BlockingFIFO[T any] struct {
+	gate
+	observer
+	items FIFO[entry[T]]
+}
+
+// NewBlockingFIFO creates and returns an empty BlockingFIFO. The queue is
+// observed by the hooks given with WithHooks.
+func NewBlockingFIFO[T any](opts ...Option) *BlockingFIFO[T] {
+	o := newOptions(opts)
+	return &BlockingFIFO[T]{gate: newGate(), observer: observer{o.hooks}}
+}
+
+// Push adds an item to the back of the queue. Returns ErrClosed if the queue
+// is closed.
+func (q *BlockingFIFO[T]) Push(elem T) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		q.dropped()
+		return ErrClosed
+	}
+	q.items.Push(entry[T]{elem: elem, stamp: q.stamp()})
+	q.mu.Unlock()
+
+	q.pushed()
+	q.signal()
+	return nil
+}
+
+// PushCtx adds an item to the back of the queue. Since the queue is
+// unbounded this never blocks, but the item is not added, and the error of
+// the context is returned, if the context is already done.
+func (q *BlockingFIFO[T]) PushCtx(ctx context.Context, elem T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return q.Push(elem)
+}
+
+// TryPush adds an item to the back of the queue. Since the queue is
+// unbounded, this only fails if the queue is closed.
+func (q *BlockingFIFO[T]) TryPush(elem T) bool {
+	return q.Push(elem) == nil
+}
+
+// Pop removes and returns the item at the front of the queue, blocking while
+// the queue is empty. Once the queue is closed, Pop continues to return the
+// remaining items and then returns ErrClosed.
+func (q *BlockingFIFO[T]) Pop() (T, error) {
+	return q.PopCtx(context.Background())
+}
+
+// PopCtx removes and returns the item at the front of the queue, blocking
+// while the queue is empty. Returns the error of the context if it is done
+// before an item is available. A closed queue behaves as for Pop.
+func (q *BlockingFIFO[T]) PopCtx(ctx context.Context) (T, error) {
+	var start int64
+	defer func() { q.blocked(start) }()
+	for {
+		elem, ok, closed := q.pop()
+		if ok {
+			return elem, nil
+		}
+		if closed {
+			return elem, ErrClosed
+		}
+		start = q.waiting(start)
+		if err := q.wait(ctx); err != nil {
+			return elem, err
+		}
+	}
+}
+
+// TryPop removes and returns the item at the front of the queue if there is
+// one. Returns false if the queue is empty.
+func (q *BlockingFIFO[T]) TryPop() (T, bool) {
+	elem, ok, _ := q.pop()
+	return elem, ok
+}
+
+func (q *BlockingFIFO[T]) pop() (elem T, ok bool, closed bool) {
+	q.mu.Lock()
+	e, ok := q.items.Pop()
+	more := q.items.Len() > 0
+	closed = q.closed
+	q.mu.Unlock()
+
+	if ok {
+		q.popped(e.stamp)
+	}
+	// pass the token on to the next blocked popper
+	if more {
+		q.signal()
+	}
+	return e.elem, ok, closed
+}
+
+// Len returns the number of items in the queue.
+func (q *BlockingFIFO[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+// Close closes the queue. Future calls to Push fail with ErrClosed, and
+// blocked calls to Pop return ErrClosed once no items are left. Closing a
+// queue more than once has no effect.
+func (q *BlockingFIFO[T]) Close() error {
+	q.close()
+	return nil
+}
@@ -0,0 +1,213 @@
+package queue
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFIFO(t *testing.T) {
+
+	var tests = map[string]struct {
+		ops    []int // positive values are pushed, zero pops
+		expPop []int
+		expLen int
+		expCap int
+	}{
+		"empty": {
+			ops:    []int{0},
+			expPop: []int{},
+		},
+		"fifo order": {
+			ops:    []int{1, 2, 3, 0, 0, 0},
+			expPop: []int{1, 2, 3},
+			expCap: minDequeCap,
+		},
+		"wrap around": {
+			ops:    []int{1, 2, 3, 4, 5, 6, 0, 0, 0, 0, 7, 8, 9, 10, 0, 0, 0},
+			expPop: []int{1, 2, 3, 4, 5, 6, 7},
+			expLen: 3,
+			expCap: minDequeCap,
+		},
+		"grow while wrapped": {
+			ops:    []int{1, 2, 3, 4, 5, 6, 0, 0, 7, 8, 9, 10, 11, 0},
+			expPop: []int{1, 2, 3},
+			expLen: 8,
+			expCap: 2 * minDequeCap,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var q FIFO[int]
+			gotPop := []int{}
+			for _, op := range tt.ops {
+				if op != 0 {
+					q.Push(op)
+					continue
+				}
+				if v, ok := q.Pop(); ok {
+					gotPop = append(gotPop, v)
+				}
+			}
+
+			assert.Equal(t, tt.expPop, gotPop)
+			assert.Equal(t, tt.expLen, q.Len())
+			assert.Equal(t, tt.expCap, len(q.items.buf))
+		})
+	}
+}
+
+func TestFIFOGrowShrink(t *testing.T) {
+
+	q := NewFIFO[int]()
+	for i := 0; i < 1000; i++ {
+		q.Push(i)
+	}
+	assert.Equal(t, 1000, q.Len())
+	assert.Equal(t, 1024, len(q.items.buf))
+
+	front, ok := q.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, 0, front)
+
+	for i := 0; i < 990; i++ {
+		v, ok := q.Pop()
+		assert.True(t, ok)
+		assert.Equal(t, i, v)
+	}
+	assert.Equal(t, 10, q.Len())
+	assert.Equal(t, 32, len(q.items.buf))
+
+	for i := 990; i < 1000; i++ {
+		v, _ := q.Pop()
+		assert.Equal(t, i, v)
+	}
+	_, ok = q.Peek()
+	assert.False(t, ok)
+	assert.Equal(t, minDequeCap, len(q.items.buf))
+}
+
+func TestFIFOReleasesItems(t *testing.T) {
+
+	q := NewFIFO[*int]()
+	v := 1
+	q.Push(&v)
+	q.Pop()
+
+	for _, elem := range q.items.buf {
+		assert.Nil(t, elem)
+	}
+}
+
+func TestBlockingFIFO(t *testing.T) {
+
+	q := NewBlockingFIFO[int]()
+
+	_, ok := q.TryPop()
+	assert.False(t, ok)
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, q.Push(i))
+	}
+	assert.True(t, q.TryPush(100))
+	assert.Equal(t, 101, q.Len())
+
+	for i := 0; i < 50; i++ {
+		v, err := q.Pop()
+		assert.NoError(t, err)
+		assert.Equal(t, i, v)
+	}
+
+	v, ok := q.TryPop()
+	assert.True(t, ok)
+	assert.Equal(t, 50, v)
+
+	assert.NoError(t, q.Close())
+	assert.ErrorIs(t, q.Push(1), ErrClosed)
+	assert.False(t, q.TryPush(1))
+
+	for i := 51; i <= 100; i++ {
+		v, err := q.Pop()
+		assert.NoError(t, err)
+		assert.Equal(t, i, v)
+	}
+	_, err := q.Pop()
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestBlockingFIFOPopBlocks(t *testing.T) {
+
+	q := NewBlockingFIFO[int]()
+
+	popped := make(chan int)
+	go func() {
+		v, _ := q.Pop()
+		popped <- v
+	}()
+
+	select {
+	case <-popped:
+		t.Fatal("pop from empty queue did not block")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	assert.NoError(t, q.Push(3))
+	assert.Equal(t, 3, <-popped)
+
+	closed := make(chan error)
+	go func() {
+		_, err := q.Pop()
+		closed <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	q.Close()
+	assert.ErrorIs(t, <-closed, ErrClosed)
+}
+
+func TestBlockingFIFOConcurrent(t *testing.T) {
+
+	const producers, consumers, items = 4, 4, 1000
+
+	q := NewBlockingFIFO[int]()
+
+	var prod sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		prod.Add(1)
+		go func() {
+			defer prod.Done()
+			for i := 0; i < items; i++ {
+				assert.NoError(t, q.Push(i))
+			}
+		}()
+	}
+
+	var mu sync.Mutex
+	sum, count := 0, 0
+	var cons sync.WaitGroup
+	for c := 0; c < consumers; c++ {
+		cons.Add(1)
+		go func() {
+			defer cons.Done()
+			for {
+				v, err := q.Pop()
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				sum += v
+				count++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	prod.Wait()
+	q.Close()
+	cons.Wait()
+
+	assert.Equal(t, producers*items, count)
+	assert.Equal(t, producers*items*(items-1)/2, sum)
+}
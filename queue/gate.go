@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// gate coordinates the goroutines blocked on a queue whose items are guarded
+// by the mutex of the gate. Producers signal the gate after adding items, and
+// consumers that find no items wait on it.
+type gate struct {
+	mu     sync.Mutex
+	closed bool
+
+	// ready holds a token while items may be available to blocked consumers
+	ready chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newGate() gate {
+	return gate{
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+}
+
+// signal wakes one blocked consumer, or the next consumer to wait if none is
+// blocked.
+func (g *gate) signal() {
+	select {
+	case g.ready <- struct{}{}:
+	default:
+	}
+}
+
+// wait blocks until the gate is signalled or closed, or the context is done,
+// in which case the error of the context is returned.
+func (g *gate) wait(ctx context.Context) error {
+	select {
+	case <-g.ready:
+		return nil
+	case <-g.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitDue is wait for queues that schedule their items. It also returns once
+// the given delay has passed on the clock, unless the delay is negative. Once
+// the gate is closed, it waits only for a signal or the delay.
+func (g *gate) waitDue(ctx context.Context, clock Clock, next time.Duration, closed bool) error {
+	var due <-chan time.Time
+	if next >= 0 {
+		timer := clock.NewTimer(next)
+		defer timer.Stop()
+		due = timer.C()
+	}
+
+	var done <-chan struct{}
+	if !closed {
+		done = g.done
+	}
+
+	select {
+	case <-due:
+	case <-g.ready:
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// close marks the gate closed and wakes all blocked consumers. Closing a gate
+// more than once has no effect.
+func (g *gate) close() {
+	g.once.Do(func() {
+		g.mu.Lock()
+		g.closed = true
+		g.mu.Unlock()
+		close(g.done)
+	})
+}
@@ -0,0 +1,229 @@
+package queue
+
+import (
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+// Hooks receives the events of a queue, to observe it. A queue is given its
+// hooks with WithHooks. The methods are called in the goroutine causing the
+// event, possibly while it holds the lock of the queue, so they must be
+// quick, must not call the queue, and must be safe for concurrent use.
+// Metrics is the built-in implementation.
+type Hooks interface {
+	// OnPush is called when an item is added to the queue, including when a
+	// Work queue returns an item whose lease was not acknowledged.
+	OnPush()
+	// OnPop is called when an item is removed from the queue, by a pop or
+	// by Remove on a BlockingPriority, or leased from a Work queue, with the
+	// time since it was pushed.
+	OnPop(latency time.Duration)
+	// OnBlock is called when a push or pop that had to wait returns, with
+	// the time it waited, whether or not it succeeded.
+	OnBlock(waited time.Duration)
+	// OnDrop is called when an item is not added because the queue is full
+	// or closed, and when a Work queue dead-letters an item.
+	OnDrop()
+}
+
+// WithHooks sets the hooks that observe a queue. It applies to every queue
+// that is safe for concurrent use.
+func WithHooks(h Hooks) Option {
+	return func(o *options) {
+		o.hooks = h
+	}
+}
+
+// epoch is the origin of the stamps of an observer, which are read from the
+// monotonic clock.
+var epoch = time.Now()
+
+// observer calls the hooks of a queue, if it has any. Items are stamped with
+// the time they are pushed to measure their latency; without hooks the stamp
+// is zero and no time is read.
+type observer struct {
+	hooks Hooks
+}
+
+// stamp returns the current time as a stamp, or zero if there are no hooks.
+func (o observer) stamp() int64 {
+	if o.hooks == nil {
+		return 0
+	}
+	return int64(time.Since(epoch))
+}
+
+func (o observer) pushed() {
+	if o.hooks != nil {
+		o.hooks.OnPush()
+	}
+}
+
+// popped reports an item popped that was stamped when it was pushed. An item
+// with no stamp is reported with zero latency.
+func (o observer) popped(stamp int64) {
+	if o.hooks == nil {
+		return
+	}
+	var latency time.Duration
+	if stamp != 0 {
+		latency = time.Since(epoch) - time.Duration(stamp)
+	}
+	o.hooks.OnPop(latency)
+}
+
+// blocked reports a wait that started at the given stamp. A zero stamp means
+// there was no wait.
+func (o observer) blocked(stamp int64) {
+	if o.hooks != nil && stamp != 0 {
+		o.hooks.OnBlock(time.Since(epoch) - time.Duration(stamp))
+	}
+}
+
+// waiting returns the stamp of a wait starting now, unless one has already
+// started.
+func (o observer) waiting(stamp int64) int64 {
+	if stamp != 0 {
+		return stamp
+	}
+	return o.stamp()
+}
+
+func (o observer) dropped() {
+	if o.hooks != nil {
+		o.hooks.OnDrop()
+	}
+}
+
+// entry is an item stamped with the time it was pushed.
+type entry[T any] struct {
+	elem  T
+	stamp int64
+}
+
+// latencyBounds are the upper bounds of the buckets of the latency histogram
+// of Metrics. Latencies above the last bound fall in a final bucket.
+var latencyBounds = [...]time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+}
+
+// Metrics is the Hooks that counts the events of a queue with atomic
+// counters, cheap enough to leave enabled. Counters can be read with Snapshot
+// and exported through expvar with Publish. A Metrics may observe more than
+// one queue, in which case it counts their events together.
+//
+// The zero value is ready to use.
+type Metrics struct {
+	pushed  atomic.Int64
+	popped  atomic.Int64
+	dropped atomic.Int64
+	blocks  atomic.Int64
+	blocked atomic.Int64 // nanoseconds
+
+	latency    [len(latencyBounds) + 1]atomic.Int64
+	latencySum atomic.Int64 // nanoseconds
+}
+
+// OnPush counts a push.
+func (m *Metrics) OnPush() {
+	m.pushed.Add(1)
+}
+
+// OnPop counts a pop and its latency.
+func (m *Metrics) OnPop(latency time.Duration) {
+	m.popped.Add(1)
+	i := 0
+	for i < len(latencyBounds) && latency > latencyBounds[i] {
+		i++
+	}
+	m.latency[i].Add(1)
+	m.latencySum.Add(int64(latency))
+}
+
+// OnBlock counts a wait and the time spent in it.
+func (m *Metrics) OnBlock(waited time.Duration) {
+	m.blocks.Add(1)
+	m.blocked.Add(int64(waited))
+}
+
+// OnDrop counts a dropped item.
+func (m *Metrics) OnDrop() {
+	m.dropped.Add(1)
+}
+
+// MetricsSnapshot holds the counters of a Metrics at one time. Durations are
+// in nanoseconds when encoded as JSON.
+type MetricsSnapshot struct {
+	// Len is the number of items pushed and not yet popped.
+	Len int64 `json:"len"`
+	// Pushed, Popped and Dropped count items; together with the time
+	// between snapshots they give the throughput of the queue.
+	Pushed  int64 `json:"pushed"`
+	Popped  int64 `json:"popped"`
+	Dropped int64 `json:"dropped"`
+	// Blocks counts the pushes and pops that waited, and Blocked is the
+	// total time they waited.
+	Blocks  int64         `json:"blocks"`
+	Blocked time.Duration `json:"blocked"`
+	// Latency is the histogram of the time from push to pop.
+	Latency LatencyHistogram `json:"latency"`
+}
+
+// LatencyHistogram counts latencies in buckets. Each bucket counts the
+// latencies no greater than its bound and greater than the bound of the
+// bucket before it; the last bucket has no bound.
+type LatencyHistogram struct {
+	Buckets []LatencyBucket `json:"buckets"`
+	// Sum is the total of all latencies, and with the count of pops gives
+	// their mean.
+	Sum time.Duration `json:"sum"`
+}
+
+// LatencyBucket is a bucket of a LatencyHistogram. The bound of the last
+// bucket is zero, standing for no bound.
+type LatencyBucket struct {
+	Bound time.Duration `json:"bound"`
+	Count int64         `json:"count"`
+}
+
+// Snapshot returns the current value of the counters. The counters are read
+// one by one, so a snapshot taken while the queue is in use may be slightly
+// inconsistent.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	s := MetricsSnapshot{
+		Pushed:  m.pushed.Load(),
+		Popped:  m.popped.Load(),
+		Dropped: m.dropped.Load(),
+		Blocks:  m.blocks.Load(),
+		Blocked: time.Duration(m.blocked.Load()),
+		Latency: LatencyHistogram{
+			Buckets: make([]LatencyBucket, len(m.latency)),
+			Sum:     time.Duration(m.latencySum.Load()),
+		},
+	}
+	s.Len = s.Pushed - s.Popped
+	for i := range m.latency {
+		if i < len(latencyBounds) {
+			s.Latency.Buckets[i].Bound = latencyBounds[i]
+		}
+		s.Latency.Buckets[i].Count = m.latency[i].Load()
+	}
+	return s
+}
+
+// Publish exports the snapshots of the metrics through expvar under the given
+// name. Like expvar.Publish, it panics if the name is already in use.
+func (m *Metrics) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return m.Snapshot()
+	}))
+}
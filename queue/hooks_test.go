@@ -0,0 +1,181 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+
+	var m Metrics
+	m.OnPush()
+	m.OnPush()
+	m.OnPush()
+	for _, latency := range []time.Duration{
+		500 * time.Nanosecond,
+		time.Microsecond,
+		2 * time.Millisecond,
+		2 * time.Minute,
+	} {
+		m.OnPop(latency)
+	}
+	m.OnBlock(time.Second)
+	m.OnBlock(2 * time.Second)
+	m.OnDrop()
+
+	s := m.Snapshot()
+	assert.Equal(t, int64(-1), s.Len)
+	assert.Equal(t, int64(3), s.Pushed)
+	assert.Equal(t, int64(4), s.Popped)
+	assert.Equal(t, int64(1), s.Dropped)
+	assert.Equal(t, int64(2), s.Blocks)
+	assert.Equal(t, 3*time.Second, s.Blocked)
+	assert.Equal(t, 2*time.Minute+2*time.Millisecond+1500*time.Nanosecond, s.Latency.Sum)
+
+	counts := make([]int64, len(s.Latency.Buckets))
+	for i, b := range s.Latency.Buckets {
+		counts[i] = b.Count
+	}
+	assert.Equal(t, []int64{2, 0, 0, 0, 1, 0, 0, 0, 0, 1}, counts)
+	assert.Equal(t, 10*time.Millisecond, s.Latency.Buckets[4].Bound)
+	assert.Equal(t, time.Duration(0), s.Latency.Buckets[9].Bound)
+}
+
+func TestMetricsPublish(t *testing.T) {
+
+	var m Metrics
+	m.OnPush()
+	// expvar names are global, so make one unique to this run of the test
+	name := fmt.Sprintf("queue_test_metrics_%p", &m)
+	m.Publish(name)
+
+	var got MetricsSnapshot
+	assert.NoError(t, json.Unmarshal([]byte(expvar.Get(name).String()), &got))
+	assert.Equal(t, int64(1), got.Pushed)
+	assert.Equal(t, int64(1), got.Len)
+
+	assert.Panics(t, func() { m.Publish(name) })
+}
+
+func TestHooks(t *testing.T) {
+
+	var tests = map[string]func(opts ...Option) Blocking[int]{
+		"queue": func(opts ...Option) Blocking[int] {
+			return New[int](1, opts...)
+		},
+		"blocking fifo": func(opts ...Option) Blocking[int] {
+			return NewBlockingFIFO[int](opts...)
+		},
+		"blocking priority": func(opts ...Option) Blocking[int] {
+			return NewBlockingPriority(func(a, b int) bool { return a < b }, opts...)
+		},
+		"delayed": func(opts ...Option) Blocking[int] {
+			return NewDelayed[int](opts...)
+		},
+		"disk": func(opts ...Option) Blocking[int] {
+			return openDisk(t, t.TempDir(), opts...)
+		},
+	}
+
+	for name, newQueue := range tests {
+		t.Run(name, func(t *testing.T) {
+			var m Metrics
+			q := newQueue(WithHooks(&m))
+
+			assert.NoError(t, q.Push(1))
+			time.Sleep(time.Millisecond)
+			got, err := q.Pop()
+			assert.NoError(t, err)
+			assert.Equal(t, 1, got)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			_, err = q.PopCtx(ctx)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+			assert.NoError(t, q.Close())
+			assert.ErrorIs(t, q.Push(2), ErrClosed)
+
+			s := m.Snapshot()
+			assert.Equal(t, int64(0), s.Len)
+			assert.Equal(t, int64(1), s.Pushed)
+			assert.Equal(t, int64(1), s.Popped)
+			assert.Equal(t, int64(1), s.Dropped)
+			assert.Equal(t, int64(1), s.Blocks)
+			assert.GreaterOrEqual(t, s.Blocked, 10*time.Millisecond)
+			assert.GreaterOrEqual(t, s.Latency.Sum, time.Millisecond)
+		})
+	}
+}
+
+func TestHooksPriorityRemove(t *testing.T) {
+
+	var m Metrics
+	q := NewBlockingPriority(func(a, b int) bool { return a < b }, WithHooks(&m))
+
+	h1, err := q.PushHandle(1)
+	assert.NoError(t, err)
+	h2, err := q.PushHandle(2)
+	assert.NoError(t, err)
+	assert.True(t, q.Update(h2, 0))
+	assert.True(t, q.Remove(h1))
+	assert.False(t, q.Remove(h1))
+
+	s := m.Snapshot()
+	assert.Equal(t, int64(q.Len()), s.Len)
+	assert.Equal(t, int64(2), s.Pushed)
+	assert.Equal(t, int64(1), s.Popped)
+
+	_, err = q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), m.Snapshot().Len)
+}
+
+func TestHooksQueueFull(t *testing.T) {
+
+	var m Metrics
+	q := New[int](1, WithHooks(&m))
+	assert.True(t, q.TryPush(1))
+	assert.False(t, q.TryPush(2))
+
+	pushed := make(chan error)
+	go func() {
+		pushed <- q.Push(3)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	q.Pop()
+	assert.NoError(t, <-pushed)
+
+	s := m.Snapshot()
+	assert.Equal(t, int64(2), s.Pushed)
+	assert.Equal(t, int64(1), s.Dropped)
+	assert.Equal(t, int64(1), s.Blocks)
+	assert.GreaterOrEqual(t, s.Blocked, 10*time.Millisecond)
+}
+
+func TestHooksWork(t *testing.T) {
+
+	var m Metrics
+	c := newFakeClock()
+	q := NewWork[int](time.Second, 2, WithClock(c), WithHooks(&m))
+	assert.NoError(t, q.Push(1))
+
+	// a returned item is pushed again, and a dead-lettered one dropped
+	l, _ := q.TryPop()
+	assert.NoError(t, q.Nack(l))
+	l, _ = q.TryPop()
+	c.Advance(time.Second)
+	assert.Equal(t, WorkStats{Dead: 1}, q.Stats())
+
+	s := m.Snapshot()
+	assert.Equal(t, int64(2), s.Pushed)
+	assert.Equal(t, int64(2), s.Popped)
+	assert.Equal(t, int64(1), s.Dropped)
+	assert.Equal(t, int64(0), s.Len)
+}
@@ -0,0 +1,64 @@
+package queue
+
+import "time"
+
+// Option configures a queue when it is created. Each option documents the
+// queues it applies to; it is ignored by the others.
+type Option func(*options)
+
+type options struct {
+	clock Clock
+	hooks Hooks
+
+	codec        any // a Codec of the item type of the queue
+	sync         SyncPolicy
+	syncInterval time.Duration
+	segmentSize  int64
+}
+
+// WithClock sets the clock used by a queue to schedule its items. It applies
+// to Delayed, Work and, for SyncInterval, Disk.
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithCodec sets the codec used by Disk to store items. Items are stored as
+// JSON by default. Opening a Disk with a codec for another item type fails.
+func WithCodec[T any](c Codec[T]) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+// WithSync sets when Disk flushes its files to stable storage. The interval
+// is the time between flushes for SyncInterval, and is ignored by the other
+// policies. The default is SyncAlways.
+func WithSync(policy SyncPolicy, interval time.Duration) Option {
+	return func(o *options) {
+		o.sync = policy
+		o.syncInterval = interval
+	}
+}
+
+// WithSegmentSize sets the size in bytes above which Disk starts a new
+// segment file. The default is 64 MiB.
+func WithSegmentSize(size int64) Option {
+	return func(o *options) {
+		o.segmentSize = size
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		clock:        systemClock{},
+		sync:         SyncAlways,
+		syncInterval: time.Second,
+		segmentSize:  defaultSegmentSize,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,33 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithClock(t *testing.T) {
+
+	c := newFakeClock()
+	o := newOptions([]Option{WithClock(c)})
+	assert.Equal(t, Clock(c), o.clock)
+}
+
+func TestOptions(t *testing.T) {
+
+	o := newOptions(nil)
+	assert.Equal(t, SyncAlways, o.sync)
+	assert.Equal(t, int64(defaultSegmentSize), o.segmentSize)
+	assert.Nil(t, o.codec)
+
+	o = newOptions([]Option{
+		WithSync(SyncInterval, time.Minute),
+		WithSegmentSize(100),
+		WithCodec[int](JSONCodec[int]{}),
+	})
+	assert.Equal(t, SyncInterval, o.sync)
+	assert.Equal(t, time.Minute, o.syncInterval)
+	assert.Equal(t, int64(100), o.segmentSize)
+	assert.Equal(t, JSONCodec[int]{}, o.codec)
+}
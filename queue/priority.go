@@ -0,0 +1,303 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+)
+
+// Handle refers to an item in a priority queue. It is returned when the item
+// is pushed, and can be used to change the item or remove it from the queue.
+// A handle becomes invalid once its item leaves the queue.
+//
+// The item of a handle from a BlockingPriority must not be read with Value
+// concurrently with an Update of the same handle.
+type Handle[T any] struct {
+	value T
+	index int   // position in the heap, or -1 once the item has left the queue
+	stamp int64 // time of the push, for the hooks of a BlockingPriority
+}
+
+// Value returns the item referred to by the handle.
+func (h *Handle[T]) Value() T {
+	return h.value
+}
+
+// Priority is an unbounded priority queue backed by a binary heap. Items are
+// popped in the order defined by a caller-supplied less function, smallest
+// first. Push, Pop, Update and Remove take O(log n) time and Peek constant
+// time. Items that compare as equal are popped in no particular order.
+//
+// A Priority is not safe for concurrent use. Use BlockingPriority to share a
+// priority queue between goroutines.
+type Priority[T any] struct {
+	h handleHeap[T]
+}
+
+// NewPriority creates and returns an empty priority queue ordered by less.
+func NewPriority[T any](less func(a, b T) bool) *Priority[T] {
+	return &Priority[T]{h: handleHeap[T]{less: less}}
+}
+
+// Push adds an item to the queue and returns a handle to it.
+func (q *Priority[T]) Push(elem T) *Handle[T] {
+	h := &Handle[T]{value: elem}
+	heap.Push(&q.h, h)
+	return h
+}
+
+// Pop removes and returns the smallest item in the queue. Returns false if
+// the queue is empty.
+func (q *Priority[T]) Pop() (T, bool) {
+	h := q.popHandle()
+	if h == nil {
+		var zero T
+		return zero, false
+	}
+	return h.value, true
+}
+
+// popHandle removes the smallest item in the queue and returns its handle, or
+// nil if the queue is empty.
+func (q *Priority[T]) popHandle() *Handle[T] {
+	if len(q.h.items) == 0 {
+		return nil
+	}
+	return heap.Pop(&q.h).(*Handle[T])
+}
+
+// Peek returns the smallest item in the queue without removing it. Returns
+// false if the queue is empty.
+func (q *Priority[T]) Peek() (T, bool) {
+	if len(q.h.items) == 0 {
+		var zero T
+		return zero, false
+	}
+	return q.h.items[0].value, true
+}
+
+// Update replaces the item referred to by a handle and moves it to its new
+// place in the queue. Returns false if the handle is not in this queue.
+func (q *Priority[T]) Update(h *Handle[T], elem T) bool {
+	if !q.contains(h) {
+		return false
+	}
+	h.value = elem
+	heap.Fix(&q.h, h.index)
+	return true
+}
+
+// Remove removes the item referred to by a handle from the queue. Returns
+// false if the handle is not in this queue.
+func (q *Priority[T]) Remove(h *Handle[T]) bool {
+	if !q.contains(h) {
+		return false
+	}
+	heap.Remove(&q.h, h.index)
+	return true
+}
+
+// Len returns the number of items in the queue.
+func (q *Priority[T]) Len() int {
+	return len(q.h.items)
+}
+
+func (q *Priority[T]) contains(h *Handle[T]) bool {
+	return h != nil && h.index >= 0 && h.index < len(q.h.items) && q.h.items[h.index] == h
+}
+
+// handleHeap implements heap.Interface over handles, keeping the index of
+// each handle up to date.
+type handleHeap[T any] struct {
+	less  func(a, b T) bool
+	items []*Handle[T]
+}
+
+func (h *handleHeap[T]) Len() int {
+	return len(h.items)
+}
+
+func (h *handleHeap[T]) Less(i, j int) bool {
+	return h.less(h.items[i].value, h.items[j].value)
+}
+
+func (h *handleHeap[T]) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *handleHeap[T]) Push(x any) {
+	hd := x.(*Handle[T])
+	hd.index = len(h.items)
+	h.items = append(h.items, hd)
+}
+
+func (h *handleHeap[T]) Pop() any {
+	n := len(h.items) - 1
+	hd := h.items[n]
+	h.items[n] = nil // release the reference held by the slice
+	h.items = h.items[:n]
+	hd.index = -1
+	return hd
+}
+
+// BlockingPriority is an unbounded priority queue that is safe for concurrent
+// use. Push never blocks, while Pop blocks until an item is available or the
+// queue is closed. Items are popped in the order defined by less, as for
+// Priority.
+type BlockingPriority[T any] struct {
+	gate
+	observer
+	items *Priority[T]
+}
+
+// NewBlockingPriority creates and returns an empty BlockingPriority ordered
+// by less. The queue is observed by the hooks given with WithHooks.
+func NewBlockingPriority[T any](less func(a, b T) bool, opts ...Option) *BlockingPriority[T] {
+	o := newOptions(opts)
+	return &BlockingPriority[T]{
+		gate:     newGate(),
+		observer: observer{o.hooks},
+		items:    NewPriority(less),
+	}
+}
+
+// Push adds an item to the queue. Returns ErrClosed if the queue is closed.
+func (q *BlockingPriority[T]) Push(elem T) error {
+	_, err := q.PushHandle(elem)
+	return err
+}
+
+// PushHandle adds an item to the queue and returns a handle to it, which can
+// be passed to Update and Remove. Returns ErrClosed if the queue is closed.
+func (q *BlockingPriority[T]) PushHandle(elem T) (*Handle[T], error) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		q.dropped()
+		return nil, ErrClosed
+	}
+	h := q.items.Push(elem)
+	h.stamp = q.stamp()
+	q.mu.Unlock()
+
+	q.pushed()
+	q.signal()
+	return h, nil
+}
+
+// PushCtx adds an item to the queue. Since the queue is unbounded this never
+// blocks, but the item is not added, and the error of the context is
+// returned, if the context is already done.
+func (q *BlockingPriority[T]) PushCtx(ctx context.Context, elem T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return q.Push(elem)
+}
+
+// TryPush adds an item to the queue. Since the queue is unbounded, this only
+// fails if the queue is closed.
+func (q *BlockingPriority[T]) TryPush(elem T) bool {
+	return q.Push(elem) == nil
+}
+
+// Pop removes and returns the smallest item in the queue, blocking while the
+// queue is empty. Once the queue is closed, Pop continues to return the
+// remaining items and then returns ErrClosed.
+func (q *BlockingPriority[T]) Pop() (T, error) {
+	return q.PopCtx(context.Background())
+}
+
+// PopCtx removes and returns the smallest item in the queue, blocking while
+// the queue is empty. Returns the error of the context if it is done before
+// an item is available. A closed queue behaves as for Pop.
+func (q *BlockingPriority[T]) PopCtx(ctx context.Context) (T, error) {
+	var start int64
+	defer func() { q.blocked(start) }()
+	for {
+		elem, ok, closed := q.pop()
+		if ok {
+			return elem, nil
+		}
+		if closed {
+			return elem, ErrClosed
+		}
+		start = q.waiting(start)
+		if err := q.wait(ctx); err != nil {
+			return elem, err
+		}
+	}
+}
+
+// TryPop removes and returns the smallest item in the queue if there is one.
+// Returns false if the queue is empty.
+func (q *BlockingPriority[T]) TryPop() (T, bool) {
+	elem, ok, _ := q.pop()
+	return elem, ok
+}
+
+func (q *BlockingPriority[T]) pop() (elem T, ok bool, closed bool) {
+	q.mu.Lock()
+	h := q.items.popHandle()
+	more := q.items.Len() > 0
+	closed = q.closed
+	q.mu.Unlock()
+
+	if h != nil {
+		elem, ok = h.value, true
+		q.popped(h.stamp)
+	}
+	// pass the token on to the next blocked popper
+	if more {
+		q.signal()
+	}
+	return
+}
+
+// Peek returns the smallest item in the queue without removing it. Returns
+// false if the queue is empty.
+func (q *BlockingPriority[T]) Peek() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Peek()
+}
+
+// Update replaces the item referred to by a handle and moves it to its new
+// place in the queue. Returns false if the handle is not in this queue. The
+// item keeps the time it was pushed, so its latency, reported to the hooks
+// of the queue when it is popped, includes the time before the update.
+func (q *BlockingPriority[T]) Update(h *Handle[T], elem T) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Update(h, elem)
+}
+
+// Remove removes the item referred to by a handle from the queue. Returns
+// false if the handle is not in this queue. The hooks of the queue observe
+// the removal as a pop.
+func (q *BlockingPriority[T]) Remove(h *Handle[T]) bool {
+	q.mu.Lock()
+	ok := q.items.Remove(h)
+	q.mu.Unlock()
+
+	if ok {
+		q.popped(h.stamp)
+	}
+	return ok
+}
+
+// Len returns the number of items in the queue.
+func (q *BlockingPriority[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+// Close closes the queue. Future calls to Push fail with ErrClosed, and
+// blocked calls to Pop return ErrClosed once no items are left. Closing a
+// queue more than once has no effect.
+func (q *BlockingPriority[T]) Close() error {
+	q.close()
+	return nil
+}
@@ -0,0 +1,223 @@
+package queue
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intLess(a, b int) bool {
+	return a < b
+}
+
+func TestPriority(t *testing.T) {
+
+	var tests = map[string]struct {
+		push   []int
+		less   func(a, b int) bool
+		expPop []int
+	}{
+		"empty": {
+			less:   intLess,
+			expPop: []int{},
+		},
+		"min first": {
+			push:   []int{5, 1, 4, 2, 3},
+			less:   intLess,
+			expPop: []int{1, 2, 3, 4, 5},
+		},
+		"max first": {
+			push:   []int{5, 1, 4, 2, 3},
+			less:   func(a, b int) bool { return a > b },
+			expPop: []int{5, 4, 3, 2, 1},
+		},
+		"duplicates": {
+			push:   []int{2, 1, 2, 1},
+			less:   intLess,
+			expPop: []int{1, 1, 2, 2},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := NewPriority(tt.less)
+			for _, v := range tt.push {
+				q.Push(v)
+			}
+			assert.Equal(t, len(tt.push), q.Len())
+
+			gotPop := []int{}
+			for {
+				peek, peekOK := q.Peek()
+				v, ok := q.Pop()
+				assert.Equal(t, peekOK, ok)
+				if !ok {
+					break
+				}
+				assert.Equal(t, peek, v)
+				gotPop = append(gotPop, v)
+			}
+			assert.Equal(t, tt.expPop, gotPop)
+		})
+	}
+}
+
+func TestPriorityHandles(t *testing.T) {
+
+	var tests = map[string]struct {
+		change func(*Priority[int], map[int]*Handle[int]) bool
+		expOK  bool
+		expPop []int
+	}{
+		"update to front": {
+			change: func(q *Priority[int], h map[int]*Handle[int]) bool {
+				return q.Update(h[4], 0)
+			},
+			expOK:  true,
+			expPop: []int{0, 1, 2, 3, 5},
+		},
+		"update to back": {
+			change: func(q *Priority[int], h map[int]*Handle[int]) bool {
+				return q.Update(h[1], 9)
+			},
+			expOK:  true,
+			expPop: []int{2, 3, 4, 5, 9},
+		},
+		"remove": {
+			change: func(q *Priority[int], h map[int]*Handle[int]) bool {
+				return q.Remove(h[3])
+			},
+			expOK:  true,
+			expPop: []int{1, 2, 4, 5},
+		},
+		"remove twice": {
+			change: func(q *Priority[int], h map[int]*Handle[int]) bool {
+				q.Remove(h[3])
+				return q.Remove(h[3])
+			},
+			expPop: []int{1, 2, 4, 5},
+		},
+		"update popped": {
+			change: func(q *Priority[int], h map[int]*Handle[int]) bool {
+				q.Pop()
+				return q.Update(h[1], 0)
+			},
+			expPop: []int{2, 3, 4, 5},
+		},
+		"handle from other queue": {
+			change: func(q *Priority[int], h map[int]*Handle[int]) bool {
+				other := NewPriority(intLess)
+				other.Push(1)
+				return q.Remove(other.Push(0))
+			},
+			expPop: []int{1, 2, 3, 4, 5},
+		},
+		"nil handle": {
+			change: func(q *Priority[int], h map[int]*Handle[int]) bool {
+				return q.Remove(nil)
+			},
+			expPop: []int{1, 2, 3, 4, 5},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := NewPriority(intLess)
+			handles := map[int]*Handle[int]{}
+			for _, v := range []int{3, 5, 1, 4, 2} {
+				handles[v] = q.Push(v)
+				assert.Equal(t, v, handles[v].Value())
+			}
+
+			assert.Equal(t, tt.expOK, tt.change(q, handles))
+
+			gotPop := []int{}
+			for v, ok := q.Pop(); ok; v, ok = q.Pop() {
+				gotPop = append(gotPop, v)
+			}
+			assert.Equal(t, tt.expPop, gotPop)
+		})
+	}
+}
+
+func TestBlockingPriority(t *testing.T) {
+
+	q := NewBlockingPriority(intLess)
+
+	_, ok := q.Peek()
+	assert.False(t, ok)
+
+	handles := map[int]*Handle[int]{}
+	for _, v := range []int{3, 5, 1, 4, 2} {
+		h, err := q.PushHandle(v)
+		assert.NoError(t, err)
+		handles[v] = h
+	}
+	assert.True(t, q.Update(handles[5], 0))
+	assert.True(t, q.Remove(handles[2]))
+	assert.Equal(t, 4, q.Len())
+
+	front, ok := q.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, 0, front)
+
+	assert.NoError(t, q.Close())
+	_, err := q.PushHandle(6)
+	assert.ErrorIs(t, err, ErrClosed)
+
+	gotPop := []int{}
+	for {
+		v, err := q.Pop()
+		if err != nil {
+			assert.ErrorIs(t, err, ErrClosed)
+			break
+		}
+		gotPop = append(gotPop, v)
+	}
+	assert.Equal(t, []int{0, 1, 3, 4}, gotPop)
+}
+
+func TestBlockingPriorityConcurrent(t *testing.T) {
+
+	const producers, items = 4, 250
+
+	q := NewBlockingPriority(intLess)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < items; i++ {
+				assert.NoError(t, q.Push(p*items+i))
+			}
+		}(p)
+	}
+
+	got := []int{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			v, err := q.Pop()
+			if err != nil {
+				return
+			}
+			got = append(got, v)
+		}
+	}()
+
+	wg.Wait()
+	q.Close()
+	<-done
+
+	// every item is popped exactly once
+	sort.Ints(got)
+	exp := make([]int, producers*items)
+	for i := range exp {
+		exp[i] = i
+	}
+	assert.Equal(t, exp, got)
+}
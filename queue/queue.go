@@ -1,19 +1,222 @@
+/*
+Package queue implements first-in first-out, double-ended, priority, delayed,
+work and disk-backed queues.
+
+Queue is a bounded queue implemented with a buffered channel. Producers block
+while the queue is full and consumers block while it is empty; TryPush and
+TryPop are the non-blocking alternatives. A closed queue accepts no more
+items, but the items already queued can still be popped.
+
+FIFO is an unbounded queue backed by a ring buffer, for use within a single
+goroutine. BlockingFIFO wraps the same ring buffer for concurrent use; its
+producers never block, and its consumers block while it is empty. Priority
+and BlockingPriority are the equivalent priority queues, popping items in an
+order given by the caller rather than the order in which they were pushed.
+Deque is the ring buffer underlying FIFO, exposed for pushing and popping at
+both ends and for reading items by position.
+
+Delayed holds each item until a time given when it is pushed, for example to
+retry work with a backoff. It tells the time with a Clock, which can be
+replaced with WithClock so that tests control the passing of time.
+
+Work is a queue of work items that survive the failure of the workers taking
+them. Pop leases an item for a visibility timeout instead of removing it;
+the worker acknowledges the lease with Ack once the item is processed, or
+returns the item with Nack. Items whose leases expire are returned too, and
+items leased too many times are dead-lettered.
+
+Disk is a first-in first-out queue stored in segment files in a directory,
+so that its items survive the process. Items are encoded by a Codec, JSON by
+default, and the files are flushed according to a SyncPolicy.
+
+The queues that are safe for concurrent use, apart from Work, implement the
+Blocking interface. Its PushCtx and PopCtx methods give up waiting when a
+context is cancelled or reaches its deadline, returning the error of the
+context. PopBatch, PushBatch and Consume work on any Blocking queue, popping
+items in batches or with a pool of workers.
+
+Every queue that is safe for concurrent use can be observed through Hooks,
+given with WithHooks. Metrics is a Hooks that keeps atomic counters of the
+length, throughput, latency and blocking of a queue, and exports them
+through expvar.
+*/
 package queue
 
-type queue struct {
-	q chan interface{}
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned when pushing to a closed queue, and when popping from
+// a closed queue that has no items left.
+var ErrClosed = errors.New("queue: closed")
+
+// Blocking is the interface implemented by the queues of this package that
+// are safe for concurrent use.
+//
+// Push and Pop block until they succeed or the queue is closed, and PushCtx
+// and PopCtx also give up when the context is done. TryPush and TryPop never
+// block. Once a queue is closed, pushes fail with ErrClosed, while pops
+// continue to return the remaining items before failing with ErrClosed.
+type Blocking[T any] interface {
+	Push(elem T) error
+	PushCtx(ctx context.Context, elem T) error
+	TryPush(elem T) bool
+	Pop() (T, error)
+	PopCtx(ctx context.Context) (T, error)
+	TryPop() (T, bool)
+	Len() int
+	Close() error
+}
+
+// Queue is a bounded first-in first-out queue of items of type T.
+type Queue[T any] struct {
+	observer
+	items chan entry[T]
+	done  chan struct{}
+	once  sync.Once
+}
+
+// New creates and returns an empty queue that holds up to capacity items. A
+// queue with a capacity of zero holds no items; each Push blocks until a Pop
+// takes the item. The queue is observed by the hooks given with WithHooks.
+func New[T any](capacity int, opts ...Option) *Queue[T] {
+	o := newOptions(opts)
+	return &Queue[T]{
+		observer: observer{o.hooks},
+		items:    make(chan entry[T], capacity),
+		done:     make(chan struct{}),
+	}
+}
+
+// Push adds an item to the back of the queue, blocking while the queue is
+// full. Returns ErrClosed if the queue is closed before the item is added.
+func (q *Queue[T]) Push(elem T) error {
+	return q.PushCtx(context.Background(), elem)
+}
+
+// PushCtx adds an item to the back of the queue, blocking while the queue is
+// full. Returns ErrClosed if the queue is closed, or the error of the context
+// if it is done, before the item is added. The item is never added if the
+// context is already done.
+func (q *Queue[T]) PushCtx(ctx context.Context, elem T) error {
+	select {
+	case <-q.done:
+		q.dropped()
+		return ErrClosed
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	e := entry[T]{elem: elem, stamp: q.stamp()}
+	select {
+	case q.items <- e:
+		q.pushed()
+		return nil
+	default:
+	}
+
+	// the queue is full
+	defer q.blocked(q.stamp())
+	select {
+	case q.items <- e:
+		q.pushed()
+		return nil
+	case <-q.done:
+		q.dropped()
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryPush adds an item to the back of the queue if it can be done without
+// blocking. Returns false if the queue is full or closed.
+func (q *Queue[T]) TryPush(elem T) bool {
+	select {
+	case <-q.done:
+		q.dropped()
+		return false
+	default:
+	}
+
+	select {
+	case q.items <- entry[T]{elem: elem, stamp: q.stamp()}:
+		q.pushed()
+		return true
+	default:
+		q.dropped()
+		return false
+	}
+}
+
+// Pop removes and returns the item at the front of the queue, blocking while
+// the queue is empty. Once the queue is closed, Pop continues to return the
+// remaining items and then returns ErrClosed.
+func (q *Queue[T]) Pop() (T, error) {
+	return q.PopCtx(context.Background())
+}
+
+// PopCtx removes and returns the item at the front of the queue, blocking
+// while the queue is empty. Returns the error of the context if it is done
+// before an item is available. A closed queue behaves as for Pop.
+func (q *Queue[T]) PopCtx(ctx context.Context) (T, error) {
+	if elem, ok := q.TryPop(); ok {
+		return elem, nil
+	}
+
+	// the queue is empty
+	defer q.blocked(q.stamp())
+	select {
+	case e := <-q.items:
+		q.popped(e.stamp)
+		return e.elem, nil
+	case <-q.done:
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+
+	// closed; drain whatever is left
+	if elem, ok := q.TryPop(); ok {
+		return elem, nil
+	}
+	var zero T
+	return zero, ErrClosed
 }
 
-func new(capacity int) *queue {
-	return &queue{
-		q: make(chan interface{}, capacity),
+// TryPop removes and returns the item at the front of the queue if it can be
+// done without blocking. Returns false if the queue is empty.
+func (q *Queue[T]) TryPop() (T, bool) {
+	select {
+	case e := <-q.items:
+		q.popped(e.stamp)
+		return e.elem, true
+	default:
+		var zero T
+		return zero, false
 	}
 }
 
-func (q *queue) push(elem interface{}) {
-	q.q <- elem
+// Len returns the number of items in the queue.
+func (q *Queue[T]) Len() int {
+	return len(q.items)
+}
+
+// Cap returns the maximum number of items the queue can hold.
+func (q *Queue[T]) Cap() int {
+	return cap(q.items)
 }
 
-func (q *queue) pop() interface{} {
-	return <-q.q
+// Close closes the queue. Blocked and future calls to Push fail with
+// ErrClosed, and blocked calls to Pop return ErrClosed once no items are
+// left. Closing a queue more than once has no effect.
+func (q *Queue[T]) Close() error {
+	q.once.Do(func() {
+		close(q.done)
+	})
+	return nil
 }
@@ -0,0 +1,293 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushPop(t *testing.T) {
+
+	var tests = map[string]struct {
+		capacity int
+		push     []int
+		expPop   []int
+		expLen   int
+	}{
+		"fifo order": {
+			capacity: 3,
+			push:     []int{1, 2, 3},
+			expPop:   []int{1, 2, 3},
+		},
+		"partial pop": {
+			capacity: 4,
+			push:     []int{1, 2, 3},
+			expPop:   []int{1},
+			expLen:   2,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			q := New[int](tt.capacity)
+			for _, v := range tt.push {
+				assert.NoError(t, q.Push(v))
+			}
+
+			for _, exp := range tt.expPop {
+				got, err := q.Pop()
+				assert.NoError(t, err)
+				assert.Equal(t, exp, got)
+			}
+
+			assert.Equal(t, tt.expLen, q.Len())
+			assert.Equal(t, tt.capacity, q.Cap())
+		})
+	}
+}
+
+func TestTryPushPop(t *testing.T) {
+
+	q := New[string](2)
+
+	_, ok := q.TryPop()
+	assert.False(t, ok, "pop from empty queue")
+
+	assert.True(t, q.TryPush("a"))
+	assert.True(t, q.TryPush("b"))
+	assert.False(t, q.TryPush("c"), "push to full queue")
+
+	got, ok := q.TryPop()
+	assert.True(t, ok)
+	assert.Equal(t, "a", got)
+
+	assert.NoError(t, q.Close())
+	assert.False(t, q.TryPush("c"), "push to closed queue")
+
+	got, ok = q.TryPop()
+	assert.True(t, ok, "pop remaining from closed queue")
+	assert.Equal(t, "b", got)
+}
+
+func TestPushBlocks(t *testing.T) {
+
+	q := New[int](1)
+	assert.NoError(t, q.Push(1))
+
+	pushed := make(chan error)
+	go func() {
+		pushed <- q.Push(2)
+	}()
+
+	select {
+	case <-pushed:
+		t.Fatal("push to full queue did not block")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	got, err := q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, got)
+	assert.NoError(t, <-pushed)
+
+	got, err = q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, got)
+}
+
+func TestPopBlocks(t *testing.T) {
+
+	q := New[int](1)
+
+	popped := make(chan int)
+	go func() {
+		v, _ := q.Pop()
+		popped <- v
+	}()
+
+	select {
+	case <-popped:
+		t.Fatal("pop from empty queue did not block")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	assert.NoError(t, q.Push(7))
+	assert.Equal(t, 7, <-popped)
+}
+
+func TestClose(t *testing.T) {
+
+	q := New[int](3)
+	assert.NoError(t, q.Push(1))
+	assert.NoError(t, q.Push(2))
+
+	assert.NoError(t, q.Close())
+	assert.NoError(t, q.Close(), "close twice")
+
+	assert.ErrorIs(t, q.Push(3), ErrClosed)
+
+	for _, exp := range []int{1, 2} {
+		got, err := q.Pop()
+		assert.NoError(t, err)
+		assert.Equal(t, exp, got)
+	}
+
+	_, err := q.Pop()
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestCloseUnblocks(t *testing.T) {
+
+	full := New[int](0)
+	empty := New[int](1)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	var pushErr, popErr error
+	go func() {
+		defer wg.Done()
+		pushErr = full.Push(1)
+	}()
+	go func() {
+		defer wg.Done()
+		_, popErr = empty.Pop()
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	full.Close()
+	empty.Close()
+	wg.Wait()
+
+	assert.ErrorIs(t, pushErr, ErrClosed)
+	assert.ErrorIs(t, popErr, ErrClosed)
+}
+
+func TestConcurrent(t *testing.T) {
+
+	const producers, items = 4, 1000
+
+	q := New[int](16)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < items; i++ {
+				assert.NoError(t, q.Push(i))
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		q.Close()
+	}()
+
+	sum, count := 0, 0
+	for {
+		v, err := q.Pop()
+		if err != nil {
+			assert.ErrorIs(t, err, ErrClosed)
+			break
+		}
+		sum += v
+		count++
+	}
+
+	assert.Equal(t, producers*items, count)
+	assert.Equal(t, producers*items*(items-1)/2, sum)
+}
+
+// blocking returns one of each queue implementing Blocking, each able to hold
+// at least eight items.
+func blocking(t *testing.T) map[string]Blocking[int] {
+	return map[string]Blocking[int]{
+		"queue":         New[int](8),
+		"blocking fifo": NewBlockingFIFO[int](),
+		"blocking priority": NewBlockingPriority(func(a, b int) bool {
+			return a < b
+		}),
+		"delayed": NewDelayed[int](),
+		"disk":    openDisk(t, t.TempDir()),
+	}
+}
+
+func TestPopCtx(t *testing.T) {
+
+	for name, q := range blocking(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := q.PopCtx(ctx)
+			assert.ErrorIs(t, err, context.Canceled)
+
+			ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			_, err = q.PopCtx(ctx)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+			assert.NoError(t, q.PushCtx(context.Background(), 5))
+			got, err := q.PopCtx(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, 5, got)
+
+			// pending pops drain the remaining items before failing
+			assert.NoError(t, q.Push(6))
+			assert.NoError(t, q.Close())
+			got, err = q.PopCtx(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, 6, got)
+			_, err = q.PopCtx(context.Background())
+			assert.ErrorIs(t, err, ErrClosed)
+		})
+	}
+}
+
+func TestPushCtx(t *testing.T) {
+
+	for name, q := range blocking(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			assert.ErrorIs(t, q.PushCtx(ctx, 1), context.Canceled)
+			assert.Equal(t, 0, q.Len())
+
+			assert.NoError(t, q.Close())
+			assert.ErrorIs(t, q.PushCtx(context.Background(), 1), ErrClosed)
+		})
+	}
+
+	// only a bounded queue blocks a push
+	q := New[int](1)
+	assert.NoError(t, q.Push(1))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.PushCtx(ctx, 2), context.DeadlineExceeded)
+}
+
+func TestPopCtxUnblocksOnClose(t *testing.T) {
+
+	for name, q := range blocking(t) {
+		t.Run(name, func(t *testing.T) {
+			const waiters = 3
+
+			errs := make(chan error, waiters)
+			for i := 0; i < waiters; i++ {
+				go func() {
+					_, err := q.PopCtx(context.Background())
+					errs <- err
+				}()
+			}
+
+			time.Sleep(10 * time.Millisecond)
+			assert.NoError(t, q.Close())
+			for i := 0; i < waiters; i++ {
+				assert.ErrorIs(t, <-errs, ErrClosed)
+			}
+		})
+	}
+}
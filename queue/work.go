@@ -0,0 +1,295 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrLeaseExpired is returned when acknowledging a lease that has expired, or
+// that has already been acknowledged.
+var ErrLeaseExpired = errors.New("queue: lease expired")
+
+// workItem is an item of a Work queue with the number of times it has been
+// leased, stamped with the time it was last made ready.
+type workItem[T any] struct {
+	elem     T
+	attempts int
+	stamp    int64
+}
+
+// Lease is the hold of a worker on an item popped from a Work queue. The item
+// stays in the queue, invisible to other workers, until the lease is
+// acknowledged with Ack or Nack or until it expires.
+type Lease[T any] struct {
+	item    *workItem[T]
+	attempt int
+	expires time.Time
+	handle  *Handle[*Lease[T]]
+}
+
+// Value returns the leased item.
+func (l *Lease[T]) Value() T {
+	return l.item.elem
+}
+
+// Attempt returns the number of times the item has been leased, including
+// this lease; it is one for the first delivery of an item.
+func (l *Lease[T]) Attempt() int {
+	return l.attempt
+}
+
+// Expires returns the time at which the lease expires if it has not been
+// acknowledged.
+func (l *Lease[T]) Expires() time.Time {
+	return l.expires
+}
+
+// WorkStats counts the items of a Work queue by state.
+type WorkStats struct {
+	// Ready is the number of items waiting to be popped.
+	Ready int
+	// InFlight is the number of items leased to workers.
+	InFlight int
+	// Dead is the number of items dead-lettered and not yet drained.
+	Dead int
+}
+
+// Work is an unbounded queue of work items that are not lost if a worker
+// fails while processing them. It is safe for concurrent use.
+//
+// Pop leases the item at the front of the queue to a worker for the
+// visibility timeout of the queue, during which no other worker sees it. The
+// worker removes the item with Ack once it has been processed, or returns it
+// to the back of the queue with Nack. An item whose lease expires is also
+// returned to the back of the queue. An item that has been leased the maximum
+// number of times is dead-lettered instead of being returned, and is kept
+// until taken with DrainDead.
+//
+// Time is told by the clock given with WithClock, or by the system clock.
+type Work[T any] struct {
+	gate
+	observer
+	clock       Clock
+	visibility  time.Duration
+	maxAttempts int
+
+	ready  Deque[*workItem[T]]
+	leased *Priority[*Lease[T]]
+	dead   []T
+}
+
+// NewWork creates and returns an empty Work queue whose leases expire after
+// the visibility timeout. Items are dead-lettered once they have been leased
+// maxAttempts times; a maxAttempts of zero or less retries items forever. The
+// queue is observed by the hooks given with WithHooks.
+func NewWork[T any](visibility time.Duration, maxAttempts int, opts ...Option) *Work[T] {
+	o := newOptions(opts)
+	return &Work[T]{
+		gate:        newGate(),
+		observer:    observer{o.hooks},
+		clock:       o.clock,
+		visibility:  visibility,
+		maxAttempts: maxAttempts,
+		leased: NewPriority(func(a, b *Lease[T]) bool {
+			return a.expires.Before(b.expires)
+		}),
+	}
+}
+
+// Push adds an item to the back of the queue. Returns ErrClosed if the queue
+// is closed.
+func (q *Work[T]) Push(elem T) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		q.dropped()
+		return ErrClosed
+	}
+	q.ready.PushBack(&workItem[T]{elem: elem, stamp: q.stamp()})
+	q.mu.Unlock()
+
+	q.pushed()
+	q.signal()
+	return nil
+}
+
+// PushCtx adds an item to the back of the queue. Since the queue is
+// unbounded this never blocks, but the item is not added, and the error of
+// the context is returned, if the context is already done.
+func (q *Work[T]) PushCtx(ctx context.Context, elem T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return q.Push(elem)
+}
+
+// TryPush adds an item to the back of the queue. Since the queue is
+// unbounded, this only fails if the queue is closed.
+func (q *Work[T]) TryPush(elem T) bool {
+	return q.Push(elem) == nil
+}
+
+// Pop leases the item at the front of the queue, blocking while no item is
+// ready. Once the queue is closed, Pop continues to lease the remaining items,
+// including those returned by Nack or by expired leases, and returns
+// ErrClosed once no item is either ready or leased.
+func (q *Work[T]) Pop() (*Lease[T], error) {
+	return q.PopCtx(context.Background())
+}
+
+// PopCtx leases the item at the front of the queue, blocking while no item is
+// ready. Returns the error of the context if it is done before an item is
+// ready. A closed queue behaves as for Pop.
+func (q *Work[T]) PopCtx(ctx context.Context) (*Lease[T], error) {
+	var start int64
+	defer func() { q.blocked(start) }()
+	for {
+		l, next, closed := q.pop()
+		if l != nil {
+			return l, nil
+		}
+		if closed && next < 0 {
+			return nil, ErrClosed
+		}
+		start = q.waiting(start)
+		if err := q.waitDue(ctx, q.clock, next, closed); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// TryPop leases the item at the front of the queue if one is ready. Returns
+// false if no item is ready.
+func (q *Work[T]) TryPop() (*Lease[T], bool) {
+	l, _, _ := q.pop()
+	return l, l != nil
+}
+
+// pop leases the item at the front of the queue if one is ready. Otherwise it
+// returns how long until the next lease expires, or a negative duration if
+// no item is leased.
+func (q *Work[T]) pop() (l *Lease[T], next time.Duration, closed bool) {
+	q.mu.Lock()
+	now := q.clock.Now()
+	q.expire(now)
+
+	if it, ok := q.ready.PopFront(); ok {
+		it.attempts++
+		q.popped(it.stamp)
+		l = &Lease[T]{item: it, attempt: it.attempts, expires: now.Add(q.visibility)}
+		l.handle = q.leased.Push(l)
+	}
+
+	next = -1
+	if first, ok := q.leased.Peek(); ok {
+		next = first.expires.Sub(now)
+	}
+	more := q.ready.Len() > 0
+	closed = q.closed
+	q.mu.Unlock()
+
+	// pass the token on to the next blocked popper, either to take the next
+	// item or to find that the closed queue is done
+	if (l != nil && more) || (closed && next < 0) {
+		q.signal()
+	}
+	return
+}
+
+// expire releases the items whose leases have expired by the given time.
+func (q *Work[T]) expire(now time.Time) {
+	for {
+		first, ok := q.leased.Peek()
+		if !ok || first.expires.After(now) {
+			return
+		}
+		q.leased.Pop()
+		q.release(first.item)
+	}
+}
+
+// release returns an item to the back of the queue, or dead-letters it if it
+// has been leased the maximum number of times.
+func (q *Work[T]) release(it *workItem[T]) {
+	if q.maxAttempts > 0 && it.attempts >= q.maxAttempts {
+		q.dead = append(q.dead, it.elem)
+		q.dropped()
+		return
+	}
+	it.stamp = q.stamp()
+	q.ready.PushBack(it)
+	q.pushed()
+}
+
+// Ack removes the item of a lease from the queue once it has been processed.
+// Returns ErrLeaseExpired if the lease has expired or has already been
+// acknowledged, in which case the item may have been leased again.
+func (q *Work[T]) Ack(l *Lease[T]) error {
+	return q.settle(l, false)
+}
+
+// Nack returns the item of a lease to the back of the queue, or dead-letters
+// it if it has been leased the maximum number of times. Returns
+// ErrLeaseExpired if the lease has expired or has already been acknowledged.
+func (q *Work[T]) Nack(l *Lease[T]) error {
+	return q.settle(l, true)
+}
+
+func (q *Work[T]) settle(l *Lease[T], requeue bool) error {
+	q.mu.Lock()
+	q.expire(q.clock.Now())
+	if l == nil || !q.leased.Remove(l.handle) {
+		q.mu.Unlock()
+		return ErrLeaseExpired
+	}
+	if requeue {
+		q.release(l.item)
+	}
+	q.mu.Unlock()
+
+	// wake a blocked popper, either to take the returned item or to find that
+	// the closed queue is done
+	q.signal()
+	return nil
+}
+
+// DrainDead removes and returns the dead-lettered items, in the order they
+// were dead-lettered.
+func (q *Work[T]) DrainDead() []T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire(q.clock.Now())
+	dead := q.dead
+	q.dead = nil
+	return dead
+}
+
+// Stats returns the number of items in each state.
+func (q *Work[T]) Stats() WorkStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire(q.clock.Now())
+	return WorkStats{
+		Ready:    q.ready.Len(),
+		InFlight: q.leased.Len(),
+		Dead:     len(q.dead),
+	}
+}
+
+// Len returns the number of items that are ready or leased.
+func (q *Work[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire(q.clock.Now())
+	return q.ready.Len() + q.leased.Len()
+}
+
+// Close closes the queue. Future calls to Push fail with ErrClosed, and
+// blocked calls to Pop return ErrClosed once no items are ready or leased.
+// Leases can still be acknowledged. Closing a queue more than once has no
+// effect.
+func (q *Work[T]) Close() error {
+	q.close()
+	return nil
+}
@@ -0,0 +1,170 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkAck(t *testing.T) {
+
+	q := NewWork[string](time.Second, 0, WithClock(newFakeClock()))
+	assert.NoError(t, q.Push("a"))
+	assert.Equal(t, WorkStats{Ready: 1}, q.Stats())
+
+	l, err := q.Pop()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", l.Value())
+	assert.Equal(t, 1, l.Attempt())
+	assert.Equal(t, WorkStats{InFlight: 1}, q.Stats())
+	assert.Equal(t, 1, q.Len())
+
+	assert.NoError(t, q.Ack(l))
+	assert.Equal(t, WorkStats{}, q.Stats())
+	assert.ErrorIs(t, q.Ack(l), ErrLeaseExpired, "ack twice")
+	assert.ErrorIs(t, q.Nack(l), ErrLeaseExpired, "nack after ack")
+	assert.ErrorIs(t, q.Ack(nil), ErrLeaseExpired)
+
+	_, ok := q.TryPop()
+	assert.False(t, ok)
+}
+
+func TestWorkNack(t *testing.T) {
+
+	q := NewWork[string](time.Second, 0, WithClock(newFakeClock()))
+	assert.NoError(t, q.Push("a"))
+	assert.NoError(t, q.Push("b"))
+
+	l, _ := q.TryPop()
+	assert.Equal(t, "a", l.Value())
+	assert.NoError(t, q.Nack(l))
+	assert.Equal(t, WorkStats{Ready: 2}, q.Stats())
+
+	// a returned item goes to the back of the queue
+	l, _ = q.TryPop()
+	assert.Equal(t, "b", l.Value())
+	l, _ = q.TryPop()
+	assert.Equal(t, "a", l.Value())
+	assert.Equal(t, 2, l.Attempt())
+}
+
+func TestWorkExpire(t *testing.T) {
+
+	c := newFakeClock()
+	q := NewWork[string](time.Second, 0, WithClock(c))
+	assert.NoError(t, q.Push("a"))
+
+	l, _ := q.TryPop()
+	assert.Equal(t, c.Now().Add(time.Second), l.Expires())
+
+	c.Advance(time.Second - 1)
+	assert.Equal(t, WorkStats{InFlight: 1}, q.Stats())
+
+	c.Advance(1)
+	assert.Equal(t, WorkStats{Ready: 1}, q.Stats())
+	assert.ErrorIs(t, q.Ack(l), ErrLeaseExpired, "ack expired lease")
+
+	l, _ = q.TryPop()
+	assert.Equal(t, "a", l.Value())
+	assert.Equal(t, 2, l.Attempt())
+	assert.NoError(t, q.Ack(l))
+}
+
+func TestWorkDeadLetter(t *testing.T) {
+
+	c := newFakeClock()
+	q := NewWork[string](time.Second, 2, WithClock(c))
+	assert.NoError(t, q.Push("a"))
+	assert.NoError(t, q.Push("b"))
+
+	// a is nacked and b expires, each twice
+	for i := 0; i < 2; i++ {
+		l, _ := q.TryPop()
+		assert.Equal(t, "a", l.Value())
+		assert.NoError(t, q.Nack(l))
+		l, _ = q.TryPop()
+		assert.Equal(t, "b", l.Value())
+		c.Advance(time.Second)
+	}
+
+	assert.Equal(t, WorkStats{Dead: 2}, q.Stats())
+	assert.Equal(t, 0, q.Len())
+	assert.Equal(t, []string{"a", "b"}, q.DrainDead())
+	assert.Equal(t, WorkStats{}, q.Stats())
+	assert.Empty(t, q.DrainDead())
+}
+
+func TestWorkPopBlocks(t *testing.T) {
+
+	c := newFakeClock()
+	q := NewWork[int](time.Second, 0, WithClock(c))
+	assert.NoError(t, q.Push(1))
+	first, _ := q.TryPop()
+
+	// the only item is leased, so the next pop waits for the lease to expire
+	popped := make(chan *Lease[int])
+	go func() {
+		l, err := q.Pop()
+		assert.NoError(t, err)
+		popped <- l
+	}()
+
+	c.BlockUntil(first.Expires())
+	select {
+	case <-popped:
+		t.Fatal("popped a leased item")
+	default:
+	}
+
+	c.Advance(time.Second)
+	l := <-popped
+	assert.Equal(t, 1, l.Value())
+	assert.Equal(t, 2, l.Attempt())
+}
+
+func TestWorkClose(t *testing.T) {
+
+	c := newFakeClock()
+	q := NewWork[int](time.Second, 0, WithClock(c))
+	assert.NoError(t, q.Push(1))
+	l, _ := q.TryPop()
+
+	assert.NoError(t, q.Close())
+	assert.ErrorIs(t, q.Push(2), ErrClosed)
+
+	// an item in flight can still be returned and leased again
+	popped := make(chan error)
+	go func() {
+		for {
+			l, err := q.Pop()
+			if err != nil {
+				popped <- err
+				return
+			}
+			assert.NoError(t, q.Ack(l))
+		}
+	}()
+
+	c.BlockUntil(l.Expires())
+	assert.NoError(t, q.Nack(l))
+	assert.ErrorIs(t, <-popped, ErrClosed)
+	assert.Equal(t, WorkStats{}, q.Stats())
+}
+
+func TestWorkPopCtx(t *testing.T) {
+
+	q := NewWork[int](time.Second, 0, WithClock(newFakeClock()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := q.PopCtx(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.ErrorIs(t, q.PushCtx(ctx, 1), context.Canceled)
+	assert.True(t, q.TryPush(1))
+	l, err := q.PopCtx(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, l.Value())
+}
@@ -0,0 +1,289 @@
+package tree
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+const (
+	// none marks a missing link between the nodes of an arena
+	none int32 = -1
+	// dead marks the parent of a node of an arena that was removed from its
+	// tree
+	dead int32 = -2
+)
+
+// Arena makes a tree store its nodes in an arena, for trees of millions of
+// nodes, with room for size nodes before its storage grows. Without it, every
+// node is a separate allocation holding pointers to its parent and children,
+// and the primary index maps each key to a pointer, all of which the garbage
+// collector must scan. With it, the keys, data and links of the nodes are held
+// in contiguous slices: each node is numbered by its position in the slices,
+// and has the numbers of its parent, first child, last child and next sibling.
+// The primary index maps each key to a number.
+//
+// The tree keeps all of its methods, and its nodes are Node values. A Node of
+// a tree with this option refers to the arena, so that GetChildren builds a
+// new slice on each call; the methods of Tree, such as Traverse and Find,
+// follow the links without allocating. AddChildren and ReplaceChildren on its
+// nodes panic if given nodes of another tree.
+//
+// Remove copies the removed nodes into a new tree, whose nodes are then other
+// Node values, and Merge copies the nodes of the other tree if either tree has
+// this option, so that the two trees remain independent. The storage of the
+// removed nodes is not reused until the tree is empty; Clone returns a copy of
+// the tree without it. The trees returned by Remove and Clone inherit this
+// option. Arena has no effect on a tree created with NodeFactory, whose nodes
+// are created by the factory.
+//
+// A tree with this option holds at most math.MaxInt32 nodes, and panics when
+// a node is added beyond that.
+func Arena(size int) TreeOption {
+	return func(c *treeConfig) {
+		c.arena = max(size, 1)
+	}
+}
+
+// arena holds the nodes of a tree with the Arena option, each under its
+// number.
+type arena[K comparable, T any] struct {
+	tree *Tree[K, T]
+
+	ids       []K
+	parentIDs []K
+	noParent  []bool
+	data      []T
+	// the links between the nodes, as numbers or none; the parent of a node
+	// removed from the tree is dead
+	parent      []int32
+	firstChild  []int32
+	lastChild   []int32
+	nextSibling []int32
+
+	// handles holds the Node of each number, in blocks doubling in size so
+	// that a handle never moves once created: block k holds the numbers from
+	// 2^k-1 to 2^(k+1)-2
+	handles [][]arenaNode[K, T]
+}
+
+func newArena[K comparable, T any](t *Tree[K, T], size int) *arena[K, T] {
+	return &arena[K, T]{
+		tree:        t,
+		ids:         make([]K, 0, size),
+		parentIDs:   make([]K, 0, size),
+		noParent:    make([]bool, 0, size),
+		data:        make([]T, 0, size),
+		parent:      make([]int32, 0, size),
+		firstChild:  make([]int32, 0, size),
+		lastChild:   make([]int32, 0, size),
+		nextSibling: make([]int32, 0, size),
+	}
+}
+
+// handle returns the position in a.handles of the Node of number i.
+func handle(i int32) (block, offset int) {
+	j := uint32(i) + 1
+	block = bits.Len32(j) - 1
+	return block, int(j - 1<<block)
+}
+
+// node returns the Node of number i.
+func (a *arena[K, T]) node(i int32) *arenaNode[K, T] {
+	b, o := handle(i)
+	return &a.handles[b][o]
+}
+
+// alloc adds a node to the arena, unlinked, and returns its Node.
+func (a *arena[K, T]) alloc(nodeID K, parentID K, noParent bool, data T) *arenaNode[K, T] {
+	if len(a.ids) == math.MaxInt32 {
+		panic(fmt.Sprintf("tree: arena of %T is full", a.tree))
+	}
+	i := int32(len(a.ids))
+	a.ids = append(a.ids, nodeID)
+	a.parentIDs = append(a.parentIDs, parentID)
+	a.noParent = append(a.noParent, noParent)
+	a.data = append(a.data, data)
+	a.parent = append(a.parent, none)
+	a.firstChild = append(a.firstChild, none)
+	a.lastChild = append(a.lastChild, none)
+	a.nextSibling = append(a.nextSibling, none)
+
+	b, o := handle(i)
+	if b == len(a.handles) {
+		a.handles = append(a.handles, make([]arenaNode[K, T], 1<<b))
+	}
+	a.handles[b][o] = arenaNode[K, T]{a: a, i: i}
+	return &a.handles[b][o]
+}
+
+// discard takes the node n, which was allocated but not added to the tree,
+// back out of the arena if it is the last one allocated.
+func (a *arena[K, T]) discard(n *arenaNode[K, T]) {
+	last := len(a.ids) - 1
+	if int(n.i) != last {
+		return
+	}
+	var zeroK K
+	var zeroT T
+	a.ids[last], a.parentIDs[last], a.data[last] = zeroK, zeroK, zeroT
+	a.ids = a.ids[:last]
+	a.parentIDs = a.parentIDs[:last]
+	a.noParent = a.noParent[:last]
+	a.data = a.data[:last]
+	a.parent = a.parent[:last]
+	a.firstChild = a.firstChild[:last]
+	a.lastChild = a.lastChild[:last]
+	a.nextSibling = a.nextSibling[:last]
+}
+
+// kill unlinks the node of number i, which was removed from the tree.
+func (a *arena[K, T]) kill(i int32) {
+	a.parent[i], a.firstChild[i], a.lastChild[i], a.nextSibling[i] = dead, none, none, none
+}
+
+// own returns the number of n, which must be a node of the arena.
+func (a *arena[K, T]) own(n Node[K, T]) int32 {
+	an, ok := n.(*arenaNode[K, T])
+	if !ok || an.a != a {
+		panic(fmt.Sprintf("tree: node %v is not in the arena of the tree", n.GetID()))
+	}
+	return an.i
+}
+
+// arenaNode is a node of a tree with the Arena option, referring to the
+// storage of the node in the arena by its number.
+type arenaNode[K comparable, T any] struct {
+	a *arena[K, T]
+	i int32
+}
+
+func (n *arenaNode[K, T]) GetID() K {
+	return n.a.ids[n.i]
+}
+
+func (n *arenaNode[K, T]) GetParentID() K {
+	return n.a.parentIDs[n.i]
+}
+
+func (n *arenaNode[K, T]) HasParentID() bool {
+	return !n.a.noParent[n.i]
+}
+
+// GetChildren returns the children of the node in a new slice, which may be
+// modified.
+func (n *arenaNode[K, T]) GetChildren() []Node[K, T] {
+	var children []Node[K, T]
+	for c := n.a.firstChild[n.i]; c != none; c = n.a.nextSibling[c] {
+		children = append(children, n.a.node(c))
+	}
+	return children
+}
+
+func (n *arenaNode[K, T]) GetParent() Node[K, T] {
+	p := n.a.parent[n.i]
+	if p < 0 {
+		return nil
+	}
+	return n.a.node(p)
+}
+
+func (n *arenaNode[K, T]) AddChildren(children ...Node[K, T]) {
+	n.setChildren(append(n.GetChildren(), children...))
+}
+
+func (n *arenaNode[K, T]) ReplaceChildren(children ...Node[K, T]) {
+	n.setChildren(children)
+}
+
+func (n *arenaNode[K, T]) setParent(parent Node[K, T]) {
+	if parent == nil || parent.GetID() == n.GetID() {
+		return
+	}
+	n.a.parent[n.i] = n.a.own(parent)
+	n.a.parentIDs[n.i] = parent.GetID()
+	n.a.noParent[n.i] = false
+}
+
+func (n *arenaNode[K, T]) setChildren(children []Node[K, T]) {
+	a := n.a
+	first, prev := none, none
+	for _, c := range children {
+		ci := a.own(c)
+		if prev == none {
+			first = ci
+		} else {
+			a.nextSibling[prev] = ci
+		}
+		prev = ci
+	}
+	if prev != none {
+		a.nextSibling[prev] = none
+	}
+	a.firstChild[n.i], a.lastChild[n.i] = first, prev
+}
+
+func (n *arenaNode[K, T]) appendChild(c Node[K, T]) {
+	a := n.a
+	ci := a.own(c)
+	a.nextSibling[ci] = none
+	if last := a.lastChild[n.i]; last == none {
+		a.firstChild[n.i] = ci
+	} else {
+		a.nextSibling[last] = ci
+	}
+	a.lastChild[n.i] = ci
+}
+
+func (n *arenaNode[K, T]) eachChild(visit func(Node[K, T]) bool) {
+	for c := n.a.firstChild[n.i]; c != none; c = n.a.nextSibling[c] {
+		if !visit(n.a.node(c)) {
+			return
+		}
+	}
+}
+
+func (n *arenaNode[K, T]) detach() {
+	n.a.parent[n.i] = none
+}
+
+func (n *arenaNode[K, T]) setParentKey(id K, noParent bool) {
+	n.a.parentIDs[n.i], n.a.noParent[n.i] = id, noParent
+}
+
+func (n *arenaNode[K, T]) base() *BaseNode[K, T] {
+	return nil
+}
+
+func (n *arenaNode[K, T]) GetData() T {
+	return n.a.data[n.i]
+}
+
+func (n *arenaNode[K, T]) SetData(newdata T) {
+	if t := n.a.tree; t != nil && n.a.parent[n.i] != dead {
+		// a violation of a unique index is reported by Tree.SetData, which
+		// checks the indexes before calling this method
+		if err := t.reindex(n, newdata); err != nil {
+			return
+		}
+		defer t.check("SetData")
+	}
+	n.a.data[n.i] = newdata
+}
+
+func (n *arenaNode[K, T]) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		fmt.Fprintf(f, "{primary: %v parentID: %v data:%+v children:[", n.GetID(), n.GetParentID(), n.GetData())
+		i := 0
+		n.eachChild(func(c Node[K, T]) bool {
+			if i != 0 {
+				fmt.Fprint(f, " ")
+			}
+			fmt.Fprintf(f, "%v", c.GetID())
+			i++
+			return true
+		})
+		fmt.Fprint(f, "]}")
+	}
+}
@@ -0,0 +1,272 @@
+package tree
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// arenaOrderedTree builds the tree of orderedTree with the given options.
+func arenaOrderedTree(opts ...TreeOption) *Tree[uint, string] {
+	t := New[uint, string](opts...)
+	for n := range orderedTree().Traverse(TraverseBreadthFirst) {
+		t.Add(n.GetID(), n.GetParentID(), n.GetData())
+	}
+	return t
+}
+
+func TestArena(t *testing.T) {
+
+	var tests = map[string]struct {
+		change func(t *Tree[uint, string])
+	}{
+		"add": {
+			change: func(t *Tree[uint, string]) {
+				t.Add(7, 2, "g")
+				t.Add(8, 9, "h")
+				t.Add(5, 2, "f")
+			},
+		},
+		"reroot": {
+			change: func(t *Tree[uint, string]) {
+				t.Add(0, 7, "z")
+			},
+		},
+		"insert at": {
+			change: func(t *Tree[uint, string]) {
+				t.InsertAt(7, 1, 1, "g")
+				t.InsertAt(8, 3, 0, "h")
+			},
+		},
+		"move": {
+			change: func(t *Tree[uint, string]) {
+				t.Move(5, 2, 0)
+				t.Move(3, 4, -1)
+				t.MoveBefore(6, 4)
+				t.MoveAfter(2, 4)
+			},
+		},
+		"sort": {
+			change: func(t *Tree[uint, string]) {
+				t.SortChildren(1, func(a, b Node[uint, string]) bool { return a.GetData() < b.GetData() }, true)
+			},
+		},
+		"replace children": {
+			change: func(t *Tree[uint, string]) {
+				t.ReplaceChildren(1, 4, 5, 2)
+			},
+		},
+		"remove and merge": {
+			change: func(t *Tree[uint, string]) {
+				removed, _ := t.Remove(3)
+				removed.Add(7, 5, "g")
+				t.Merge(removed)
+			},
+		},
+		"remove root": {
+			change: func(t *Tree[uint, string]) {
+				t.Remove(1)
+				t.AddRoot(9, "i")
+				t.Add(10, 9, "j")
+			},
+		},
+		"set data": {
+			change: func(t *Tree[uint, string]) {
+				t.SetData(4, "x")
+				n, _ := t.Find(5)
+				n.SetData("y")
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			exp := arenaOrderedTree()
+			got := arenaOrderedTree(Arena(2), Debug())
+			tt.change(exp)
+			tt.change(got)
+
+			assert.NotNil(t, got.arena)
+			assert.Equal(t, exp.primary.len(), got.primary.len())
+			for _, trvsl := range []TraversalType{TraverseBreadthFirst, TraverseDepthFirst} {
+				assert.Equal(t, serialized(t, exp.Serialize, trvsl), serialized(t, got.Serialize, trvsl))
+			}
+			assert.Empty(t, got.Validate())
+		})
+	}
+}
+
+func TestArenaNodes(t *testing.T) {
+
+	tree := arenaOrderedTree(Arena(2))
+
+	n, ok := tree.Find(3)
+	assert.True(t, ok)
+	assert.Equal(t, uint(1), n.GetParent().GetID())
+	assert.Equal(t, uint(1), n.GetParentID())
+	assert.True(t, n.HasParentID())
+	assert.Equal(t, []uint{5, 6}, childIDs(n.GetChildren()))
+	assert.Equal(t, "{primary: 3 parentID: 1 data:c children:[5 6]}", fmt.Sprintf("%v", n))
+	assert.Nil(t, tree.Root().GetParent())
+
+	// the same node is returned each time
+	again, _ := tree.Find(3)
+	assert.Same(t, n, again)
+	assert.Same(t, n, n.GetChildren()[0].GetParent())
+
+	// failed additions leave no storage behind
+	size := len(tree.arena.ids)
+	tree.Add(3, 1, "x")
+	tree.Add(7, 9, "x")
+	assert.Equal(t, size, len(tree.arena.ids))
+
+	// removed nodes are copied, and the originals are detached
+	removed, ok := tree.Remove(3)
+	assert.True(t, ok)
+	r, _ := removed.Find(3)
+	assert.NotSame(t, n, r)
+	assert.Nil(t, n.GetParent())
+	assert.Empty(t, n.GetChildren())
+	assert.Equal(t, []uint{5, 6}, childIDs(r.GetChildren()))
+	assert.NotNil(t, removed.arena)
+	n.SetData("z")
+	assert.Equal(t, "c", r.GetData())
+
+	// a clone leaves the storage of removed nodes behind
+	assert.Equal(t, 3, len(tree.Clone(nil).arena.ids))
+
+	assert.Panics(t, func() { tree.Root().AddChildren(&BaseNode[uint, string]{primary: 9}) })
+}
+
+func TestArenaIndexes(t *testing.T) {
+
+	tree := arenaOrderedTree(Arena(2), Debug())
+	assert.NoError(t, tree.AddIndex("data", func(s string) any { return s }, true))
+
+	found, err := tree.SetData(2, "a")
+	assert.True(t, found)
+	assert.ErrorIs(t, err, ErrUniqueViolation)
+	n, _ := tree.Find(2)
+	n.SetData("a")
+	assert.Equal(t, "d", n.GetData())
+
+	n.SetData("z")
+	nodes, ok := tree.FindBy("data", "z")
+	assert.True(t, ok)
+	assert.Equal(t, []Node[uint, string]{n}, nodes)
+
+	other := Empty[uint, string]()
+	other.Add(7, 4, "y")
+	assert.True(t, tree.Merge(other))
+	nodes, _ = tree.FindBy("data", "y")
+	m, _ := tree.Find(7)
+	assert.Equal(t, []Node[uint, string]{m}, nodes)
+	o, _ := other.Find(7)
+	assert.NotSame(t, o, m)
+}
+
+// benchSize is the number of nodes of the trees built by the benchmarks.
+const benchSize = 100000
+
+// buildTree builds a benchmark tree of n nodes, in which each node has up to
+// eight children.
+func buildTree(n int, opts ...TreeOption) *Tree[int, int] {
+	t := New[int, int](opts...)
+	t.AddRoot(0, 0)
+	for i := 1; i < n; i++ {
+		t.Add(i, (i-1)/8, i)
+	}
+	return t
+}
+
+// benchLayouts are the node layouts compared by the benchmarks: the nodes
+// allocated one by one and linked by pointers, and the nodes of an arena.
+var benchLayouts = map[string][]TreeOption{
+	"pointer": nil,
+	"arena":   {Arena(benchSize)},
+}
+
+func BenchmarkAdd(b *testing.B) {
+
+	for name, opts := range benchLayouts {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buildTree(benchSize, opts...)
+			}
+		})
+	}
+}
+
+// heapGrowth returns the growth of the live heap over a call to build, whose
+// result is kept alive until the heap is measured.
+func heapGrowth(build func() any) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	v := build()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(v)
+	return after.HeapAlloc - before.HeapAlloc
+}
+
+// BenchmarkMemory reports the live heap taken by each node of a tree, as the
+// B/node metric.
+func BenchmarkMemory(b *testing.B) {
+
+	for name, opts := range benchLayouts {
+		b.Run(name, func(b *testing.B) {
+			var bytes uint64
+			for i := 0; i < b.N; i++ {
+				bytes += heapGrowth(func() any { return buildTree(benchSize, opts...) })
+			}
+			b.ReportMetric(float64(bytes)/float64(b.N*benchSize), "B/node")
+		})
+	}
+}
+
+// BenchmarkGC reports the time taken by a garbage collection while a tree is
+// live, which grows with the pointers the collector must scan.
+func BenchmarkGC(b *testing.B) {
+
+	for name, opts := range benchLayouts {
+		t := buildTree(benchSize, opts...)
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+			}
+		})
+		runtime.KeepAlive(t)
+	}
+}
+
+func BenchmarkFind(b *testing.B) {
+
+	for name, opts := range benchLayouts {
+		t := buildTree(benchSize, opts...)
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				t.Find(i % benchSize)
+			}
+		})
+	}
+}
+
+func BenchmarkTraverse(b *testing.B) {
+
+	for name, opts := range benchLayouts {
+		t := buildTree(benchSize, opts...)
+		for trvslName, trvsl := range map[string]TraversalType{"bfs": TraverseBreadthFirst, "dfs": TraverseDepthFirst} {
+			b.Run(name+"/"+trvslName, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					for range t.Traverse(trvsl) {
+					}
+				}
+			})
+		}
+	}
+}
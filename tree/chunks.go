@@ -0,0 +1,291 @@
+package tree
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// chunkIndexFile is the name of the index written by SerializeChunks.
+const chunkIndexFile = "index.jsonl"
+
+// chunkFile returns the name of a chunk file written by SerializeChunks.
+func chunkFile(n int) string {
+	return fmt.Sprintf("chunk-%06d.jsonl", n)
+}
+
+// chunkEntry is a line of the index written by SerializeChunks, locating the
+// children of a node.
+type chunkEntry[K comparable] struct {
+	ID K
+	// ParentID is nil for a root with no parent key
+	ParentID *K
+	// Chunk is the number of the chunk file holding the children of the
+	// node, or -1 if it has none
+	Chunk int
+}
+
+// SerializeChunks writes the tree to a directory as chunk files, which
+// ChunkLoader reads for a Lazy tree. Each chunk file holds the children of a
+// number of nodes in the format of Serialize, the children of any one node
+// being in a single chunk. The nodes are taken in depth first order, so that
+// each chunk holds a part of a subtree; a new chunk is started when the
+// current one holds at least size nodes. The root is in the first chunk.
+//
+// The directory also gets an index, with a line for each node giving its
+// parent and the chunk holding its children.
+func (t *Tree[K, T]) SerializeChunks(dir string, size int) (err error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	index, err := os.Create(filepath.Join(dir, chunkIndexFile))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := index.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	indexWriter := bufio.NewWriter(index)
+	indexEncoder := json.NewEncoder(indexWriter)
+
+	chunk, count := -1, 0
+	var file *os.File
+	var writer *bufio.Writer
+	var encoder *json.Encoder
+	closeChunk := func() error {
+		if file == nil {
+			return nil
+		}
+		if err := writer.Flush(); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+	nextChunk := func() error {
+		if err := closeChunk(); err != nil {
+			return err
+		}
+		chunk, count = chunk+1, 0
+		f, err := os.Create(filepath.Join(dir, chunkFile(chunk)))
+		if err != nil {
+			return err
+		}
+		file, writer = f, bufio.NewWriter(f)
+		encoder = json.NewEncoder(writer)
+		return nil
+	}
+	defer func() {
+		if cerr := closeChunk(); err == nil {
+			err = cerr
+		}
+	}()
+
+	if t.root == nil {
+		return indexWriter.Flush()
+	}
+	if err := nextChunk(); err != nil {
+		return err
+	}
+	if err := encoder.Encode(serialNode[K, T]{
+		Primary:  t.root.GetID(),
+		ParentID: t.root.GetParentID(),
+		Data:     t.root.GetData(),
+		noParent: !t.root.HasParentID(),
+	}); err != nil {
+		return err
+	}
+	count++
+
+	err = depthFirst(t.root, func(n Node[K, T], _ int) error {
+		entry := chunkEntry[K]{ID: n.GetID(), Chunk: -1}
+		if n.HasParentID() {
+			p := n.GetParentID()
+			entry.ParentID = &p
+		}
+
+		if children := n.GetChildren(); len(children) > 0 {
+			if count >= size {
+				if err := nextChunk(); err != nil {
+					return err
+				}
+			}
+			entry.Chunk = chunk
+			for i, c := range children {
+				err := encoder.Encode(serialNode[K, T]{
+					Primary:      c.GetID(),
+					ParentID:     c.GetParentID(),
+					SiblingIndex: i,
+					Data:         c.GetData(),
+				})
+				if err != nil {
+					return err
+				}
+			}
+			count += len(children)
+		}
+
+		return indexEncoder.Encode(entry)
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return indexWriter.Flush()
+}
+
+// ChunkLoader is a SubtreeLoader reading the chunk files written by
+// Tree.SerializeChunks. It holds the index of the tree in memory, which takes
+// far less memory than the nodes and their data, and keeps the last chunk it
+// read.
+type ChunkLoader[K comparable, T any] struct {
+	dir   string
+	root  K
+	empty bool
+	index map[K]chunkLocation[K]
+
+	// the last chunk read, by parent key
+	cached   int
+	children map[K][]StoredNode[K, T]
+}
+
+// chunkLocation is the entry of a node in the index of a ChunkLoader.
+type chunkLocation[K comparable] struct {
+	parent   K
+	noParent bool
+	chunk    int
+}
+
+// OpenChunks reads the index of the chunk files in a directory, written by
+// Tree.SerializeChunks, and returns a loader for them.
+func OpenChunks[K comparable, T any](dir string) (*ChunkLoader[K, T], error) {
+	f, err := os.Open(filepath.Join(dir, chunkIndexFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &ChunkLoader[K, T]{dir: dir, empty: true, index: map[K]chunkLocation[K]{}, cached: -1}
+	decoder := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var e chunkEntry[K]
+		err := decoder.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading chunk index: %w", err)
+		}
+
+		// the root is the first node of the index
+		if c.empty {
+			c.root, c.empty = e.ID, false
+		}
+		var loc chunkLocation[K]
+		if e.ParentID != nil {
+			loc.parent = *e.ParentID
+		} else {
+			loc.noParent = true
+		}
+		loc.chunk = e.Chunk
+		c.index[e.ID] = loc
+	}
+	return c, nil
+}
+
+// read reads a chunk file, returning the nodes it holds by parent key, and
+// the root if it is in the chunk.
+func (c *ChunkLoader[K, T]) read(chunk int) (map[K][]StoredNode[K, T], *StoredNode[K, T], error) {
+	f, err := os.Open(filepath.Join(c.dir, chunkFile(chunk)))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	children := map[K][]StoredNode[K, T]{}
+	var root *StoredNode[K, T]
+	decoder := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var n serialNode[K, T]
+		err := decoder.Decode(&n)
+		if err == io.EOF {
+			return children, root, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading chunk %d: %w", chunk, err)
+		}
+
+		s := StoredNode[K, T]{ID: n.Primary, ParentID: n.ParentID, NoParent: n.noParent, Data: n.Data}
+		s.HasChildren = c.index[n.Primary].chunk >= 0
+		if n.Primary == c.root && root == nil {
+			root = &s
+			continue
+		}
+		children[n.ParentID] = append(children[n.ParentID], s)
+	}
+}
+
+// LoadRoot implements SubtreeLoader.
+func (c *ChunkLoader[K, T]) LoadRoot() (root StoredNode[K, T], ok bool, err error) {
+	if c.empty {
+		return
+	}
+	_, r, err := c.read(0)
+	if err != nil {
+		return root, false, err
+	}
+	if r == nil {
+		return root, false, errors.New("root missing from chunk 0")
+	}
+	return *r, true, nil
+}
+
+// LoadChildren implements SubtreeLoader.
+func (c *ChunkLoader[K, T]) LoadChildren(id K) ([]StoredNode[K, T], error) {
+	loc, ok := c.index[id]
+	if !ok || loc.chunk < 0 {
+		return nil, nil
+	}
+	if loc.chunk != c.cached {
+		children, _, err := c.read(loc.chunk)
+		if err != nil {
+			return nil, err
+		}
+		c.cached, c.children = loc.chunk, children
+	}
+	return c.children[id], nil
+}
+
+// LoadPath implements SubtreeLoader. It returns an error if the index does
+// not lead from the node up to the root.
+func (c *ChunkLoader[K, T]) LoadPath(id K) (path []K, ok bool, err error) {
+	if _, ok := c.index[id]; !ok {
+		return nil, false, nil
+	}
+	for node := id; node != c.root; {
+		loc := c.index[node]
+		if loc.noParent {
+			return nil, false, fmt.Errorf("broken chunk index: %v has no parent but is not the root", node)
+		}
+		// a path longer than the index goes round a cycle
+		if len(path) == len(c.index) {
+			return nil, false, fmt.Errorf("broken chunk index: cycle above %v", id)
+		}
+		node = loc.parent
+		if _, ok := c.index[node]; !ok {
+			return nil, false, fmt.Errorf("broken chunk index: parent %v of %v missing", node, id)
+		}
+		path = append(path, node)
+	}
+	// reverse, to start at the root
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, true, nil
+}
@@ -0,0 +1,122 @@
+package tree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerializeChunks(t *testing.T) {
+
+	tree := bigTree(300)
+	dir := t.TempDir()
+	assert.NoError(t, tree.SerializeChunks(dir, 20))
+
+	files, err := filepath.Glob(filepath.Join(dir, "chunk-*.jsonl"))
+	assert.NoError(t, err)
+	assert.Greater(t, len(files), 10)
+
+	loader, err := OpenChunks[uint, string](dir)
+	assert.NoError(t, err)
+
+	root, ok, err := loader.LoadRoot()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, StoredNode[uint, string]{ID: 0, NoParent: true, Data: "root", HasChildren: true}, root)
+
+	for n := range tree.Traverse(TraverseBreadthFirst) {
+		children, err := loader.LoadChildren(n.GetID())
+		assert.NoError(t, err)
+		assert.Len(t, children, len(n.GetChildren()))
+		for i, c := range n.GetChildren() {
+			assert.Equal(t, StoredNode[uint, string]{
+				ID:          c.GetID(),
+				ParentID:    n.GetID(),
+				Data:        c.GetData(),
+				HasChildren: len(c.GetChildren()) > 0,
+			}, children[i])
+		}
+
+		var exp []uint
+		parents, _ := tree.FindParents(n.GetID())
+		for i := len(parents) - 1; i >= 0; i-- {
+			exp = append(exp, parents[i].GetID())
+		}
+		path, ok, err := loader.LoadPath(n.GetID())
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, exp, path)
+	}
+
+	_, ok, err = loader.LoadPath(1000)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestSerializeChunksEmpty(t *testing.T) {
+
+	dir := t.TempDir()
+	assert.NoError(t, Empty[uint, string]().SerializeChunks(dir, 20))
+
+	loader, err := OpenChunks[uint, string](dir)
+	assert.NoError(t, err)
+	_, ok, err := loader.LoadRoot()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestOpenChunksError(t *testing.T) {
+
+	_, err := OpenChunks[uint, string](t.TempDir())
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, chunkIndexFile), []byte(`{"ID":`), 0o644))
+	_, err = OpenChunks[uint, string](dir)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "error reading chunk index")
+}
+
+func TestLoadPathCorrupt(t *testing.T) {
+
+	var tests = map[string]struct {
+		index string
+		err   string
+	}{
+		"missing parent": {
+			index: `{"ID":1,"ParentID":null,"Chunk":0}
+{"ID":2,"ParentID":1,"Chunk":0}
+{"ID":3,"ParentID":9,"Chunk":-1}`,
+			err: "parent 9 of 3 missing",
+		},
+		"second root": {
+			index: `{"ID":1,"ParentID":null,"Chunk":0}
+{"ID":2,"ParentID":4,"Chunk":0}
+{"ID":3,"ParentID":2,"Chunk":-1}
+{"ID":4,"ParentID":null,"Chunk":0}`,
+			err: "4 has no parent but is not the root",
+		},
+		"cycle": {
+			index: `{"ID":1,"ParentID":null,"Chunk":0}
+{"ID":2,"ParentID":3,"Chunk":0}
+{"ID":3,"ParentID":2,"Chunk":0}`,
+			err: "cycle above 3",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, chunkIndexFile), []byte(tt.index), 0o644))
+			loader, err := OpenChunks[uint, string](dir)
+			assert.NoError(t, err)
+
+			path, ok, err := loader.LoadPath(3)
+			assert.EqualError(t, err, "broken chunk index: "+tt.err)
+			assert.False(t, ok)
+			assert.Nil(t, path)
+		})
+	}
+}
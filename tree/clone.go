@@ -0,0 +1,123 @@
+package tree
+
+// Clone returns a deep copy of a tree. Every node of the tree is copied, and
+// the copies are linked and indexed exactly as the originals, so that
+// modifications to either tree afterwards have no effect on the other.
+//
+// The argument copyData is called on the data of every node to produce the
+// data of its copy. If copyData is nil, the data is assigned as-is; if the
+// data is a pointer, a map or a slice, the data will then be shared between
+// both trees.
+//
+// The secondary indexes of the tree are copied, with the keys of the original
+// nodes, so copyData must return data with the same index keys. If the tree
+// was created with NodeFactory, the copies are new nodes from the factory, so
+// that fields added to the nodes are not copied. If the tree has the Arena
+// option, the copy holds only the nodes that are in the tree, without the
+// storage of the nodes removed from it.
+func (t *Tree[K, T]) Clone(copyData func(T) T) *Tree[K, T] {
+	c := withConfig[K, T](t.config)
+	if t.root != nil {
+		c.graft(t.root, nil, copyData)
+	}
+
+	// the indexes are copied once all nodes are, keeping the order of the
+	// nodes under each key
+	for name, idx := range t.secondary {
+		cidx := newDataIndex[K, T](idx.keyFn, idx.unique)
+		for key, nodes := range idx.keys {
+			for _, n := range nodes {
+				cidx.insert(key, c.primary.find(n.GetID()))
+			}
+		}
+		if c.secondary == nil {
+			c.secondary = map[string]*dataIndex[K, T]{}
+		}
+		c.secondary[name] = cidx
+	}
+
+	return c
+}
+
+// EqualOption modifies the comparison performed by Equal.
+type EqualOption func(*equalConfig)
+
+type equalConfig struct {
+	ignoreOrder bool
+}
+
+// IgnoreChildOrder makes Equal treat the children of a node as a set; two
+// nodes are equal if they have the same children in any order.
+func IgnoreChildOrder() EqualOption {
+	return func(c *equalConfig) {
+		c.ignoreOrder = true
+	}
+}
+
+// Equal reports whether two trees have the same shape and data. Two trees are
+// equal if their roots are equal, and two nodes are equal if they have the
+// same primary key and parent key, their data is equal, and their children
+// are equal. By default children must also be in the same order; this can
+// be relaxed with the IgnoreChildOrder option.
+//
+// The argument eq compares the data of two nodes. If eq is nil, node data is
+// not compared and only the shape of the trees is considered.
+//
+// Two nil or empty trees are equal to each other.
+func Equal[K comparable, T any](a, b *Tree[K, T], eq func(T, T) bool, opts ...EqualOption) bool {
+	var cfg equalConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var ra, rb Node[K, T]
+	if a != nil {
+		ra = a.root
+	}
+	if b != nil {
+		rb = b.root
+	}
+	if ra == nil || rb == nil {
+		return ra == nil && rb == nil
+	}
+
+	stack := [][2]Node[K, T]{{ra, rb}}
+	for len(stack) > 0 {
+		na, nb := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		if na.GetID() != nb.GetID() || na.GetParentID() != nb.GetParentID() {
+			return false
+		}
+		if eq != nil && !eq(na.GetData(), nb.GetData()) {
+			return false
+		}
+
+		ca, cb := na.GetChildren(), nb.GetChildren()
+		if len(ca) != len(cb) {
+			return false
+		}
+
+		if !cfg.ignoreOrder {
+			for i := range ca {
+				stack = append(stack, [2]Node[K, T]{ca[i], cb[i]})
+			}
+			continue
+		}
+
+		// primary keys are unique, so children can be matched by key
+		byID := make(map[K]Node[K, T], len(cb))
+		for _, c := range cb {
+			byID[c.GetID()] = c
+		}
+		for _, c := range ca {
+			m, ok := byID[c.GetID()]
+			if !ok {
+				return false
+			}
+			stack = append(stack, [2]Node[K, T]{c, m})
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,212 @@
+package tree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClone(t *testing.T) {
+
+	var tests = map[string]struct {
+		prep     func() *Tree[uint, []int]
+		copyData func([]int) []int
+		expBFC   []uint
+		expDFC   []uint
+		shared   bool
+	}{
+		"empty": {
+			prep:   Empty[uint, []int],
+			expBFC: []uint{},
+			expDFC: []uint{},
+		},
+		"shallow data": {
+			prep: func() *Tree[uint, []int] {
+				t := Empty[uint, []int]()
+				t.Add(1, 0, []int{1})
+				t.Add(2, 1, []int{2})
+				t.Add(3, 2, []int{3})
+				t.Add(4, 1, []int{4})
+				return t
+			},
+			expBFC: []uint{1, 2, 4, 3},
+			expDFC: []uint{1, 2, 3, 4},
+			shared: true,
+		},
+		"deep data": {
+			prep: func() *Tree[uint, []int] {
+				t := Empty[uint, []int]()
+				t.Add(1, 0, []int{1})
+				t.Add(2, 1, []int{2})
+				t.Add(3, 2, []int{3})
+				t.Add(4, 1, []int{4})
+				return t
+			},
+			copyData: func(d []int) []int {
+				return append([]int{}, d...)
+			},
+			expBFC: []uint{1, 2, 4, 3},
+			expDFC: []uint{1, 2, 3, 4},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			orig := tt.prep()
+			got := orig.Clone(tt.copyData)
+
+			assert.Equal(t, tt.expBFC, bfc([]Node[uint, []int]{got.root}, []uint{}))
+			assert.Equal(t, tt.expDFC, dfc(got.root, []uint{}))
+			assert.Equal(t, orig.primary.len(), got.primary.len())
+
+			for _, key := range tt.expBFC {
+				o, g := orig.primary.find(key), got.primary.find(key)
+				if assert.NotNil(t, g, "Expected value for %d not to be nil", key) {
+					assert.NotSame(t, o, g)
+					assert.Equal(t, o.GetData(), g.GetData())
+					if o.GetParent() != nil {
+						assert.Same(t, got.primary.find(o.GetParentID()), g.GetParent())
+					}
+				}
+			}
+
+			if len(tt.expBFC) == 0 {
+				return
+			}
+
+			// changes to the clone do not reach the original
+			got.Add(100, tt.expBFC[0], nil)
+			_, found := orig.Find(100)
+			assert.False(t, found)
+
+			o, g := orig.primary.find(1), got.primary.find(1)
+			g.GetData()[0] = 42
+			assert.Equal(t, tt.shared, o.GetData()[0] == 42)
+		})
+	}
+}
+
+func TestCloneIndexes(t *testing.T) {
+
+	orig := indexedTree()
+	assert.NoError(t, orig.AddIndex("slug", slugKey, true))
+	assert.NoError(t, orig.AddIndex("kind", kindKey, false))
+	got := orig.Clone(nil)
+
+	nodes, ok := got.FindBy("kind", "file")
+	assert.True(t, ok)
+	var ids []uint
+	for _, n := range nodes {
+		assert.Same(t, got.primary.find(n.GetID()), n)
+		ids = append(ids, n.GetID())
+	}
+	assert.Equal(t, []uint{4, 3}, ids)
+
+	// the unique index is kept on the clone and separate from the original
+	found, err := got.SetData(3, indexed{"a", "file"})
+	assert.True(t, found)
+	assert.ErrorIs(t, err, ErrUniqueViolation)
+	found, err = got.SetData(3, indexed{"c", "file"})
+	assert.True(t, found)
+	assert.NoError(t, err)
+	_, ok = orig.FindBy("slug", "c")
+	assert.False(t, ok)
+	nodes, ok = got.FindBy("slug", "c")
+	assert.True(t, ok)
+	assert.Same(t, got.primary.find(3), nodes[0])
+	assert.Empty(t, got.Validate())
+}
+
+func TestEqual(t *testing.T) {
+
+	build := func(adds ...addInput) *Tree[uint, int] {
+		t := Empty[uint, int]()
+		for _, in := range adds {
+			t.Add(in.nodeID, in.parentID, int(in.nodeID))
+		}
+		return t
+	}
+
+	eqInt := func(a, b int) bool { return a == b }
+
+	var tests = map[string]struct {
+		a, b  *Tree[uint, int]
+		eq    func(int, int) bool
+		opts  []EqualOption
+		expEq bool
+	}{
+		"both nil": {
+			expEq: true,
+		},
+		"nil and empty": {
+			b:     Empty[uint, int](),
+			expEq: true,
+		},
+		"empty and non-empty": {
+			a:     Empty[uint, int](),
+			b:     build(addInput{1, 0}),
+			expEq: false,
+		},
+		"same": {
+			a:     build(addInput{1, 0}, addInput{2, 1}, addInput{3, 1}),
+			b:     build(addInput{1, 0}, addInput{2, 1}, addInput{3, 1}),
+			eq:    eqInt,
+			expEq: true,
+		},
+		"different keys": {
+			a:     build(addInput{1, 0}, addInput{2, 1}, addInput{3, 1}),
+			b:     build(addInput{1, 0}, addInput{2, 1}, addInput{4, 1}),
+			expEq: false,
+		},
+		"different shape": {
+			a:     build(addInput{1, 0}, addInput{2, 1}, addInput{3, 1}),
+			b:     build(addInput{1, 0}, addInput{2, 1}, addInput{3, 2}),
+			expEq: false,
+		},
+		"different child order": {
+			a:     build(addInput{1, 0}, addInput{2, 1}, addInput{3, 1}),
+			b:     build(addInput{1, 0}, addInput{3, 1}, addInput{2, 1}),
+			expEq: false,
+		},
+		"ignore child order": {
+			a:     build(addInput{1, 0}, addInput{2, 1}, addInput{3, 1}, addInput{4, 3}),
+			b:     build(addInput{1, 0}, addInput{3, 1}, addInput{4, 3}, addInput{2, 1}),
+			opts:  []EqualOption{IgnoreChildOrder()},
+			expEq: true,
+		},
+		"ignore child order - different children": {
+			a:     build(addInput{1, 0}, addInput{2, 1}, addInput{3, 1}),
+			b:     build(addInput{1, 0}, addInput{3, 1}, addInput{4, 1}),
+			opts:  []EqualOption{IgnoreChildOrder()},
+			expEq: false,
+		},
+		"different data": {
+			a: build(addInput{1, 0}, addInput{2, 1}),
+			b: func() *Tree[uint, int] {
+				t := build(addInput{1, 0}, addInput{2, 1})
+				n, _ := t.Find(2)
+				n.SetData(7)
+				return t
+			}(),
+			eq:    eqInt,
+			expEq: false,
+		},
+		"different data - not compared": {
+			a: build(addInput{1, 0}, addInput{2, 1}),
+			b: func() *Tree[uint, int] {
+				t := build(addInput{1, 0}, addInput{2, 1})
+				n, _ := t.Find(2)
+				n.SetData(7)
+				return t
+			}(),
+			expEq: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expEq, Equal(tt.a, tt.b, tt.eq, tt.opts...))
+			assert.Equal(t, tt.expEq, Equal(tt.b, tt.a, tt.eq, tt.opts...))
+		})
+	}
+}
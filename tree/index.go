@@ -1,6 +1,22 @@
 package tree
 
-import "log"
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// keyIndex is the primary index of a tree, mapping the primary key of each
+// node to the node.
+type keyIndex[K comparable, T any] interface {
+	find(id K) Node[K, T]
+	insert(id K, node Node[K, T]) bool
+	remove(id K)
+	len() int
+	// each calls visit on every key and node of the index, in no particular
+	// order, until visit returns false.
+	each(visit func(K, Node[K, T]) bool)
+}
 
 type index[K comparable, T any] map[K]Node[K, T]
 
@@ -26,3 +42,242 @@ func (idx *index[K, T]) insert(id K, node Node[K, T]) bool {
 	m[id] = node
 	return true
 }
+
+func (idx *index[K, T]) remove(id K) {
+	delete(*idx, id)
+}
+
+func (idx *index[K, T]) len() int {
+	return len(*idx)
+}
+
+func (idx *index[K, T]) each(visit func(K, Node[K, T]) bool) {
+	for k, n := range *idx {
+		if !visit(k, n) {
+			return
+		}
+	}
+}
+
+// slotIndex is the primary index of a tree with the Arena option, mapping
+// the primary key of each node to its number in the arena, so that the index
+// holds no pointers.
+type slotIndex[K comparable, T any] struct {
+	slots map[K]int32
+	arena *arena[K, T]
+}
+
+func newSlotIndex[K comparable, T any](a *arena[K, T]) *slotIndex[K, T] {
+	return &slotIndex[K, T]{slots: map[K]int32{}, arena: a}
+}
+
+func (idx *slotIndex[K, T]) find(id K) Node[K, T] {
+	i, exists := idx.slots[id]
+	if !exists {
+		return nil
+	}
+	return idx.arena.node(i)
+}
+
+func (idx *slotIndex[K, T]) insert(id K, node Node[K, T]) bool {
+	idx.slots[id] = idx.arena.own(node)
+	return true
+}
+
+func (idx *slotIndex[K, T]) remove(id K) {
+	delete(idx.slots, id)
+}
+
+func (idx *slotIndex[K, T]) len() int {
+	return len(idx.slots)
+}
+
+func (idx *slotIndex[K, T]) each(visit func(K, Node[K, T]) bool) {
+	for k, i := range idx.slots {
+		if !visit(k, idx.arena.node(i)) {
+			return
+		}
+	}
+}
+
+var (
+	// ErrIndexExists is returned when adding a secondary index with the name of
+	// an index that already exists.
+	ErrIndexExists = errors.New("tree: index already exists")
+	// ErrUniqueViolation is returned when an operation would result in two
+	// nodes having the same key in a unique secondary index.
+	ErrUniqueViolation = errors.New("tree: unique index violation")
+)
+
+// dataIndex is a secondary index mapping a key derived from node data to the
+// nodes with that key. Nodes sharing a key are kept in insertion order.
+type dataIndex[K comparable, T any] struct {
+	keyFn  func(T) any
+	unique bool
+	keys   map[any][]Node[K, T]
+}
+
+func newDataIndex[K comparable, T any](keyFn func(T) any, unique bool) *dataIndex[K, T] {
+	return &dataIndex[K, T]{keyFn: keyFn, unique: unique, keys: map[any][]Node[K, T]{}}
+}
+
+// conflicts reports whether n cannot be indexed under key because another
+// node already holds that key in a unique index.
+func (d *dataIndex[K, T]) conflicts(key any, n Node[K, T]) bool {
+	if !d.unique || key == nil {
+		return false
+	}
+	for _, m := range d.keys[key] {
+		if m.GetID() != n.GetID() {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *dataIndex[K, T]) insert(key any, n Node[K, T]) {
+	if key == nil {
+		return
+	}
+	d.keys[key] = append(d.keys[key], n)
+}
+
+func (d *dataIndex[K, T]) remove(key any, n Node[K, T]) {
+	if key == nil {
+		return
+	}
+	nodes := d.keys[key]
+	for i, m := range nodes {
+		if m.GetID() == n.GetID() {
+			nodes = append(nodes[:i], nodes[i+1:]...)
+			break
+		}
+	}
+	if len(nodes) == 0 {
+		delete(d.keys, key)
+		return
+	}
+	d.keys[key] = nodes
+}
+
+// AddIndex creates a secondary index over the data of the nodes in the tree.
+// The argument keyFn derives the index key from the data of a node; the key
+// must be a comparable value, and a nil key leaves the node out of the index.
+// All nodes already in the tree are indexed, and the index is kept up to date
+// as nodes are added, merged or removed and as their data is changed.
+//
+// If unique is true, no two nodes may share a key. Any insertion or data
+// change that would break this is rejected.
+//
+// Returns ErrIndexExists if an index with the same name already exists, or
+// ErrUniqueViolation if the index is unique and the existing nodes of the
+// tree do not have unique keys. In either case the index is not created.
+func (t *Tree[K, T]) AddIndex(name string, keyFn func(T) any, unique bool) error {
+	if _, ok := t.secondary[name]; ok {
+		return fmt.Errorf("%w: %s", ErrIndexExists, name)
+	}
+	defer t.check("AddIndex")
+
+	idx := newDataIndex[K, T](keyFn, unique)
+	var err error
+	breadthFirst(func(n Node[K, T]) bool {
+		key := keyFn(n.GetData())
+		if idx.conflicts(key, n) {
+			err = fmt.Errorf("%w: index %s, key %v", ErrUniqueViolation, name, key)
+			return false
+		}
+		idx.insert(key, n)
+		return true
+	}, t.root)
+	if err != nil {
+		return err
+	}
+
+	if t.secondary == nil {
+		t.secondary = map[string]*dataIndex[K, T]{}
+	}
+	t.secondary[name] = idx
+	return nil
+}
+
+// DropIndex removes a secondary index from the tree. Returns false if no
+// index with the given name exists.
+func (t *Tree[K, T]) DropIndex(name string) bool {
+	if _, ok := t.secondary[name]; !ok {
+		return false
+	}
+	delete(t.secondary, name)
+	return true
+}
+
+// FindBy looks up nodes by their key in a secondary index. If any nodes are
+// found, then ok is true and the nodes are returned in the order in which they
+// were indexed. If the index does not exist or has no nodes with the key, then
+// ok is false and a nil array is returned.
+func (t *Tree[K, T]) FindBy(name string, key any) (nodes []Node[K, T], ok bool) {
+	idx, exists := t.secondary[name]
+	if !exists {
+		return
+	}
+	found := idx.keys[key]
+	if len(found) == 0 {
+		return
+	}
+	return append([]Node[K, T]{}, found...), true
+}
+
+// checkIndexes returns an error if the node n holding data would violate a
+// unique secondary index of the tree.
+func (t *Tree[K, T]) checkIndexes(n Node[K, T], data T) error {
+	for name, idx := range t.secondary {
+		if key := idx.keyFn(data); idx.conflicts(key, n) {
+			return fmt.Errorf("%w: index %s, key %v", ErrUniqueViolation, name, key)
+		}
+	}
+	return nil
+}
+
+func (t *Tree[K, T]) indexData(n Node[K, T], data T) {
+	for _, idx := range t.secondary {
+		idx.insert(idx.keyFn(data), n)
+	}
+}
+
+func (t *Tree[K, T]) unindexData(n Node[K, T], data T) {
+	for _, idx := range t.secondary {
+		idx.remove(idx.keyFn(data), n)
+	}
+}
+
+// SetData replaces the data of a node, identified by its primary key, and
+// updates the secondary indexes of the tree. If the node is found, then found
+// is true.
+//
+// If the new data conflicts with another node in a unique index, this method
+// returns ErrUniqueViolation and leaves the data unchanged. Node.SetData
+// leaves the data unchanged as well, but cannot report the violation.
+func (t *Tree[K, T]) SetData(id K, data T) (found bool, err error) {
+	n := t.primary.find(id)
+	if n == nil {
+		return
+	}
+	if err = t.checkIndexes(n, data); err != nil {
+		return true, err
+	}
+	n.SetData(data)
+	return true, nil
+}
+
+// reindex moves a node in the secondary indexes from the keys of its current
+// data to the keys of data.
+func (t *Tree[K, T]) reindex(n Node[K, T], data T) error {
+	if len(t.secondary) == 0 {
+		return nil
+	}
+	if err := t.checkIndexes(n, data); err != nil {
+		return err
+	}
+	t.unindexData(n, n.GetData())
+	t.indexData(n, data)
+	return nil
+}
@@ -8,8 +8,8 @@ import (
 
 func TestIndexFind(t *testing.T) {
 
-	node1 := &node[uint, int]{primary: 1}
-	node2 := &node[uint, int]{primary: 2}
+	node1 := &BaseNode[uint, int]{primary: 1}
+	node2 := &BaseNode[uint, int]{primary: 2}
 
 	tests := map[string]struct {
 		index   index[uint, int]
@@ -47,3 +47,242 @@ func TestIndexFind(t *testing.T) {
 		})
 	}
 }
+
+type indexed struct {
+	Slug string
+	Kind string
+}
+
+func slugKey(d indexed) any {
+	if d.Slug == "" {
+		return nil
+	}
+	return d.Slug
+}
+
+func kindKey(d indexed) any {
+	return d.Kind
+}
+
+func indexedTree() *Tree[uint, indexed] {
+	t := Empty[uint, indexed]()
+	t.Add(1, 0, indexed{"root", "dir"})
+	t.Add(2, 1, indexed{"a", "dir"})
+	t.Add(3, 2, indexed{"b", "file"})
+	t.Add(4, 1, indexed{"", "file"})
+	return t
+}
+
+func TestAddIndex(t *testing.T) {
+
+	var tests = map[string]struct {
+		prep   func() *Tree[uint, indexed]
+		name   string
+		keyFn  func(indexed) any
+		unique bool
+		expErr error
+	}{
+		"empty tree": {
+			prep:   Empty[uint, indexed],
+			name:   "slug",
+			keyFn:  slugKey,
+			unique: true,
+		},
+		"unique": {
+			prep:   indexedTree,
+			name:   "slug",
+			keyFn:  slugKey,
+			unique: true,
+		},
+		"not unique": {
+			prep:  indexedTree,
+			name:  "kind",
+			keyFn: kindKey,
+		},
+		"unique violation": {
+			prep:   indexedTree,
+			name:   "kind",
+			keyFn:  kindKey,
+			unique: true,
+			expErr: ErrUniqueViolation,
+		},
+		"index exists": {
+			prep: func() *Tree[uint, indexed] {
+				t := indexedTree()
+				t.AddIndex("slug", slugKey, true)
+				return t
+			},
+			name:   "slug",
+			keyFn:  kindKey,
+			expErr: ErrIndexExists,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tree := tt.prep()
+			gotErr := tree.AddIndex(tt.name, tt.keyFn, tt.unique)
+
+			assert.ErrorIs(t, gotErr, tt.expErr)
+			if tt.expErr == nil {
+				_, ok := tree.secondary[tt.name]
+				assert.True(t, ok)
+			}
+		})
+	}
+}
+
+func TestFindBy(t *testing.T) {
+
+	var tests = map[string]struct {
+		mutate     func(*testing.T, *Tree[uint, indexed])
+		index      string
+		key        any
+		expNodeIDs []uint
+		expOK      bool
+	}{
+		"unique": {
+			index:      "slug",
+			key:        "b",
+			expNodeIDs: []uint{3},
+			expOK:      true,
+		},
+		"not unique": {
+			index:      "kind",
+			key:        "dir",
+			expNodeIDs: []uint{1, 2},
+			expOK:      true,
+		},
+		"nil key not indexed": {
+			index: "slug",
+			key:   nil,
+		},
+		"key not found": {
+			index: "slug",
+			key:   "c",
+		},
+		"index not found": {
+			index: "other",
+			key:   "a",
+		},
+		"after add": {
+			mutate: func(t *testing.T, tree *Tree[uint, indexed]) {
+				added, _ := tree.Add(5, 2, indexed{"c", "file"})
+				assert.True(t, added)
+			},
+			index:      "kind",
+			key:        "file",
+			expNodeIDs: []uint{4, 3, 5},
+			expOK:      true,
+		},
+		"add rejected": {
+			mutate: func(t *testing.T, tree *Tree[uint, indexed]) {
+				added, exists := tree.Add(5, 2, indexed{"b", "file"})
+				assert.False(t, added)
+				assert.False(t, exists)
+				_, found := tree.Find(5)
+				assert.False(t, found)
+			},
+			index:      "kind",
+			key:        "file",
+			expNodeIDs: []uint{4, 3},
+			expOK:      true,
+		},
+		"after set data": {
+			mutate: func(t *testing.T, tree *Tree[uint, indexed]) {
+				n, _ := tree.Find(3)
+				n.SetData(indexed{"c", "file"})
+			},
+			index:      "slug",
+			key:        "c",
+			expNodeIDs: []uint{3},
+			expOK:      true,
+		},
+		"old key after set data": {
+			mutate: func(t *testing.T, tree *Tree[uint, indexed]) {
+				found, err := tree.SetData(3, indexed{"c", "file"})
+				assert.True(t, found)
+				assert.NoError(t, err)
+			},
+			index: "slug",
+			key:   "b",
+		},
+		"set data rejected": {
+			mutate: func(t *testing.T, tree *Tree[uint, indexed]) {
+				found, err := tree.SetData(3, indexed{"a", "file"})
+				assert.True(t, found)
+				assert.ErrorIs(t, err, ErrUniqueViolation)
+
+				n, _ := tree.Find(2)
+				n.SetData(indexed{"b", "dir"})
+				assert.Equal(t, indexed{"a", "dir"}, n.GetData())
+			},
+			index:      "slug",
+			key:        "a",
+			expNodeIDs: []uint{2},
+			expOK:      true,
+		},
+		"after merge": {
+			mutate: func(t *testing.T, tree *Tree[uint, indexed]) {
+				other := Empty[uint, indexed]()
+				other.Add(5, 4, indexed{"c", "dir"})
+				other.Add(6, 5, indexed{"d", "dir"})
+				assert.True(t, tree.Merge(other))
+
+				// merged nodes follow the indexes of the target tree
+				n, _ := tree.Find(6)
+				n.SetData(indexed{"e", "file"})
+			},
+			index:      "kind",
+			key:        "dir",
+			expNodeIDs: []uint{1, 2, 5},
+			expOK:      true,
+		},
+		"merge rejected": {
+			mutate: func(t *testing.T, tree *Tree[uint, indexed]) {
+				other := Empty[uint, indexed]()
+				other.Add(5, 4, indexed{"c", "dir"})
+				other.Add(6, 5, indexed{"a", "dir"})
+				assert.False(t, tree.Merge(other))
+			},
+			index: "slug",
+			key:   "c",
+		},
+		"after remove": {
+			mutate: func(t *testing.T, tree *Tree[uint, indexed]) {
+				removed, ok := tree.Remove(2)
+				assert.True(t, ok)
+
+				// removed nodes no longer affect the indexes
+				n, _ := removed.Find(3)
+				n.SetData(indexed{"root", "dir"})
+			},
+			index:      "kind",
+			key:        "dir",
+			expNodeIDs: []uint{1},
+			expOK:      true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tree := indexedTree()
+			assert.NoError(t, tree.AddIndex("slug", slugKey, true))
+			assert.NoError(t, tree.AddIndex("kind", kindKey, false))
+
+			if tt.mutate != nil {
+				tt.mutate(t, tree)
+			}
+
+			gotNodes, gotOK := tree.FindBy(tt.index, tt.key)
+
+			var gotNodeIDs []uint
+			for _, n := range gotNodes {
+				gotNodeIDs = append(gotNodeIDs, n.GetID())
+			}
+
+			assert.Equal(t, tt.expOK, gotOK)
+			assert.Equal(t, tt.expNodeIDs, gotNodeIDs)
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package tree
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned by the methods of a Lazy tree for a node that is
+// not in the tree.
+var ErrNotFound = errors.New("tree: node not found")
+
+// StoredNode is a node of a tree kept in storage, as returned by a
+// SubtreeLoader.
+type StoredNode[K comparable, T any] struct {
+	ID       K
+	ParentID K
+	// NoParent is set on a root with no parent key
+	NoParent bool
+	Data     T
+	// HasChildren reports whether the node has children in storage
+	HasChildren bool
+}
+
+// SubtreeLoader reads the nodes of a tree kept in storage for a Lazy tree.
+// ChunkLoader implements it for the files written by Tree.SerializeChunks;
+// other storage, such as a key-value store, can be read by implementing it.
+type SubtreeLoader[K comparable, T any] interface {
+	// LoadRoot returns the root of the tree. If the tree is empty, ok is
+	// false.
+	LoadRoot() (root StoredNode[K, T], ok bool, err error)
+	// LoadChildren returns the children of a node, in order.
+	LoadChildren(id K) ([]StoredNode[K, T], error)
+	// LoadPath returns the keys of the ancestors of a node, from the root to
+	// its parent. If the node is not in the tree, ok is false.
+	LoadPath(id K) (path []K, ok bool, err error)
+}
+
+// Lazy is a tree that is kept in storage and loaded on demand, for trees too
+// large to hold in memory. Only the root is loaded when the tree is created;
+// the children of a node are loaded through its SubtreeLoader when they are
+// first needed by Find, Load or Children.
+//
+// A Lazy tree holds at most about budget nodes in memory, if budget is
+// positive. When more are loaded, the children of the nodes whose children
+// were used least recently are evicted, together with their descendants, to
+// be loaded again when they are next needed. The nodes used by the call that
+// loads them are never evicted by that call, so a tree may go over its budget
+// by the nodes on the path to a node.
+//
+// The nodes of a Lazy tree are Node values, held in a Tree. The getters of a
+// node never load: GetChildren returns only the children that are loaded, and
+// so returns no children for a node whose children are in storage but not
+// loaded, which Loaded tells apart from a node with no children. The children
+// of a node are read with Children. An evicted node is
+// detached from the tree, and must be found again with Find to go further
+// down the tree. Changes made to the data of a node are lost when it is
+// evicted, since they are not written back to storage.
+//
+// A Lazy tree is not safe for concurrent use.
+type Lazy[K comparable, T any] struct {
+	loader SubtreeLoader[K, T]
+	budget int
+	tree   *Tree[K, T]
+
+	// used holds the nodes whose children are loaded, the most recently used
+	// first
+	used *list.List
+	// op counts the calls to the tree; the nodes used by the current call
+	// are not evicted
+	op uint64
+}
+
+// lazyNode is a node of a Lazy tree.
+type lazyNode[K comparable, T any] struct {
+	BaseNode[K, T]
+	lazy *Lazy[K, T]
+	// stored is set if the node has children in storage, and loaded if they
+	// are in memory, in which case elem is its entry in lazy.used
+	stored, loaded bool
+	elem           *list.Element
+	// lastOp is the last call to the tree that used the node
+	lastOp uint64
+}
+
+// NewLazy creates a Lazy tree reading its nodes with loader, and holding about
+// budget nodes in memory, or any number if budget is not positive. Only the
+// root of the tree is loaded, so that GetChildren on the root returns no
+// children until they are loaded by Find, Load or Children.
+func NewLazy[K comparable, T any](loader SubtreeLoader[K, T], budget int) (*Lazy[K, T], error) {
+	l := &Lazy[K, T]{loader: loader, budget: budget, used: list.New()}
+	l.tree = New[K, T](NodeFactory(func() Node[K, T] {
+		return &lazyNode[K, T]{lazy: l}
+	}))
+
+	root, ok, err := loader.LoadRoot()
+	if err != nil {
+		return nil, fmt.Errorf("error loading root: %w", err)
+	}
+	if ok {
+		l.add(root, nil)
+	}
+	return l, nil
+}
+
+// add adds a stored node to the loaded tree, below parent or as the root.
+func (l *Lazy[K, T]) add(s StoredNode[K, T], parent *lazyNode[K, T]) {
+	switch {
+	case parent != nil:
+		l.tree.Add(s.ID, parent.GetID(), s.Data)
+	case s.NoParent:
+		l.tree.AddRoot(s.ID, s.Data)
+	default:
+		l.tree.Add(s.ID, s.ParentID, s.Data)
+	}
+	if n, ok := l.tree.Find(s.ID); ok {
+		n.(*lazyNode[K, T]).stored = s.HasChildren
+	}
+}
+
+// touch marks a node and its ancestors as used by the current call.
+func (l *Lazy[K, T]) touch(n *lazyNode[K, T]) {
+	// the ancestors are moved to the front after the node, so that they are
+	// evicted after it
+	for a := Node[K, T](n); a != nil; a = a.GetParent() {
+		an := a.(*lazyNode[K, T])
+		an.lastOp = l.op
+		if an.elem != nil {
+			l.used.MoveToFront(an.elem)
+		}
+	}
+}
+
+// use marks a node as used by the current call, loading its children if they
+// are not loaded.
+func (l *Lazy[K, T]) use(n *lazyNode[K, T]) error {
+	l.touch(n)
+	if !n.stored || n.loaded {
+		return nil
+	}
+
+	children, err := l.loader.LoadChildren(n.GetID())
+	if err != nil {
+		return fmt.Errorf("error loading children of %v: %w", n.GetID(), err)
+	}
+	for _, c := range children {
+		l.add(c, n)
+	}
+	n.loaded = true
+	n.elem = l.used.PushFront(n)
+	return nil
+}
+
+// evict evicts the children of the least recently used nodes until the tree
+// is within its budget, or only nodes used by the current call are left.
+func (l *Lazy[K, T]) evict() {
+	if l.budget <= 0 {
+		return
+	}
+
+	for l.Len() > l.budget {
+		e := l.used.Back()
+		if e == nil {
+			return
+		}
+		n := e.Value.(*lazyNode[K, T])
+		if n.lastOp == l.op {
+			return
+		}
+
+		for _, c := range n.GetChildren() {
+			removed, _ := l.tree.Remove(c.GetID())
+			removed.primary.each(func(_ K, r Node[K, T]) bool {
+				rn := r.(*lazyNode[K, T])
+				if rn.elem != nil {
+					l.used.Remove(rn.elem)
+					rn.elem = nil
+				}
+				return true
+			})
+		}
+		n.loaded = false
+		l.used.Remove(e)
+		n.elem = nil
+	}
+}
+
+// Root returns the root node of the tree. If the tree is empty, this function
+// returns nil.
+func (l *Lazy[K, T]) Root() Node[K, T] {
+	return l.tree.Root()
+}
+
+// Len returns the number of nodes loaded in memory.
+func (l *Lazy[K, T]) Len() int {
+	return l.tree.primary.len()
+}
+
+// Find looks up a node by its primary key, loading it and its ancestors if
+// they are not loaded. If the node is not found, ok is false.
+func (l *Lazy[K, T]) Find(id K) (n Node[K, T], ok bool, err error) {
+	l.op++
+	defer l.evict()
+
+	if f, found := l.tree.Find(id); found {
+		l.touch(f.(*lazyNode[K, T]))
+		return f, true, nil
+	}
+
+	path, ok, err := l.loader.LoadPath(id)
+	if err != nil {
+		return nil, false, fmt.Errorf("error finding %v: %w", id, err)
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	for _, a := range path {
+		an, found := l.tree.Find(a)
+		if !found {
+			return nil, false, fmt.Errorf("error finding %v: ancestor %v is not loaded", id, a)
+		}
+		if err := l.use(an.(*lazyNode[K, T])); err != nil {
+			return nil, false, err
+		}
+	}
+
+	f, found := l.tree.Find(id)
+	if !found {
+		return nil, false, fmt.Errorf("error finding %v: not a child of its stored parent", id)
+	}
+	l.touch(f.(*lazyNode[K, T]))
+	return f, true, nil
+}
+
+// Load loads the children of a node, identified by its primary key, if they
+// are not loaded, together with the ancestors of the node. Returns
+// ErrNotFound if the node is not in the tree.
+func (l *Lazy[K, T]) Load(id K) error {
+	_, err := l.Children(id)
+	return err
+}
+
+// Loaded reports whether the children of a node, identified by its primary
+// key, are in memory, so that GetChildren on the node returns all of them. A
+// node with no children in storage has them all loaded. Loaded does not load
+// anything, and returns ErrNotFound if the node itself is not in memory.
+func (l *Lazy[K, T]) Loaded(id K) (bool, error) {
+	n, ok := l.tree.Find(id)
+	if !ok {
+		return false, fmt.Errorf("%w: %v", ErrNotFound, id)
+	}
+	ln := n.(*lazyNode[K, T])
+	return !ln.stored || ln.loaded, nil
+}
+
+// Children returns the children of a node, identified by its primary key,
+// loading them if they are not loaded. Returns ErrNotFound if the node is not
+// in the tree.
+func (l *Lazy[K, T]) Children(id K) ([]Node[K, T], error) {
+	n, ok, err := l.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, id)
+	}
+
+	l.op++
+	defer l.evict()
+	if err := l.use(n.(*lazyNode[K, T])); err != nil {
+		return nil, err
+	}
+	return n.GetChildren(), nil
+}
@@ -0,0 +1,252 @@
+package tree
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mapLoader is a SubtreeLoader reading a tree held in memory, standing in
+// for a key-value store. It counts the children it loads.
+type mapLoader struct {
+	tree   *Tree[uint, string]
+	loaded int
+	err    error
+}
+
+func stored(n Node[uint, string]) StoredNode[uint, string] {
+	return StoredNode[uint, string]{
+		ID:          n.GetID(),
+		ParentID:    n.GetParentID(),
+		NoParent:    !n.HasParentID(),
+		Data:        n.GetData(),
+		HasChildren: len(n.GetChildren()) > 0,
+	}
+}
+
+func (m *mapLoader) LoadRoot() (StoredNode[uint, string], bool, error) {
+	if m.tree.root == nil {
+		return StoredNode[uint, string]{}, false, nil
+	}
+	return stored(m.tree.root), true, nil
+}
+
+func (m *mapLoader) LoadChildren(id uint) ([]StoredNode[uint, string], error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	n, _ := m.tree.Find(id)
+	var children []StoredNode[uint, string]
+	for _, c := range n.GetChildren() {
+		children = append(children, stored(c))
+	}
+	m.loaded += len(children)
+	return children, nil
+}
+
+func (m *mapLoader) LoadPath(id uint) ([]uint, bool, error) {
+	parents, ok := m.tree.FindParents(id)
+	path := make([]uint, len(parents))
+	for i, p := range parents {
+		path[len(parents)-1-i] = p.GetID()
+	}
+	return path, ok, nil
+}
+
+// childIDs returns the keys of the children of a node.
+func childIDs(children []Node[uint, string]) []uint {
+	var ids []uint
+	for _, c := range children {
+		ids = append(ids, c.GetID())
+	}
+	return ids
+}
+
+func TestLazyFind(t *testing.T) {
+
+	tree := bigTree(500)
+	dir := t.TempDir()
+	assert.NoError(t, tree.SerializeChunks(dir, 25))
+	chunks, err := OpenChunks[uint, string](dir)
+	assert.NoError(t, err)
+
+	loaders := map[string]SubtreeLoader[uint, string]{
+		"chunks": chunks,
+		"map":    &mapLoader{tree: tree},
+	}
+
+	for name, loader := range loaders {
+		t.Run(name, func(t *testing.T) {
+			lazy, err := NewLazy(loader, 40)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, lazy.Len())
+			assert.Equal(t, uint(0), lazy.Root().GetID())
+			assert.False(t, lazy.Root().HasParentID())
+
+			for _, id := range []uint{499, 3, 250, 17, 0, 498, 120} {
+				n, ok, err := lazy.Find(id)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+
+				exp, _ := tree.Find(id)
+				assert.Equal(t, exp.GetData(), n.GetData())
+				var gotParents, expParents []uint
+				for p := n.GetParent(); p != nil; p = p.GetParent() {
+					gotParents = append(gotParents, p.GetID())
+				}
+				parents, _ := tree.FindParents(id)
+				for _, p := range parents {
+					expParents = append(expParents, p.GetID())
+				}
+				assert.Equal(t, expParents, gotParents)
+
+				// the budget may be exceeded by the children of the path
+				assert.LessOrEqual(t, lazy.Len(), 40+5*len(parents))
+				assert.Empty(t, lazy.tree.Validate())
+			}
+
+			_, ok, err := lazy.Find(1000)
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestLazyChildren(t *testing.T) {
+
+	tree := bigTree(500)
+	loader := &mapLoader{tree: tree}
+	lazy, err := NewLazy[uint, string](loader, 0)
+	assert.NoError(t, err)
+
+	// the getters of a node do not load, and Loaded tells unloaded children
+	// apart from none
+	assert.Empty(t, lazy.Root().GetChildren())
+	loaded, err := lazy.Loaded(0)
+	assert.NoError(t, err)
+	assert.False(t, loaded)
+	assert.Equal(t, 0, loader.loaded)
+
+	children, err := lazy.Children(12)
+	assert.NoError(t, err)
+	exp, _ := tree.Find(12)
+	assert.Equal(t, childIDs(exp.GetChildren()), childIDs(children))
+	loaded, err = lazy.Loaded(12)
+	assert.NoError(t, err)
+	assert.True(t, loaded)
+
+	// a sibling on the path is loaded without its children
+	sibling, ok, err := lazy.Find(3)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	count := loader.loaded
+	assert.Empty(t, sibling.GetChildren())
+	loaded, err = lazy.Loaded(sibling.GetID())
+	assert.NoError(t, err)
+	assert.False(t, loaded)
+	assert.Equal(t, count, loader.loaded)
+
+	_, err = lazy.Loaded(1000)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = lazy.Children(1000)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, lazy.Load(1000), ErrNotFound)
+
+	// walking the tree through Children loads all of it
+	var walk func(id uint) []uint
+	walk = func(id uint) []uint {
+		ids := []uint{id}
+		children, err := lazy.Children(id)
+		assert.NoError(t, err)
+		for _, c := range children {
+			ids = append(ids, walk(c.GetID())...)
+		}
+		return ids
+	}
+	assert.Equal(t, dfc(tree.root, []uint{}), walk(0))
+	assert.Equal(t, 500, lazy.Len())
+	assert.True(t, Equal(tree, lazy.tree, func(a, b string) bool { return a == b }))
+}
+
+func TestLazyLoad(t *testing.T) {
+
+	tree := bigTree(500)
+	loader := &mapLoader{tree: tree}
+	lazy, err := NewLazy[uint, string](loader, 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, lazy.Load(40))
+	n, _ := lazy.tree.Find(40)
+	exp, _ := tree.Find(40)
+	assert.Equal(t, childIDs(exp.GetChildren()), childIDs(n.GetChildren()))
+
+	// loading again reads nothing
+	loaded := loader.loaded
+	assert.NoError(t, lazy.Load(40))
+	assert.Equal(t, loaded, loader.loaded)
+	assert.Empty(t, lazy.tree.Validate())
+}
+
+func TestLazyEvict(t *testing.T) {
+
+	tree := bigTree(500)
+	loader := &mapLoader{tree: tree}
+	lazy, err := NewLazy[uint, string](loader, 20)
+	assert.NoError(t, err)
+
+	first, _, err := lazy.Find(400)
+	assert.NoError(t, err)
+
+	// finding a node in another subtree evicts the first path
+	_, _, err = lazy.Find(200)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, lazy.Len(), 30)
+	_, ok := lazy.tree.Find(400)
+	assert.False(t, ok)
+
+	// an evicted node is detached from the tree
+	loaded := loader.loaded
+	assert.Nil(t, first.GetParent())
+	assert.Empty(t, first.GetChildren())
+	assert.Equal(t, loaded, loader.loaded)
+
+	again, ok, err := lazy.Find(400)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NotSame(t, first, again)
+	assert.Greater(t, loader.loaded, loaded)
+	assert.Empty(t, lazy.tree.Validate())
+}
+
+func TestLazyError(t *testing.T) {
+
+	loader := &mapLoader{tree: bigTree(50)}
+	lazy, err := NewLazy[uint, string](loader, 0)
+	assert.NoError(t, err)
+
+	loader.err = errors.New("unavailable")
+	_, _, err = lazy.Find(40)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, loader.err))
+
+	_, err = lazy.Children(0)
+	assert.True(t, errors.Is(err, loader.err))
+	assert.True(t, errors.Is(lazy.Load(0), loader.err))
+	assert.Empty(t, lazy.Root().GetChildren())
+
+	loader.err = nil
+	n, ok, err := lazy.Find(40)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint(40), n.GetID())
+}
+
+func TestLazyEmpty(t *testing.T) {
+
+	lazy, err := NewLazy[uint, string](&mapLoader{tree: Empty[uint, string]()}, 0)
+	assert.NoError(t, err)
+	assert.Nil(t, lazy.Root())
+	assert.Equal(t, 0, lazy.Len())
+}
@@ -0,0 +1,167 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoRoot is returned when building a tree from a set of nodes in which
+	// every node has a parent, so that no node can be the root.
+	ErrNoRoot = errors.New("tree: no root node")
+	// ErrMultipleRoots is returned when building a tree from a set of nodes in
+	// which more than one node has no parent.
+	ErrMultipleRoots = errors.New("tree: multiple root nodes")
+	// ErrDuplicateKey is returned when building a tree from a set of nodes in
+	// which a primary key appears more than once.
+	ErrDuplicateKey = errors.New("tree: duplicate primary key")
+	// ErrCycle is returned when building a tree from a set of nodes in which
+	// some nodes are their own ancestors and so cannot be reached from the
+	// root.
+	ErrCycle = errors.New("tree: cycle between nodes")
+)
+
+// Record is a node of a tree given apart from the tree, such as a node read
+// from a file in another format than that of Serialize, for Link.
+type Record[K comparable, T any] struct {
+	ID       K
+	ParentID K
+	// NoParent is set on a root with no parent key
+	NoParent bool
+	// SiblingIndex is the position of the node among its parent's children
+	SiblingIndex int
+	Data         T
+}
+
+// IsRoot reports whether a record is a root of a set of records, given
+// whether each key is in the set: it is if it has no parent key, if its parent
+// is not in the set, or if it is its own parent. A set of records links into
+// a tree only if exactly one of them is a root.
+func (r Record[K, T]) IsRoot(has func(K) bool) bool {
+	return r.NoParent || !has(r.ParentID) || r.ParentID == r.ID
+}
+
+// Link builds a tree from records given in any order, as DeserializeParallel
+// builds it from the nodes it decodes. The root, the single record for which
+// IsRoot is true, is added with AddRoot if it has no parent key, and keeps
+// its parent key otherwise. Siblings are ordered by their sibling index, and
+// then by the order of the records. The tree is created by New with the given
+// options.
+//
+// If the records do not form a tree, Link fails with one of the errors
+// ErrDuplicateKey, ErrMultipleRoots, ErrNoRoot or ErrCycle.
+func Link[K comparable, T any](records []Record[K, T], opts ...TreeOption) (*Tree[K, T], error) {
+	nodes := make([]serialNode[K, T], len(records))
+	for i, r := range records {
+		nodes[i] = serialNode[K, T]{
+			Primary:      r.ID,
+			ParentID:     r.ParentID,
+			SiblingIndex: r.SiblingIndex,
+			Data:         r.Data,
+			noParent:     r.NoParent,
+		}
+	}
+	return linkOrdered(New[K, T](opts...), nodes)
+}
+
+// link builds a tree from a set of nodes given in any order. The root is the
+// single node that is a root by Record.IsRoot; if it has no parent key or is
+// its own parent, it is added with AddRoot. The children of each node are added in the order in which they
+// appear in the set.
+func link[K comparable, T any](nodes []serialNode[K, T]) (*Tree[K, T], error) {
+	return linkInto(Empty[K, T](), nodes)
+}
+
+// linkInto builds a tree from a set of nodes as link does, adding them to the
+// empty tree t.
+func linkInto[K comparable, T any](t *Tree[K, T], nodes []serialNode[K, T]) (*Tree[K, T], error) {
+	if len(nodes) == 0 {
+		return t, nil
+	}
+
+	keys := make(map[K]bool, len(nodes))
+	for _, n := range nodes {
+		if keys[n.Primary] {
+			return nil, fmt.Errorf("%w: %v", ErrDuplicateKey, n.Primary)
+		}
+		keys[n.Primary] = true
+	}
+
+	has := func(k K) bool { return keys[k] }
+	root := -1
+	children := make(map[K][]int, len(nodes))
+	for i, n := range nodes {
+		if n.record().IsRoot(has) {
+			if root >= 0 {
+				return nil, fmt.Errorf("%w: %v and %v", ErrMultipleRoots, nodes[root].Primary, n.Primary)
+			}
+			root = i
+			continue
+		}
+		children[n.ParentID] = append(children[n.ParentID], i)
+	}
+	if root < 0 {
+		return nil, ErrNoRoot
+	}
+
+	// add parents before their children
+	pending := []int{root}
+	for len(pending) > 0 {
+		i := pending[0]
+		n := nodes[i]
+		pending = pending[1:]
+
+		var added bool
+		if i == root && (n.noParent || n.ParentID == n.Primary) {
+			added, _ = t.AddRoot(n.Primary, n.Data)
+		} else {
+			added, _ = t.Add(n.Primary, n.ParentID, n.Data)
+		}
+		if !added {
+			return nil, fmt.Errorf("tree: failed to add node %v", n.Primary)
+		}
+		pending = append(pending, children[n.Primary]...)
+	}
+
+	if t.primary.len() != len(nodes) {
+		return nil, fmt.Errorf("%w: %d nodes unreachable from the root", ErrCycle, len(nodes)-t.primary.len())
+	}
+	return t, nil
+}
+
+// linkOrdered builds a tree from a set of serialized nodes as linkInto does,
+// and then puts the children of each node in their serialized order. As in
+// Deserialize, a root that is its own parent keeps its parent key.
+func linkOrdered[K comparable, T any](t *Tree[K, T], nodes []serialNode[K, T]) (*Tree[K, T], error) {
+	positions := make(map[K]int, len(nodes))
+	for _, n := range nodes {
+		positions[n.Primary] = n.SiblingIndex
+	}
+
+	t, err := linkInto(t, nodes)
+	if err != nil {
+		return nil, err
+	}
+	if t.root == nil {
+		return t, nil
+	}
+
+	// linkInto adds a root that is its own parent with AddRoot, but
+	// Deserialize keeps its parent key
+	for _, n := range nodes {
+		if n.Primary == t.root.GetID() {
+			if !n.noParent && n.ParentID == n.Primary {
+				t.root.setParentKey(n.ParentID, false)
+			}
+			break
+		}
+	}
+
+	t.restoreOrder(positions)
+	return t, nil
+}
+
+// record returns the serialized node as a Record.
+func (n serialNode[K, T]) record() Record[K, T] {
+	return Record[K, T]{ID: n.Primary, ParentID: n.ParentID, NoParent: n.noParent, SiblingIndex: n.SiblingIndex, Data: n.Data}
+}
@@ -0,0 +1,90 @@
+package tree
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// records returns the nodes of a tree as records, in breadth first order.
+func records(t *Tree[uint, string]) []Record[uint, string] {
+	var recs []Record[uint, string]
+	for n := range t.Traverse(TraverseBreadthFirst) {
+		r := Record[uint, string]{ID: n.GetID(), ParentID: n.GetParentID(), NoParent: !n.HasParentID(), Data: n.GetData()}
+		if p := n.GetParent(); p != nil {
+			for i, c := range p.GetChildren() {
+				if c == n {
+					r.SiblingIndex = i
+				}
+			}
+		}
+		recs = append(recs, r)
+	}
+	return recs
+}
+
+func TestLink(t *testing.T) {
+
+	tree := bigTree(500)
+	recs := records(tree)
+	rand.New(rand.NewSource(3)).Shuffle(len(recs), func(i, j int) {
+		recs[i], recs[j] = recs[j], recs[i]
+	})
+
+	got, err := Link(recs, Arena(10), Debug())
+	assert.NoError(t, err)
+	assert.True(t, Equal(tree, got, func(a, b string) bool { return a == b }))
+	assert.NotNil(t, got.arena)
+	assert.Empty(t, got.Validate())
+
+	got, err = Link([]Record[uint, string]{{ID: 2, ParentID: 1}, {ID: 1, ParentID: 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), got.Root().GetID())
+	assert.True(t, got.Root().HasParentID(), "a root that is its own parent keeps its parent key")
+
+	got, err = Link[uint, string](nil)
+	assert.NoError(t, err)
+	assert.Nil(t, got.Root())
+}
+
+func TestLinkError(t *testing.T) {
+
+	var tests = map[string]struct {
+		recs   []Record[uint, string]
+		expErr error
+	}{
+		"duplicate": {
+			recs:   []Record[uint, string]{{ID: 1, NoParent: true}, {ID: 2, ParentID: 1}, {ID: 2, ParentID: 1}},
+			expErr: ErrDuplicateKey,
+		},
+		"multiple roots": {
+			recs:   []Record[uint, string]{{ID: 1, NoParent: true}, {ID: 2, ParentID: 9}},
+			expErr: ErrMultipleRoots,
+		},
+		"no root": {
+			recs:   []Record[uint, string]{{ID: 1, ParentID: 2}, {ID: 2, ParentID: 1}},
+			expErr: ErrNoRoot,
+		},
+		"cycle": {
+			recs:   []Record[uint, string]{{ID: 1, NoParent: true}, {ID: 2, ParentID: 3}, {ID: 3, ParentID: 2}},
+			expErr: ErrCycle,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Link(tt.recs)
+			assert.ErrorIs(t, err, tt.expErr)
+		})
+	}
+}
+
+func TestRecordIsRoot(t *testing.T) {
+
+	has := func(k uint) bool { return k < 5 }
+	assert.True(t, Record[uint, string]{ID: 1, NoParent: true}.IsRoot(has))
+	assert.True(t, Record[uint, string]{ID: 1, ParentID: 9}.IsRoot(has))
+	assert.True(t, Record[uint, string]{ID: 1, ParentID: 1}.IsRoot(has))
+	assert.False(t, Record[uint, string]{ID: 1, ParentID: 2}.IsRoot(has))
+}
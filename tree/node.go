@@ -6,9 +6,9 @@ import (
 
 // Node is the interface for a node within a tree.
 //
-// This package implementes this interface with an internal struct
-// representation. This interface cannot be implemented by other packages
-// due to unexported fields.
+// This package implementes this interface with BaseNode. Other packages
+// implement it by embedding BaseNode in their own node type, which a tree
+// creates with the NodeFactory option; see BaseNode.
 //
 // The properties of a Node are that it has a uint primary key, a parent
 // Node and an array of child Nodes. Instantiating a Node through the
@@ -31,11 +31,20 @@ import (
 // When this node is serialized, only the id and parent id, along with
 // associated data are serialized. The internal pointers to parent and children
 // are recreated with the nodes are deserialized.
+//
+// Apart from its data, a node should be treated as read-only. The structure of
+// a tree is changed through the methods of Tree, such as Add, Move,
+// ReplaceChildren and Remove, which keep the nodes and the indexes of the tree
+// consistent. The slice returned by GetChildren must not be modified.
 type Node[K comparable, T any] interface {
 	// GetID returns the primary key of this node.
 	GetID() K
 	// GetParentID returns the primary key of this node's parent.
 	GetParentID() K
+	// HasParentID reports whether this node has a parent key. Only a root
+	// may have none, if it was added with Tree.AddRoot or stored with no
+	// parent; GetParentID then returns the zero value.
+	HasParentID() bool
 
 	// GetChildren returns an array of pointers to all children of this node.
 	GetChildren() []Node[K, T]
@@ -43,12 +52,33 @@ type Node[K comparable, T any] interface {
 	GetParent() Node[K, T]
 
 	// AddChildren adds a list of Nodes as children of this node.
+	//
+	// Deprecated: AddChildren changes only the list of children of this node;
+	// the parents of the children and the indexes of the tree are not updated
+	// and the tree is left corrupted. Use Tree.Add, Tree.Move or Tree.Merge
+	// instead.
 	AddChildren(...Node[K, T])
 	// ReplaceChildren replaces the current list of children with a new list of
 	// Nodes.
+	//
+	// Deprecated: ReplaceChildren changes only the list of children of this
+	// node, with the same problems as AddChildren. Use Tree.ReplaceChildren
+	// instead.
 	ReplaceChildren(...Node[K, T])
 
 	setParent(n Node[K, T])
+	setChildren(children []Node[K, T])
+	// appendChild adds c after the children of this node
+	appendChild(c Node[K, T])
+	// eachChild calls visit on the children of this node in order, until
+	// visit returns false
+	eachChild(visit func(Node[K, T]) bool)
+	// detach clears the parent of this node, keeping its parent key
+	detach()
+	setParentKey(id K, noParent bool)
+	// base returns the BaseNode of this node, or nil for a node of a tree
+	// with the Arena option
+	base() *BaseNode[K, T]
 
 	// GetData retruns this node's internal data.
 	GetData() T
@@ -58,63 +88,170 @@ type Node[K comparable, T any] interface {
 	// This function does not attempt to test json encoding when the data is set;
 	// any error with encoding will only occur when the data is serialized
 	// to a repository.
+	//
+	// The secondary indexes of the tree holding the node are updated with the
+	// new data. If the new data violates a unique index, the data is left
+	// unchanged, which this method cannot report; in a tree with unique
+	// indexes, use Tree.SetData instead, which returns the violation as an
+	// error.
 	SetData(T)
 }
 
-type node[K comparable, T any] struct {
+// BaseNode is the implementation of Node used by a tree, unless it is created
+// with the NodeFactory option. It can be embedded in another struct to attach
+// fields to the nodes of a tree, such as a cached value or a mutex, without
+// wrapping the node data:
+//
+//	type countedNode struct {
+//		tree.BaseNode[uint, string]
+//		visits int
+//	}
+//
+//	t := tree.New[uint, string](tree.NodeFactory(func() tree.Node[uint, string] {
+//		return &countedNode{}
+//	}))
+//
+// The nodes of t are then *countedNode values, which are returned by Find and
+// Traverse and can be type asserted to get at the added fields. The fields of
+// the embedded BaseNode are set by the tree, and must not be copied from one
+// node to another. Methods of BaseNode may be overridden, for example to mark
+// a node as changed in SetData before calling BaseNode.SetData. The tree calls
+// the overriding methods, so they must keep the behaviour of those of
+// BaseNode.
+type BaseNode[K comparable, T any] struct {
 	primary  K
 	parentID K
+	// noParent is set on a root that has no parent key, as opposed to a
+	// parent key that is the zero value
+	noParent bool
 	parent   Node[K, T]
 	data     T
 	children []Node[K, T]
+	// tree is the tree holding this node, if any, whose secondary indexes
+	// must follow changes to the data
+	tree *Tree[K, T]
+	// self is the node embedding this one, or nil if it is not embedded
+	self Node[K, T]
 }
 
-func (n *node[K, T]) GetID() K {
+func (n *BaseNode[K, T]) GetID() K {
 	return n.primary
 }
 
-func (n *node[K, T]) GetParentID() K {
+func (n *BaseNode[K, T]) GetParentID() K {
 	return n.parentID
 }
 
-func (n *node[K, T]) GetChildren() []Node[K, T] {
+func (n *BaseNode[K, T]) HasParentID() bool {
+	return !n.noParent
+}
+
+func (n *BaseNode[K, T]) GetChildren() []Node[K, T] {
 	return n.children
 }
 
-func (n *node[K, T]) GetParent() Node[K, T] {
+func (n *BaseNode[K, T]) GetParent() Node[K, T] {
 	return n.parent
 }
 
-func (n *node[K, T]) AddChildren(children ...Node[K, T]) {
+func (n *BaseNode[K, T]) AddChildren(children ...Node[K, T]) {
 	if n.children == nil {
 		n.children = []Node[K, T]{}
 	}
 	n.children = append(n.children, children[:]...)
 }
 
-func (n *node[K, T]) ReplaceChildren(children ...Node[K, T]) {
+func (n *BaseNode[K, T]) ReplaceChildren(children ...Node[K, T]) {
 	n.children = []Node[K, T]{}
 	n.AddChildren(children...)
 }
 
-func (n *node[K, T]) setParent(parent Node[K, T]) {
+func (n *BaseNode[K, T]) setChildren(children []Node[K, T]) {
+	n.children = children
+}
+
+func (n *BaseNode[K, T]) appendChild(c Node[K, T]) {
+	n.node().setChildren(append(n.node().GetChildren(), c))
+}
+
+func (n *BaseNode[K, T]) eachChild(visit func(Node[K, T]) bool) {
+	for _, c := range n.node().GetChildren() {
+		if !visit(c) {
+			return
+		}
+	}
+}
+
+func (n *BaseNode[K, T]) detach() {
+	n.parent = nil
+}
+
+func (n *BaseNode[K, T]) setParentKey(id K, noParent bool) {
+	n.parentID, n.noParent = id, noParent
+}
+
+func (n *BaseNode[K, T]) setParent(parent Node[K, T]) {
 	if parent == nil || parent.GetID() == n.GetID() {
 		return
 	}
 	n.parent = parent
 	n.parentID = parent.GetID()
+	n.noParent = false
+
+}
+
+// NodeFactory makes a tree create its nodes with newNode, instead of as plain
+// BaseNode values. The function must return a new node each time it is
+// called, embedding a BaseNode; the tree sets the fields of the BaseNode.
+//
+// The nodes created by Add, AddRoot, InsertAt and Clone, and by Deserialize
+// when it is given the option, come from the factory. Nodes of another tree
+// grafted with Merge keep their type. The trees returned by Remove and Clone
+// inherit this option.
+func NodeFactory[K comparable, T any](newNode func() Node[K, T]) TreeOption {
+	return func(c *treeConfig) {
+		c.factory = newNode
+	}
+}
 
+func (n *BaseNode[K, T]) base() *BaseNode[K, T] {
+	return n
 }
 
-func (n *node[K, T]) GetData() T {
+// node returns the node embedding n, which is the node held by the tree, or n
+// itself if it is not embedded.
+func (n *BaseNode[K, T]) node() Node[K, T] {
+	if n.self != nil {
+		return n.self
+	}
+	return n
+}
+
+// setTree records the tree holding a node, so that changes to the node data
+// are reflected in the secondary indexes of that tree.
+func setTree[K comparable, T any](n Node[K, T], t *Tree[K, T]) {
+	if b := n.base(); b != nil {
+		b.tree = t
+	}
+}
+
+func (n *BaseNode[K, T]) GetData() T {
 	return n.data
 }
 
-func (n *node[K, T]) SetData(newdata T) {
+func (n *BaseNode[K, T]) SetData(newdata T) {
+	if n.tree != nil {
+		// a violation of a unique index is reported by Tree.SetData, which
+		// checks the indexes before calling this method
+		if err := n.tree.reindex(n.node(), newdata); err != nil {
+			return
+		}
+		defer n.tree.check("SetData")
+	}
 	n.data = newdata
 }
 
-func (n *node[K, T]) Format(f fmt.State, verb rune) {
+func (n *BaseNode[K, T]) Format(f fmt.State, verb rune) {
 	switch verb {
 	case 'v':
 		fmt.Fprintf(f, "{primary: %v parentID: %v data:%+v children:[", n.primary, n.parentID, n.data)
@@ -13,7 +13,7 @@ func TestGetID(t *testing.T) {
 		expID uint
 	}{
 		"trivial": {
-			n:     &node[uint, int]{primary: 1},
+			n:     &BaseNode[uint, int]{primary: 1},
 			expID: 1,
 		},
 	}
@@ -34,7 +34,7 @@ func TestGetParentID(t *testing.T) {
 		exp uint
 	}{
 		"trivial": {
-			n:   &node[uint, int]{parentID: 1},
+			n:   &BaseNode[uint, int]{parentID: 1},
 			exp: 1,
 		},
 	}
@@ -50,23 +50,23 @@ func TestGetParentID(t *testing.T) {
 
 func TestGetChildren(t *testing.T) {
 
-	node1 := &node[uint, int]{primary: 1}
-	node2 := &node[uint, int]{primary: 2}
+	node1 := &BaseNode[uint, int]{primary: 1}
+	node2 := &BaseNode[uint, int]{primary: 2}
 
 	tests := map[string]struct {
 		n        Node[uint, int]
 		expChild []Node[uint, int]
 	}{
 		"nil child array": {
-			n:        &node[uint, int]{},
+			n:        &BaseNode[uint, int]{},
 			expChild: nil,
 		},
 		"empty child array": {
-			n:        &node[uint, int]{children: []Node[uint, int]{}},
+			n:        &BaseNode[uint, int]{children: []Node[uint, int]{}},
 			expChild: []Node[uint, int]{},
 		},
 		"success": {
-			n:        &node[uint, int]{children: []Node[uint, int]{node1, node2}},
+			n:        &BaseNode[uint, int]{children: []Node[uint, int]{node1, node2}},
 			expChild: []Node[uint, int]{node1, node2},
 		},
 	}
@@ -82,18 +82,18 @@ func TestGetChildren(t *testing.T) {
 
 func TestGetParent(t *testing.T) {
 
-	node1 := &node[uint, int]{primary: 1}
+	node1 := &BaseNode[uint, int]{primary: 1}
 
 	tests := map[string]struct {
 		n       Node[uint, int]
 		expNode Node[uint, int]
 	}{
 		"nil parent": {
-			n:       &node[uint, int]{},
+			n:       &BaseNode[uint, int]{},
 			expNode: nil,
 		},
 		"success": {
-			n:       &node[uint, int]{parent: node1},
+			n:       &BaseNode[uint, int]{parent: node1},
 			expNode: node1,
 		},
 	}
@@ -109,9 +109,9 @@ func TestGetParent(t *testing.T) {
 
 func TestNodeAddChildren(t *testing.T) {
 
-	node1 := &node[uint, int]{primary: 1}
-	node2 := &node[uint, int]{primary: 2}
-	node3 := &node[uint, int]{primary: 3}
+	node1 := &BaseNode[uint, int]{primary: 1}
+	node2 := &BaseNode[uint, int]{primary: 2}
+	node3 := &BaseNode[uint, int]{primary: 3}
 
 	tests := map[string]struct {
 		n        Node[uint, int]
@@ -119,27 +119,27 @@ func TestNodeAddChildren(t *testing.T) {
 		expChild []Node[uint, int]
 	}{
 		"nil child array": {
-			n:        &node[uint, int]{},
+			n:        &BaseNode[uint, int]{},
 			argNodes: []Node[uint, int]{node1},
 			expChild: []Node[uint, int]{node1},
 		},
 		"empty child array": {
-			n:        &node[uint, int]{children: []Node[uint, int]{}},
+			n:        &BaseNode[uint, int]{children: []Node[uint, int]{}},
 			argNodes: []Node[uint, int]{node1},
 			expChild: []Node[uint, int]{node1},
 		},
 		"add nil": {
-			n:        &node[uint, int]{children: []Node[uint, int]{node1}},
+			n:        &BaseNode[uint, int]{children: []Node[uint, int]{node1}},
 			argNodes: nil,
 			expChild: []Node[uint, int]{node1},
 		},
 		"add empty array": {
-			n:        &node[uint, int]{children: []Node[uint, int]{node1}},
+			n:        &BaseNode[uint, int]{children: []Node[uint, int]{node1}},
 			argNodes: []Node[uint, int]{},
 			expChild: []Node[uint, int]{node1},
 		},
 		"non-empty child array": {
-			n:        &node[uint, int]{children: []Node[uint, int]{node1}},
+			n:        &BaseNode[uint, int]{children: []Node[uint, int]{node1}},
 			argNodes: []Node[uint, int]{node2, node3},
 			expChild: []Node[uint, int]{node1, node2, node3},
 		},
@@ -156,9 +156,9 @@ func TestNodeAddChildren(t *testing.T) {
 
 func TestReplaceChildren(t *testing.T) {
 
-	node1 := &node[uint, int]{primary: 1}
-	node2 := &node[uint, int]{primary: 2}
-	node3 := &node[uint, int]{primary: 3}
+	node1 := &BaseNode[uint, int]{primary: 1}
+	node2 := &BaseNode[uint, int]{primary: 2}
+	node3 := &BaseNode[uint, int]{primary: 3}
 
 	tests := map[string]struct {
 		n        Node[uint, int]
@@ -166,27 +166,27 @@ func TestReplaceChildren(t *testing.T) {
 		expChild []Node[uint, int]
 	}{
 		"nil child array": {
-			n:        &node[uint, int]{},
+			n:        &BaseNode[uint, int]{},
 			argNodes: []Node[uint, int]{node1},
 			expChild: []Node[uint, int]{node1},
 		},
 		"empty child array": {
-			n:        &node[uint, int]{children: []Node[uint, int]{}},
+			n:        &BaseNode[uint, int]{children: []Node[uint, int]{}},
 			argNodes: []Node[uint, int]{node1},
 			expChild: []Node[uint, int]{node1},
 		},
 		"use nil": {
-			n:        &node[uint, int]{children: []Node[uint, int]{node1}},
+			n:        &BaseNode[uint, int]{children: []Node[uint, int]{node1}},
 			argNodes: nil,
 			expChild: []Node[uint, int]{},
 		},
 		"use empty array": {
-			n:        &node[uint, int]{children: []Node[uint, int]{node1}},
+			n:        &BaseNode[uint, int]{children: []Node[uint, int]{node1}},
 			argNodes: []Node[uint, int]{},
 			expChild: []Node[uint, int]{},
 		},
 		"non-empty replacement array": {
-			n:        &node[uint, int]{children: []Node[uint, int]{node1}},
+			n:        &BaseNode[uint, int]{children: []Node[uint, int]{node1}},
 			argNodes: []Node[uint, int]{node2, node3},
 			expChild: []Node[uint, int]{node2, node3},
 		},
@@ -204,8 +204,8 @@ func TestReplaceChildren(t *testing.T) {
 
 func TestSetParent(t *testing.T) {
 
-	node1 := &node[uint, int]{primary: 1}
-	node2 := &node[uint, int]{primary: 2}
+	node1 := &BaseNode[uint, int]{primary: 1}
+	node2 := &BaseNode[uint, int]{primary: 2}
 
 	tests := map[string]struct {
 		n           Node[uint, int]
@@ -214,7 +214,7 @@ func TestSetParent(t *testing.T) {
 		expParentID uint
 	}{
 		"set nil parent": {
-			n:           &node[uint, int]{primary: 1},
+			n:           &BaseNode[uint, int]{primary: 1},
 			argParent:   nil,
 			expParent:   nil,
 			expParentID: 0,
@@ -226,7 +226,7 @@ func TestSetParent(t *testing.T) {
 			expParentID: 0,
 		},
 		"success": {
-			n:           &node[uint, int]{primary: 1},
+			n:           &BaseNode[uint, int]{primary: 1},
 			argParent:   node2,
 			expParent:   node2,
 			expParentID: 2,
@@ -244,3 +244,85 @@ func TestSetParent(t *testing.T) {
 	}
 
 }
+
+// countedNode is a custom node type, counting the changes to its data.
+type countedNode struct {
+	BaseNode[uint, string]
+	changes int
+}
+
+func (n *countedNode) SetData(data string) {
+	n.changes++
+	n.BaseNode.SetData(data)
+}
+
+func newCounted() Node[uint, string] {
+	return &countedNode{}
+}
+
+func TestNodeFactory(t *testing.T) {
+
+	tree := New[uint, string](NodeFactory(newCounted), Debug())
+	assert.Nil(t, recovered(func() {
+		tree.AddRoot(1, "a")
+		tree.Add(2, 1, "b")
+		tree.Add(3, 1, "c")
+		tree.InsertAt(4, 1, 0, "d")
+		tree.Add(5, 3, "e")
+		assert.NoError(t, tree.AddIndex("data", func(s string) any { return s }, true))
+	}))
+
+	for n := range tree.Traverse(TraverseBreadthFirst) {
+		_, ok := n.(*countedNode)
+		assert.True(t, ok, "node %v", n.GetID())
+	}
+	assert.Equal(t, []uint{1, 4, 2, 3, 5}, bfc([]Node[uint, string]{tree.root}, []uint{}))
+
+	// the secondary indexes follow the data set on the custom node
+	n, _ := tree.Find(2)
+	n.SetData("f")
+	assert.Equal(t, 1, n.(*countedNode).changes)
+	found, _ := tree.FindBy("data", "f")
+	assert.Equal(t, []Node[uint, string]{n}, found)
+
+	// a subtree keeps its nodes through Remove and Merge
+	removed, ok := tree.Remove(3)
+	assert.True(t, ok)
+	assert.True(t, tree.Merge(removed))
+	n, _ = tree.Find(5)
+	assert.IsType(t, &countedNode{}, n)
+	assert.Empty(t, tree.Validate())
+
+	rdr, errs := tree.Serialize(TraverseDepthFirst)
+	got, err := Deserialize[uint, string](rdr, NodeFactory(newCounted))
+	assert.NoError(t, err)
+	assert.NoError(t, <-errs)
+	assert.True(t, Equal(tree, got, func(a, b string) bool { return a == b }))
+	assert.IsType(t, &countedNode{}, got.root)
+
+	clone := tree.Clone(nil)
+	n, _ = clone.Find(2)
+	assert.Equal(t, 0, n.(*countedNode).changes)
+	assert.Empty(t, clone.Validate())
+}
+
+func TestNodeFactoryMerge(t *testing.T) {
+
+	tree := Empty[uint, string]()
+	tree.Add(1, 0, "a")
+	other := New[uint, string](NodeFactory(newCounted))
+	other.Add(2, 1, "b")
+	other.Add(3, 2, "c")
+
+	assert.True(t, tree.Merge(other))
+	n, _ := tree.Find(3)
+	assert.IsType(t, &countedNode{}, n)
+	assert.Empty(t, tree.Validate())
+}
+
+func TestNodeFactoryType(t *testing.T) {
+
+	assert.Panics(t, func() {
+		New[int, string](NodeFactory(newCounted))
+	})
+}
@@ -0,0 +1,222 @@
+package tree
+
+import "sort"
+
+// InsertAt inserts an element into a tree as a node, placing it at position
+// pos among the children of its parent. A position of zero makes the node
+// the first child; a position less than zero or greater than the number of
+// existing children makes it the last child, which is the position used by
+// Add.
+//
+// The return values and the conditions under which insertion fails are the
+// same as for Add. If the node becomes the root of the tree, pos is ignored.
+func (t *Tree[K, T]) InsertAt(nodeID K, parentID K, pos int, data T) (added bool, exists bool) {
+	added, exists = t.Add(nodeID, parentID, data)
+	if !added {
+		return
+	}
+	defer t.check("InsertAt")
+
+	n := t.primary.find(nodeID)
+	if parent := n.GetParent(); parent != nil {
+		siblings := parent.GetChildren()
+		moveChild(siblings, len(siblings)-1, pos)
+		parent.setChildren(siblings)
+	}
+	return
+}
+
+// MoveBefore reorders a node so that it immediately precedes a sibling in
+// the children of their shared parent. Both nodes are identified by their
+// primary keys.
+//
+// Returns true if the node was moved. The move fails if either node does not
+// exist, if the nodes do not share a parent, or if both keys are the same.
+func (t *Tree[K, T]) MoveBefore(nodeID K, siblingID K) bool {
+	return t.moveBeside(nodeID, siblingID, 0)
+}
+
+// MoveAfter reorders a node so that it immediately follows a sibling in the
+// children of their shared parent. Both nodes are identified by their
+// primary keys.
+//
+// Returns true if the node was moved. The move fails under the same
+// conditions as MoveBefore.
+func (t *Tree[K, T]) MoveAfter(nodeID K, siblingID K) bool {
+	return t.moveBeside(nodeID, siblingID, 1)
+}
+
+func (t *Tree[K, T]) moveBeside(nodeID K, siblingID K, offset int) bool {
+	if nodeID == siblingID {
+		return false
+	}
+
+	n, s := t.primary.find(nodeID), t.primary.find(siblingID)
+	if n == nil || s == nil {
+		return false
+	}
+	parent := n.GetParent()
+	if parent == nil || parent != s.GetParent() {
+		return false
+	}
+
+	defer t.check("MoveBefore or MoveAfter")
+
+	siblings := parent.GetChildren()
+	from, to := childPosition(siblings, nodeID), childPosition(siblings, siblingID)+offset
+	if from < to {
+		to-- // removing the node shifts the target left
+	}
+	moveChild(siblings, from, to)
+	parent.setChildren(siblings)
+	return true
+}
+
+// Move moves a node, identified by its primary key, together with all of its
+// descendants to a new parent, placing it at position pos among the children
+// of that parent as in InsertAt. The new parent may be the current parent, in
+// which case the node is only reordered among its siblings.
+//
+// Returns true if the node was moved. The move fails if either node does not
+// exist, if the node is the root of the tree, or if the new parent is the
+// node itself or one of its descendants.
+func (t *Tree[K, T]) Move(nodeID K, parentID K, pos int) bool {
+	n, parent := t.primary.find(nodeID), t.primary.find(parentID)
+	if n == nil || parent == nil || n.GetParent() == nil || isAncestor(n, parent) {
+		return false
+	}
+	defer t.check("Move")
+
+	t.move(n, parent, pos)
+	return true
+}
+
+// move detaches a node from its parent and attaches it to a new parent at
+// position pos.
+func (t *Tree[K, T]) move(n, parent Node[K, T], pos int) {
+	old := n.GetParent()
+	siblings := old.GetChildren()
+	i := childPosition(siblings, n.GetID())
+	old.setChildren(append(siblings[:i:i], siblings[i+1:]...))
+
+	n.setParent(parent)
+	children := append(parent.GetChildren(), n)
+	moveChild(children, len(children)-1, pos)
+	parent.setChildren(children)
+}
+
+// ReplaceChildren replaces the children of a node, identified by its primary
+// key, with the nodes identified by childIDs, in that order. Nodes that are
+// not already children of the node are moved to it with their descendants, as
+// by Move. Former children that are not given are removed from the tree with
+// their descendants, as by Remove, and returned as trees in their former
+// order. Given no childIDs, all children of the node are removed, and with
+// the childIDs of the current children in another order, the children are
+// reordered.
+//
+// If the node is not found, if a child is not found or is given twice, or if
+// a child is the node itself or one of its ancestors, then ok is false and
+// the tree is unchanged.
+func (t *Tree[K, T]) ReplaceChildren(parentID K, childIDs ...K) (removed []*Tree[K, T], ok bool) {
+	parent := t.primary.find(parentID)
+	if parent == nil {
+		return
+	}
+
+	children := make([]Node[K, T], len(childIDs))
+	given := make(map[K]bool, len(childIDs))
+	for i, id := range childIDs {
+		c := t.primary.find(id)
+		if c == nil || given[id] || isAncestor(c, parent) {
+			return
+		}
+		children[i] = c
+		given[id] = true
+	}
+	defer t.check("ReplaceChildren")
+
+	for _, c := range children {
+		if c.GetParent() != parent {
+			t.move(c, parent, -1)
+		}
+	}
+
+	for _, c := range append([]Node[K, T]{}, parent.GetChildren()...) {
+		if !given[c.GetID()] {
+			r, _ := t.Remove(c.GetID())
+			removed = append(removed, r)
+		}
+	}
+	parent.setChildren(children)
+	return removed, true
+}
+
+// isAncestor reports whether a is n or one of its ancestors.
+func isAncestor[K comparable, T any](a, n Node[K, T]) bool {
+	for ; n != nil; n = n.GetParent() {
+		if n == a {
+			return true
+		}
+	}
+	return false
+}
+
+// SortChildren sorts the children of a node, identified by its primary key,
+// using the argument less to compare siblings. The sort is stable, so
+// siblings that compare as equal retain their current order.
+//
+// If recursive is true, the children of every descendant of the node are
+// sorted as well.
+//
+// Returns false if the node does not exist.
+func (t *Tree[K, T]) SortChildren(nodeID K, less func(a, b Node[K, T]) bool, recursive bool) bool {
+	n := t.primary.find(nodeID)
+	if n == nil {
+		return false
+	}
+	defer t.check("SortChildren")
+
+	stack := []Node[K, T]{n}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		children := current.GetChildren()
+		sort.SliceStable(children, func(i, j int) bool {
+			return less(children[i], children[j])
+		})
+		current.setChildren(children)
+
+		if recursive {
+			stack = append(stack, children...)
+		}
+	}
+	return true
+}
+
+// childPosition returns the position of the child with primary key id, or
+// -1 if it is not found.
+func childPosition[K comparable, T any](children []Node[K, T], id K) int {
+	for i, c := range children {
+		if c.GetID() == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveChild moves the element at position from to position to, shifting the
+// elements between them. A target position out of range moves the element
+// to the end.
+func moveChild[K comparable, T any](children []Node[K, T], from, to int) {
+	if to < 0 || to >= len(children) {
+		to = len(children) - 1
+	}
+	c := children[from]
+	if from < to {
+		copy(children[from:to], children[from+1:to+1])
+	} else {
+		copy(children[to+1:from+1], children[to:from])
+	}
+	children[to] = c
+}
@@ -0,0 +1,381 @@
+package tree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// builds a tree with root 1 and children 2, 3 and 4; node 3 has children 5
+// and 6
+func orderedTree() *Tree[uint, string] {
+	t := Empty[uint, string]()
+	t.Add(1, 0, "a")
+	t.Add(2, 1, "d")
+	t.Add(3, 1, "c")
+	t.Add(4, 1, "b")
+	t.Add(5, 3, "f")
+	t.Add(6, 3, "e")
+	return t
+}
+
+func TestInsertAt(t *testing.T) {
+
+	var tests = map[string]struct {
+		add       addInput
+		pos       int
+		expAdded  bool
+		expExists bool
+		expBFC    []uint
+	}{
+		"first": {
+			add:      addInput{7, 1},
+			pos:      0,
+			expAdded: true,
+			expBFC:   []uint{1, 7, 2, 3, 4, 5, 6},
+		},
+		"middle": {
+			add:      addInput{7, 1},
+			pos:      2,
+			expAdded: true,
+			expBFC:   []uint{1, 2, 3, 7, 4, 5, 6},
+		},
+		"last": {
+			add:      addInput{7, 1},
+			pos:      3,
+			expAdded: true,
+			expBFC:   []uint{1, 2, 3, 4, 7, 5, 6},
+		},
+		"past the end": {
+			add:      addInput{7, 3},
+			pos:      10,
+			expAdded: true,
+			expBFC:   []uint{1, 2, 3, 4, 5, 6, 7},
+		},
+		"negative": {
+			add:      addInput{7, 3},
+			pos:      -1,
+			expAdded: true,
+			expBFC:   []uint{1, 2, 3, 4, 5, 6, 7},
+		},
+		"primary exists": {
+			add:       addInput{5, 1},
+			pos:       0,
+			expExists: true,
+			expBFC:    []uint{1, 2, 3, 4, 5, 6},
+		},
+		"parent does not exist": {
+			add:    addInput{7, 8},
+			pos:    0,
+			expBFC: []uint{1, 2, 3, 4, 5, 6},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tree := orderedTree()
+			gotAdded, gotExists := tree.InsertAt(tt.add.nodeID, tt.add.parentID, tt.pos, "")
+
+			assert.Equal(t, tt.expAdded, gotAdded)
+			assert.Equal(t, tt.expExists, gotExists)
+			assert.Equal(t, tt.expBFC, bfc([]Node[uint, string]{tree.root}, []uint{}))
+		})
+	}
+}
+
+func TestMoveBeforeAfter(t *testing.T) {
+
+	var tests = map[string]struct {
+		nodeID    uint
+		siblingID uint
+		after     bool
+		expOK     bool
+		expBFC    []uint
+	}{
+		"before - forward": {
+			nodeID:    2,
+			siblingID: 4,
+			expOK:     true,
+			expBFC:    []uint{1, 3, 2, 4, 5, 6},
+		},
+		"before - backward": {
+			nodeID:    4,
+			siblingID: 2,
+			expOK:     true,
+			expBFC:    []uint{1, 4, 2, 3, 5, 6},
+		},
+		"before - already in place": {
+			nodeID:    2,
+			siblingID: 3,
+			expOK:     true,
+			expBFC:    []uint{1, 2, 3, 4, 5, 6},
+		},
+		"after - forward": {
+			nodeID:    2,
+			siblingID: 4,
+			after:     true,
+			expOK:     true,
+			expBFC:    []uint{1, 3, 4, 2, 5, 6},
+		},
+		"after - backward": {
+			nodeID:    4,
+			siblingID: 2,
+			after:     true,
+			expOK:     true,
+			expBFC:    []uint{1, 2, 4, 3, 5, 6},
+		},
+		"after - nested": {
+			nodeID:    5,
+			siblingID: 6,
+			after:     true,
+			expOK:     true,
+			expBFC:    []uint{1, 2, 3, 4, 6, 5},
+		},
+		"not siblings": {
+			nodeID:    2,
+			siblingID: 5,
+			expBFC:    []uint{1, 2, 3, 4, 5, 6},
+		},
+		"same node": {
+			nodeID:    2,
+			siblingID: 2,
+			expBFC:    []uint{1, 2, 3, 4, 5, 6},
+		},
+		"root": {
+			nodeID:    1,
+			siblingID: 2,
+			expBFC:    []uint{1, 2, 3, 4, 5, 6},
+		},
+		"does not exist": {
+			nodeID:    7,
+			siblingID: 2,
+			expBFC:    []uint{1, 2, 3, 4, 5, 6},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tree := orderedTree()
+
+			var gotOK bool
+			if tt.after {
+				gotOK = tree.MoveAfter(tt.nodeID, tt.siblingID)
+			} else {
+				gotOK = tree.MoveBefore(tt.nodeID, tt.siblingID)
+			}
+
+			assert.Equal(t, tt.expOK, gotOK)
+			assert.Equal(t, tt.expBFC, bfc([]Node[uint, string]{tree.root}, []uint{}))
+		})
+	}
+}
+
+func TestSortChildren(t *testing.T) {
+
+	byData := func(a, b Node[uint, string]) bool {
+		return a.GetData() < b.GetData()
+	}
+
+	var tests = map[string]struct {
+		nodeID    uint
+		recursive bool
+		expOK     bool
+		expBFC    []uint
+	}{
+		"root only": {
+			nodeID: 1,
+			expOK:  true,
+			expBFC: []uint{1, 4, 3, 2, 5, 6},
+		},
+		"recursive": {
+			nodeID:    1,
+			recursive: true,
+			expOK:     true,
+			expBFC:    []uint{1, 4, 3, 2, 6, 5},
+		},
+		"subtree": {
+			nodeID: 3,
+			expOK:  true,
+			expBFC: []uint{1, 2, 3, 4, 6, 5},
+		},
+		"leaf": {
+			nodeID: 6,
+			expOK:  true,
+			expBFC: []uint{1, 2, 3, 4, 5, 6},
+		},
+		"does not exist": {
+			nodeID: 7,
+			expBFC: []uint{1, 2, 3, 4, 5, 6},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tree := orderedTree()
+			gotOK := tree.SortChildren(tt.nodeID, byData, tt.recursive)
+
+			assert.Equal(t, tt.expOK, gotOK)
+			assert.Equal(t, tt.expBFC, bfc([]Node[uint, string]{tree.root}, []uint{}))
+		})
+	}
+}
+
+func TestMove(t *testing.T) {
+
+	var tests = map[string]struct {
+		nodeID   uint
+		parentID uint
+		pos      int
+		expOK    bool
+		expDFC   []uint
+	}{
+		"to another parent": {
+			nodeID:   2,
+			parentID: 3,
+			pos:      1,
+			expOK:    true,
+			expDFC:   []uint{1, 3, 5, 2, 6, 4},
+		},
+		"with descendants": {
+			nodeID:   3,
+			parentID: 4,
+			pos:      -1,
+			expOK:    true,
+			expDFC:   []uint{1, 2, 4, 3, 5, 6},
+		},
+		"up": {
+			nodeID:   6,
+			parentID: 1,
+			pos:      0,
+			expOK:    true,
+			expDFC:   []uint{1, 6, 2, 3, 5, 4},
+		},
+		"same parent": {
+			nodeID:   4,
+			parentID: 1,
+			pos:      0,
+			expOK:    true,
+			expDFC:   []uint{1, 4, 2, 3, 5, 6},
+		},
+		"under itself": {
+			nodeID:   3,
+			parentID: 3,
+			expDFC:   []uint{1, 2, 3, 5, 6, 4},
+		},
+		"under a descendant": {
+			nodeID:   3,
+			parentID: 6,
+			expDFC:   []uint{1, 2, 3, 5, 6, 4},
+		},
+		"root": {
+			nodeID:   1,
+			parentID: 2,
+			expDFC:   []uint{1, 2, 3, 5, 6, 4},
+		},
+		"node does not exist": {
+			nodeID:   7,
+			parentID: 2,
+			expDFC:   []uint{1, 2, 3, 5, 6, 4},
+		},
+		"parent does not exist": {
+			nodeID:   2,
+			parentID: 7,
+			expDFC:   []uint{1, 2, 3, 5, 6, 4},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tree := orderedTree()
+			assert.Equal(t, tt.expOK, tree.Move(tt.nodeID, tt.parentID, tt.pos))
+			assert.Equal(t, tt.expDFC, dfc[uint, string](tree.root, []uint{}))
+			assert.Empty(t, tree.Validate())
+		})
+	}
+}
+
+func TestTreeReplaceChildren(t *testing.T) {
+
+	var tests = map[string]struct {
+		parentID   uint
+		childIDs   []uint
+		expOK      bool
+		expDFC     []uint
+		expRemoved [][]uint
+	}{
+		"reorder": {
+			parentID: 1,
+			childIDs: []uint{4, 3, 2},
+			expOK:    true,
+			expDFC:   []uint{1, 4, 3, 5, 6, 2},
+		},
+		"remove": {
+			parentID:   1,
+			childIDs:   []uint{4},
+			expOK:      true,
+			expDFC:     []uint{1, 4},
+			expRemoved: [][]uint{{2}, {3, 5, 6}},
+		},
+		"remove all": {
+			parentID:   3,
+			expOK:      true,
+			expDFC:     []uint{1, 2, 3, 4},
+			expRemoved: [][]uint{{5}, {6}},
+		},
+		"move in": {
+			parentID: 2,
+			childIDs: []uint{6, 4},
+			expOK:    true,
+			expDFC:   []uint{1, 2, 6, 4, 3, 5},
+		},
+		"move in from a removed child": {
+			parentID:   1,
+			childIDs:   []uint{5, 2},
+			expOK:      true,
+			expDFC:     []uint{1, 5, 2},
+			expRemoved: [][]uint{{3, 6}, {4}},
+		},
+		"parent does not exist": {
+			parentID: 7,
+			childIDs: []uint{2},
+			expDFC:   []uint{1, 2, 3, 5, 6, 4},
+		},
+		"child does not exist": {
+			parentID: 1,
+			childIDs: []uint{2, 7},
+			expDFC:   []uint{1, 2, 3, 5, 6, 4},
+		},
+		"child given twice": {
+			parentID: 1,
+			childIDs: []uint{2, 2},
+			expDFC:   []uint{1, 2, 3, 5, 6, 4},
+		},
+		"ancestor as child": {
+			parentID: 3,
+			childIDs: []uint{5, 1},
+			expDFC:   []uint{1, 2, 3, 5, 6, 4},
+		},
+		"itself as child": {
+			parentID: 3,
+			childIDs: []uint{3},
+			expDFC:   []uint{1, 2, 3, 5, 6, 4},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tree := orderedTree()
+			removed, ok := tree.ReplaceChildren(tt.parentID, tt.childIDs...)
+			assert.Equal(t, tt.expOK, ok)
+			assert.Equal(t, tt.expDFC, dfc[uint, string](tree.root, []uint{}))
+			assert.Empty(t, tree.Validate())
+
+			var gotRemoved [][]uint
+			for _, r := range removed {
+				gotRemoved = append(gotRemoved, dfc[uint, string](r.root, []uint{}))
+				assert.Empty(t, r.Validate())
+			}
+			assert.Equal(t, tt.expRemoved, gotRemoved)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package tree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// parallelChunkSize is the size in bytes of the chunks of a stream decoded by
+// each worker of DeserializeParallel.
+const parallelChunkSize = 1 << 20
+
+// DeserializeParallel decodes a data stream written by Serialize into a tree,
+// as Deserialize does, decoding the nodes on several goroutines. The stream is
+// split into chunks of whole lines, which are decoded by the given number of
+// workers, or by one per CPU if workers is not positive; the nodes are then
+// linked into a tree in a final pass. The tree is created by New with the
+// given options.
+//
+// The nodes may be in any order. For any stream that Deserialize reads in
+// full, such as one written by Serialize in either order, the tree is the
+// same as the one returned by Deserialize. Where Deserialize skips the nodes
+// it cannot link as they are read, DeserializeParallel fails with one of the
+// errors ErrDuplicateKey, ErrMultipleRoots, ErrNoRoot or ErrCycle.
+func DeserializeParallel[K comparable, T any](stream io.ReadCloser, workers int, opts ...TreeOption) (*Tree[K, T], error) {
+	return deserializeParallel[K, T](stream, workers, parallelChunkSize, opts...)
+}
+
+// chunk is a part of a serialized stream, holding whole lines.
+type chunk struct {
+	seq  int
+	data []byte
+}
+
+func deserializeParallel[K comparable, T any](stream io.ReadCloser, workers, chunkSize int, opts ...TreeOption) (*Tree[K, T], error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		decoded  [][]serialNode[K, T]
+	)
+	done := make(chan struct{})
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			close(done)
+		}
+	}
+
+	chunks := make(chan chunk, workers)
+	go func() {
+		defer close(chunks)
+		if err := readChunks(stream, chunkSize, chunks, done); err != nil {
+			fail(fmt.Errorf("error deserializing: %w", err))
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				nodes, err := decodeChunk[K, T](c.data)
+				if err != nil {
+					fail(fmt.Errorf("error deserializing: %w", err))
+					continue
+				}
+
+				mu.Lock()
+				for len(decoded) <= c.seq {
+					decoded = append(decoded, nil)
+				}
+				decoded[c.seq] = nodes
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	// link the nodes in the order they were read, as Deserialize adds them
+	var nodes []serialNode[K, T]
+	for _, d := range decoded {
+		nodes = append(nodes, d...)
+	}
+	return linkOrdered(New[K, T](opts...), nodes)
+}
+
+// readChunks splits a stream into chunks of about size bytes, each ending at
+// the end of a line, and sends them on chunks until the stream ends or done is
+// closed.
+func readChunks(stream io.Reader, size int, chunks chan<- chunk, done <-chan struct{}) error {
+	r := bufio.NewReader(stream)
+	for seq := 0; ; seq++ {
+		data := make([]byte, size)
+		n, err := io.ReadFull(r, data)
+		data = data[:n]
+
+		end := err != nil
+		switch err {
+		case nil:
+			// complete the last line
+			rest, err := r.ReadBytes('\n')
+			data = append(data, rest...)
+			if err == io.EOF {
+				end = true
+			} else if err != nil {
+				return err
+			}
+		case io.EOF, io.ErrUnexpectedEOF:
+		default:
+			return err
+		}
+
+		if len(data) > 0 {
+			select {
+			case chunks <- chunk{seq, data}:
+			case <-done:
+				return nil
+			}
+		}
+		if end {
+			return nil
+		}
+	}
+}
+
+// decodeChunk decodes the nodes held in a chunk of a serialized stream.
+func decodeChunk[K comparable, T any](data []byte) ([]serialNode[K, T], error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var nodes []serialNode[K, T]
+	for {
+		var n serialNode[K, T]
+		err := decoder.Decode(&n)
+		if err == io.EOF {
+			return nodes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+}
@@ -0,0 +1,186 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serialized returns the serialized form of a tree.
+func serialized(t *testing.T, serialize func(TraversalType) (io.ReadCloser, <-chan error), trvsl TraversalType) string {
+	rdr, errs := serialize(trvsl)
+	b, err := io.ReadAll(rdr)
+	assert.NoError(t, err)
+	assert.NoError(t, <-errs)
+	return string(b)
+}
+
+// bigTree returns a tree of n nodes with shuffled siblings, so that the order
+// of the children differs from the order of their keys.
+func bigTree(n int) *Tree[uint, string] {
+	t := Empty[uint, string]()
+	t.AddRoot(0, "root")
+	for i := 1; i < n; i++ {
+		t.Add(uint(i), uint(i-1)/5, fmt.Sprintf("node %d", i))
+	}
+	r := rand.New(rand.NewSource(1))
+	t.SortChildren(0, func(a, b Node[uint, string]) bool { return r.Intn(2) == 0 }, true)
+	return t
+}
+
+func TestDeserializeParallel(t *testing.T) {
+
+	tree := bigTree(2000)
+	bfs := serialized(t, tree.Serialize, TraverseBreadthFirst)
+	dfs := serialized(t, tree.Serialize, TraverseDepthFirst)
+
+	lines := strings.SplitAfter(bfs, "\n")
+	rand.New(rand.NewSource(2)).Shuffle(len(lines), func(i, j int) {
+		lines[i], lines[j] = lines[j], lines[i]
+	})
+	shuffled := strings.Join(lines, "")
+
+	var tests = map[string]struct {
+		in        string
+		workers   int
+		chunkSize int
+	}{
+		"breadth first": {
+			in:        bfs,
+			workers:   4,
+			chunkSize: 1000,
+		},
+		"depth first": {
+			in:        dfs,
+			workers:   3,
+			chunkSize: 100,
+		},
+		"one chunk": {
+			in:        dfs,
+			chunkSize: parallelChunkSize,
+		},
+		"one worker": {
+			in:        bfs,
+			workers:   1,
+			chunkSize: 10,
+		},
+		"any order": {
+			in:        shuffled,
+			workers:   4,
+			chunkSize: 1000,
+		},
+		"no final newline": {
+			in:        strings.TrimSuffix(bfs, "\n"),
+			workers:   2,
+			chunkSize: 1000,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := deserializeParallel[uint, string](io.NopCloser(strings.NewReader(tt.in)), tt.workers, tt.chunkSize)
+			assert.NoError(t, err)
+			assert.True(t, Equal(tree, got, func(a, b string) bool { return a == b }))
+			assert.Empty(t, got.Validate())
+		})
+	}
+}
+
+func TestDeserializeParallelSequential(t *testing.T) {
+
+	var tests = map[string]string{
+		"empty":          "",
+		"reroot":         `{"Primary":2,"ParentID":1}` + "\n" + `{"Primary":1,"ParentID":0}` + "\n" + `{"Primary":3,"ParentID":1}`,
+		"own parent":     `{"Primary":1,"ParentID":1}` + "\n" + `{"Primary":2,"ParentID":1}`,
+		"no parent":      `{"Primary":0,"ParentID":null}` + "\n" + `{"Primary":1,"ParentID":0}`,
+		"sibling order":  `{"Primary":1,"ParentID":0}` + "\n" + `{"Primary":2,"ParentID":1,"SiblingIndex":1}` + "\n" + `{"Primary":3,"ParentID":1,"SiblingIndex":0}`,
+		"sibling ties":   `{"Primary":1,"ParentID":0}` + "\n" + `{"Primary":3,"ParentID":1}` + "\n" + `{"Primary":2,"ParentID":1}`,
+		"several a line": `{"Primary":1,"ParentID":0} {"Primary":2,"ParentID":1}`,
+	}
+
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			exp, err := Deserialize[uint, string](io.NopCloser(strings.NewReader(in)))
+			assert.NoError(t, err)
+			got, err := deserializeParallel[uint, string](io.NopCloser(strings.NewReader(in)), 2, 10)
+			assert.NoError(t, err)
+
+			assert.Equal(t, serialized(t, exp.Serialize, TraverseDepthFirst), serialized(t, got.Serialize, TraverseDepthFirst))
+			if exp.root != nil {
+				assert.Equal(t, exp.root.HasParentID(), got.root.HasParentID())
+			}
+		})
+	}
+}
+
+func TestDeserializeParallelOptions(t *testing.T) {
+
+	in := serialized(t, orderedTree().Serialize, TraverseBreadthFirst)
+	got, err := DeserializeParallel[uint, string](io.NopCloser(strings.NewReader(in)), 0, NodeFactory(newCounted), Debug())
+	assert.NoError(t, err)
+	assert.True(t, got.config.debug)
+	for n := range got.Traverse(TraverseBreadthFirst) {
+		assert.IsType(t, &countedNode{}, n)
+	}
+}
+
+func TestDeserializeParallelError(t *testing.T) {
+
+	var tests = map[string]struct {
+		in     string
+		expErr error
+		expMsg string
+	}{
+		"malformed": {
+			in:     `{"Primary":1,"ParentID":0}` + "\n" + `{"Primary":2,` + "\n" + `{"Primary":3,"ParentID":1}`,
+			expMsg: "error deserializing",
+		},
+		"duplicate": {
+			in:     `{"Primary":1,"ParentID":0}` + "\n" + `{"Primary":2,"ParentID":1}` + "\n" + `{"Primary":2,"ParentID":1}`,
+			expErr: ErrDuplicateKey,
+		},
+		"orphan": {
+			in:     `{"Primary":1,"ParentID":0}` + "\n" + `{"Primary":2,"ParentID":9}`,
+			expErr: ErrMultipleRoots,
+		},
+		"cycle": {
+			in:     `{"Primary":1,"ParentID":2}` + "\n" + `{"Primary":2,"ParentID":1}`,
+			expErr: ErrNoRoot,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := deserializeParallel[uint, string](io.NopCloser(strings.NewReader(tt.in)), 2, 10)
+			assert.Error(t, err)
+			if tt.expErr != nil {
+				assert.True(t, errors.Is(err, tt.expErr), err)
+			}
+			assert.Contains(t, err.Error(), tt.expMsg)
+		})
+	}
+}
+
+func BenchmarkDeserialize(b *testing.B) {
+
+	var sb strings.Builder
+	rdr, _ := bigTree(benchSize).Serialize(TraverseBreadthFirst)
+	io.Copy(&sb, rdr)
+	in := sb.String()
+
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Deserialize[uint, string](io.NopCloser(strings.NewReader(in)))
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			DeserializeParallel[uint, string](io.NopCloser(strings.NewReader(in)), 0)
+		}
+	})
+}
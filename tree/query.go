@@ -0,0 +1,689 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrQuerySyntax is returned, wrapped with the position of the error, when a
+// query expression cannot be compiled.
+var ErrQuerySyntax = errors.New("tree: query syntax error")
+
+// Accessor resolves a field path, such as ["type"] for the operand data.type,
+// against the data of a node. It returns false if the path does not exist in
+// the data.
+type Accessor func(data any, path []string) (any, bool)
+
+// QueryOption modifies a query as it is compiled.
+type QueryOption func(*Query)
+
+// WithAccessor replaces the reflection-based lookup of data fields in query
+// predicates with a caller-supplied accessor.
+func WithAccessor(a Accessor) QueryOption {
+	return func(q *Query) {
+		q.access = a
+	}
+}
+
+// Query is a compiled path expression selecting nodes from a tree. Queries
+// are created with Compile and run with Select or Tree.Query. A compiled
+// query holds no reference to any tree and may be reused on any number of
+// trees, including concurrently.
+//
+// A query is a sequence of steps, each of which starts with a separator and
+// is followed by a node test and any number of predicates:
+//
+//	/name      children of the current nodes whose key is name
+//	/*         all children of the current nodes
+//	//name     descendants of the current nodes whose key is name
+//	//*        all descendants of the current nodes
+//
+// The first step starts from above the root, so that /name selects the root
+// if its key is name and //name selects every node with key name. Keys are
+// compared to names by their fmt.Sprint representation; a name may be
+// double-quoted if it contains spaces or any of the characters /[]*".
+//
+// A predicate is a boolean expression in square brackets; only the nodes for
+// which every predicate holds are kept. Predicates compare operands with ==,
+// !=, <, <=, > and >=, and combine comparisons with and, or and parentheses.
+// An operand is a string, number, true, false or null literal, or one of:
+//
+//	key          the primary key of the node
+//	depth        the depth of the node, the root having depth zero
+//	data         the data of the node
+//	data.a.b     a field of the data of the node, reached by an Accessor
+//
+// An operand on its own is true if it exists and is not a zero value. For
+// example, the following selects every descendant with key name of the nodes
+// with key child and type leaf found two levels below a root with key root:
+//
+//	/root/*/child[data.type == "leaf"]//name
+//
+// By default data fields are reached by reflection: struct fields by name or
+// json tag, map entries by string key and slice elements by index, following
+// pointers and interfaces.
+type Query struct {
+	expr   string
+	steps  []step
+	access Accessor
+}
+
+type axis int
+
+const (
+	axisChild axis = iota
+	axisDescendant
+)
+
+type step struct {
+	axis  axis
+	name  string
+	any   bool
+	preds []queryExpr
+}
+
+// Compile parses a query expression. If the expression is not valid, an
+// error wrapping ErrQuerySyntax is returned.
+func Compile(expr string, opts ...QueryOption) (*Query, error) {
+	p := &queryParser{src: expr}
+	steps, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{expr: expr, steps: steps, access: reflectAccessor}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q, nil
+}
+
+// MustCompile is like Compile, but panics if the expression is not valid.
+func MustCompile(expr string, opts ...QueryOption) *Query {
+	q, err := Compile(expr, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// String returns the source expression of the query.
+func (q *Query) String() string {
+	return q.expr
+}
+
+// Query compiles a query expression and selects the matching nodes from the
+// tree. See Query for the expression syntax.
+func (t *Tree[K, T]) Query(expr string, opts ...QueryOption) ([]Node[K, T], error) {
+	q, err := Compile(expr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return Select(t, q), nil
+}
+
+// Select runs a compiled query on a tree and returns the matching nodes. Each
+// node is returned once. The nodes selected by each step of a query are
+// ordered as a breadth first traversal starting from all of the nodes
+// selected by the step before.
+func Select[K comparable, T any](t *Tree[K, T], q *Query) []Node[K, T] {
+	if t.root == nil {
+		return nil
+	}
+
+	// the depth of each node visited is recorded during the walk; a node is
+	// always visited after its parent
+	depths := map[K]int{}
+
+	// a nil entry stands for the position above the root
+	current := []Node[K, T]{nil}
+	for _, s := range q.steps {
+		var next []Node[K, T]
+		seen := map[K]bool{}
+
+		match := func(n Node[K, T]) bool {
+			depth := 0
+			if p := n.GetParent(); p != nil {
+				depth = depths[p.GetID()] + 1
+			}
+			depths[n.GetID()] = depth
+
+			if seen[n.GetID()] || !s.matches(q, nodeView[K, T]{n, depth}) {
+				return true
+			}
+			seen[n.GetID()] = true
+			next = append(next, n)
+			return true
+		}
+
+		var children []Node[K, T]
+		for _, c := range current {
+			if c == nil {
+				children = append(children, t.root)
+			} else {
+				children = append(children, c.GetChildren()...)
+			}
+		}
+
+		if s.axis == axisDescendant {
+			breadthFirst(match, children...)
+		} else {
+			for _, child := range children {
+				match(child)
+			}
+		}
+
+		if len(next) == 0 {
+			return nil
+		}
+		current = next
+	}
+
+	return current
+}
+
+func (s step) matches(q *Query, n queryNode) bool {
+	if !s.any && fmt.Sprint(n.key()) != s.name {
+		return false
+	}
+	for _, p := range s.preds {
+		if !truthy(p.eval(q, n)) {
+			return false
+		}
+	}
+	return true
+}
+
+// queryNode gives the expressions of a query access to a node without
+// knowledge of its type parameters.
+type queryNode interface {
+	key() any
+	data() any
+	depth() int
+}
+
+type nodeView[K comparable, T any] struct {
+	n Node[K, T]
+	d int
+}
+
+func (v nodeView[K, T]) key() any {
+	return v.n.GetID()
+}
+
+func (v nodeView[K, T]) data() any {
+	return v.n.GetData()
+}
+
+func (v nodeView[K, T]) depth() int {
+	return v.d
+}
+
+// value is the result of evaluating an operand; ok is false if the operand
+// does not exist for the node.
+type value struct {
+	v  any
+	ok bool
+}
+
+type queryExpr interface {
+	eval(q *Query, n queryNode) value
+}
+
+type literalExpr struct {
+	v any
+}
+
+func (e literalExpr) eval(*Query, queryNode) value {
+	return value{e.v, true}
+}
+
+type operandExpr struct {
+	name string // key, depth or data
+	path []string
+}
+
+func (e operandExpr) eval(q *Query, n queryNode) value {
+	switch e.name {
+	case "key":
+		return value{n.key(), true}
+	case "depth":
+		return value{n.depth(), true}
+	}
+	if len(e.path) == 0 {
+		return value{n.data(), true}
+	}
+	v, ok := q.access(n.data(), e.path)
+	return value{v, ok}
+}
+
+type logicalExpr struct {
+	and  bool
+	l, r queryExpr
+}
+
+func (e logicalExpr) eval(q *Query, n queryNode) value {
+	l := truthy(e.l.eval(q, n))
+	if l != e.and {
+		return value{l, true}
+	}
+	return value{truthy(e.r.eval(q, n)), true}
+}
+
+type compareExpr struct {
+	op   string
+	l, r queryExpr
+}
+
+func (e compareExpr) eval(q *Query, n queryNode) value {
+	l, r := e.l.eval(q, n), e.r.eval(q, n)
+	if !l.ok || !r.ok {
+		return value{false, true}
+	}
+	return value{compare(e.op, normalize(l.v), normalize(r.v)), true}
+}
+
+// normalize converts numbers of any type to float64 and dereferences
+// pointers, so that values from node data can be compared to literals.
+func normalize(v any) any {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Invalid:
+		return nil
+	}
+	return rv.Interface()
+}
+
+func compare(op string, l, r any) bool {
+	switch op {
+	case "==":
+		return equalValues(l, r)
+	case "!=":
+		return !equalValues(l, r)
+	}
+
+	var c int
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			return false
+		}
+		switch {
+		case lv < rv:
+			c = -1
+		case lv > rv:
+			c = 1
+		}
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return false
+		}
+		c = strings.Compare(lv, rv)
+	default:
+		return false
+	}
+
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+func equalValues(l, r any) bool {
+	if l == nil || r == nil {
+		return l == nil && r == nil
+	}
+	if reflect.TypeOf(l).Comparable() && reflect.TypeOf(r).Comparable() {
+		return l == r
+	}
+	return reflect.DeepEqual(l, r)
+}
+
+func truthy(v value) bool {
+	if !v.ok || v.v == nil {
+		return false
+	}
+	if b, ok := v.v.(bool); ok {
+		return b
+	}
+	return !reflect.ValueOf(v.v).IsZero()
+}
+
+// reflectAccessor is the default Accessor. It walks struct fields, matched by
+// name or json tag, map entries with string keys and slice or array elements.
+func reflectAccessor(data any, path []string) (any, bool) {
+	rv := reflect.ValueOf(data)
+	for _, field := range path {
+		for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+			if rv.IsNil() {
+				return nil, false
+			}
+			rv = rv.Elem()
+		}
+
+		switch rv.Kind() {
+		case reflect.Struct:
+			f, ok := structField(rv, field)
+			if !ok {
+				return nil, false
+			}
+			rv = f
+		case reflect.Map:
+			if rv.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			rv = rv.MapIndex(reflect.ValueOf(field).Convert(rv.Type().Key()))
+			if !rv.IsValid() {
+				return nil, false
+			}
+		case reflect.Slice, reflect.Array:
+			i, err := strconv.Atoi(field)
+			if err != nil || i < 0 || i >= rv.Len() {
+				return nil, false
+			}
+			rv = rv.Index(i)
+		default:
+			return nil, false
+		}
+	}
+
+	if !rv.IsValid() || !rv.CanInterface() {
+		return nil, false
+	}
+	return rv.Interface(), true
+}
+
+func structField(rv reflect.Value, name string) (reflect.Value, bool) {
+	if f := rv.FieldByName(name); f.IsValid() && f.CanInterface() {
+		return f, true
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if tag == name || (tag == "" && strings.EqualFold(sf.Name, name)) {
+			return rv.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// queryParser is a recursive descent parser for query expressions.
+type queryParser struct {
+	src string
+	pos int
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w at offset %d in %q: %s", ErrQuerySyntax, p.pos, p.src, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) parse() ([]step, error) {
+	var steps []step
+	for p.pos < len(p.src) {
+		s, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+	}
+	if len(steps) == 0 {
+		return nil, p.errorf("empty query")
+	}
+	return steps, nil
+}
+
+func (p *queryParser) parseStep() (step, error) {
+	var s step
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "//"):
+		s.axis = axisDescendant
+		p.pos += 2
+	case strings.HasPrefix(p.src[p.pos:], "/"):
+		s.axis = axisChild
+		p.pos++
+	default:
+		return s, p.errorf("expected / or //")
+	}
+
+	switch {
+	case p.pos >= len(p.src):
+		return s, p.errorf("expected a name or *")
+	case p.src[p.pos] == '*':
+		s.any = true
+		p.pos++
+	case p.src[p.pos] == '"':
+		name, err := p.parseString()
+		if err != nil {
+			return s, err
+		}
+		s.name = name
+	default:
+		start := p.pos
+		for p.pos < len(p.src) && !strings.ContainsRune(`/[]*" `, rune(p.src[p.pos])) {
+			p.pos++
+		}
+		if p.pos == start {
+			return s, p.errorf("expected a name or *")
+		}
+		s.name = p.src[start:p.pos]
+	}
+
+	for p.pos < len(p.src) && p.src[p.pos] == '[' {
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return s, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != ']' {
+			return s, p.errorf("expected ]")
+		}
+		p.pos++
+		s.preds = append(s.preds, e)
+	}
+
+	return s, nil
+}
+
+func (p *queryParser) parseOr() (queryExpr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = logicalExpr{and: false, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	l, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		r, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		l = logicalExpr{and: true, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *queryParser) parseComparison() (queryExpr, error) {
+	l, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			p.pos += len(op)
+			r, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return compareExpr{op: op, l: l, r: r}, nil
+		}
+	}
+	return l, nil
+}
+
+func (p *queryParser) parseOperand() (queryExpr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("expected an operand")
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != ')' {
+			return nil, p.errorf("expected )")
+		}
+		p.pos++
+		return e, nil
+	case c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literalExpr{s}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && (p.src[p.pos] == '.' || (p.src[p.pos] >= '0' && p.src[p.pos] <= '9')) {
+			p.pos++
+		}
+		f, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number")
+		}
+		return literalExpr{f}, nil
+	}
+
+	start := p.pos
+	word := p.word()
+	switch word {
+	case "true":
+		return literalExpr{true}, nil
+	case "false":
+		return literalExpr{false}, nil
+	case "null":
+		return literalExpr{nil}, nil
+	case "key", "depth":
+		return operandExpr{name: word}, nil
+	case "data":
+		e := operandExpr{name: word}
+		for p.pos < len(p.src) && p.src[p.pos] == '.' {
+			p.pos++
+			field := p.word()
+			if field == "" {
+				return nil, p.errorf("expected a field name")
+			}
+			e.path = append(e.path, field)
+		}
+		return e, nil
+	}
+	p.pos = start
+	return nil, p.errorf("unknown operand %q", word)
+}
+
+// keyword consumes the keyword w if it is next in the input.
+func (p *queryParser) keyword(w string) bool {
+	p.skipSpace()
+	start := p.pos
+	if p.word() == w {
+		return true
+	}
+	p.pos = start
+	return false
+}
+
+func (p *queryParser) word() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *queryParser) parseString() (string, error) {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.src) && p.src[p.pos] != '"' {
+		if p.src[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		p.pos = start
+		return "", p.errorf("unterminated string")
+	}
+	p.pos++
+	s, err := strconv.Unquote(p.src[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return "", p.errorf("invalid string")
+	}
+	return s, nil
+}
+
+func (p *queryParser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
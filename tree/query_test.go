@@ -0,0 +1,224 @@
+package tree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type queryData struct {
+	Type  string `json:"type"`
+	Size  int
+	Tags  []string
+	Attrs map[string]any
+	Next  *queryData
+}
+
+// builds a tree of string keys:
+//
+//	root
+//	├── a
+//	│   ├── child (leaf, size 3)
+//	│   │   └── name
+//	│   └── other
+//	│       └── name
+//	└── b
+//	    └── child (branch, size 10)
+//	        └── x
+//	            └── name
+func queryTree() *Tree[string, queryData] {
+	t := Empty[string, queryData]()
+	t.Add("root", "", queryData{Type: "root"})
+	t.Add("a", "root", queryData{Type: "dir", Attrs: map[string]any{"color": "red", "größe": "L"}})
+	t.Add("b", "root", queryData{Type: "dir", Next: &queryData{Type: "link"}})
+	t.Add("a/child", "a", queryData{Type: "leaf", Size: 3, Tags: []string{"x", "y"}})
+	t.Add("a/other", "a", queryData{Type: "leaf"})
+	t.Add("b/child", "b", queryData{Type: "branch", Size: 10})
+	t.Add("a/child/name", "a/child", queryData{})
+	t.Add("a/other/name", "a/other", queryData{})
+	t.Add("b/child/x", "b/child", queryData{})
+	t.Add("b/child/x/name", "b/child/x", queryData{})
+	return t
+}
+
+func TestCompile(t *testing.T) {
+
+	var tests = map[string]struct {
+		expr   string
+		expErr error
+	}{
+		"root":                {expr: "/root"},
+		"wildcard":            {expr: "/*"},
+		"descendant":          {expr: "//name"},
+		"quoted name":         {expr: `/"a b"/"c/d"`},
+		"predicate":           {expr: `/root/*[data.type == "dir"]`},
+		"multiple predicates": {expr: `//*[depth >= 1][data.Size < 5]`},
+		"logical":             {expr: `//*[(key == "a" or key == "b") and data]`},
+		"literals":            {expr: `//*[data.x != null][data.y == true][data.z > -1.5]`},
+		"empty":               {expr: "", expErr: ErrQuerySyntax},
+		"no separator":        {expr: "root", expErr: ErrQuerySyntax},
+		"no name":             {expr: "/root/", expErr: ErrQuerySyntax},
+		"unclosed predicate":  {expr: "/root[key == 1", expErr: ErrQuerySyntax},
+		"unknown operand":     {expr: "/root[size == 1]", expErr: ErrQuerySyntax},
+		"unterminated string": {expr: `/root[key == "a]`, expErr: ErrQuerySyntax},
+		"missing field":       {expr: "/root[data. == 1]", expErr: ErrQuerySyntax},
+		"unclosed paren":      {expr: "/root[(key == 1]", expErr: ErrQuerySyntax},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotQuery, gotErr := Compile(tt.expr)
+
+			assert.ErrorIs(t, gotErr, tt.expErr)
+			if tt.expErr == nil {
+				assert.Equal(t, tt.expr, gotQuery.String())
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+
+	var tests = map[string]struct {
+		expr   string
+		opts   []QueryOption
+		expIDs []string
+	}{
+		"root": {
+			expr:   "/root",
+			expIDs: []string{"root"},
+		},
+		"root mismatch": {
+			expr: "/a",
+		},
+		"children": {
+			expr:   "/root/*",
+			expIDs: []string{"a", "b"},
+		},
+		"named child": {
+			expr:   "/root/b",
+			expIDs: []string{"b"},
+		},
+		"descendants": {
+			expr:   "//b",
+			expIDs: []string{"b"},
+		},
+		"descendants of node": {
+			expr:   `/root/b//*`,
+			expIDs: []string{"b/child", "b/child/x", "b/child/x/name"},
+		},
+		"all": {
+			expr: "//*",
+			expIDs: []string{"root", "a", "b", "a/child", "a/other", "b/child",
+				"a/child/name", "a/other/name", "b/child/x", "b/child/x/name"},
+		},
+		"quoted name": {
+			expr:   `//"a/child"`,
+			expIDs: []string{"a/child"},
+		},
+		"data field": {
+			expr:   `/root/*/*[data.type == "leaf"]`,
+			expIDs: []string{"a/child", "a/other"},
+		},
+		"data field by name": {
+			expr:   `//*[data.Type == "branch"]`,
+			expIDs: []string{"b/child"},
+		},
+		"numeric comparison": {
+			expr:   `//*[data.Size > 2][data.Size <= 3]`,
+			expIDs: []string{"a/child"},
+		},
+		"slice element": {
+			expr:   `//*[data.Tags.1 == "y"]`,
+			expIDs: []string{"a/child"},
+		},
+		"map entry": {
+			expr:   `//*[data.Attrs.color == "red"]`,
+			expIDs: []string{"a"},
+		},
+		"non-ASCII field": {
+			expr:   `//*[data.Attrs.größe == "L"]`,
+			expIDs: []string{"a"},
+		},
+		"pointer": {
+			expr:   `//*[data.Next.type == "link"]`,
+			expIDs: []string{"b"},
+		},
+		"missing field": {
+			expr: `//*[data.Missing == "x"]`,
+		},
+		"truthy": {
+			expr:   `//*[data.Size]`,
+			expIDs: []string{"a/child", "b/child"},
+		},
+		"depth": {
+			expr:   `//*[depth == 3]`,
+			expIDs: []string{"a/child/name", "a/other/name", "b/child/x"},
+		},
+		"depth below a step": {
+			expr:   `/root/b//*[depth >= 2]`,
+			expIDs: []string{"b/child", "b/child/x", "b/child/x/name"},
+		},
+		"key": {
+			expr:   `//*[key < "b" and depth == 1]`,
+			expIDs: []string{"a"},
+		},
+		"or": {
+			expr:   `//*[key == "a" or (data.Size >= 10)]`,
+			expIDs: []string{"a", "b/child"},
+		},
+		"descendant after predicate": {
+			expr:   `/root/*/*[data.type == "branch"]//*`,
+			expIDs: []string{"b/child/x", "b/child/x/name"},
+		},
+		"accessor": {
+			expr: `//*[data.kind == "dir"]`,
+			opts: []QueryOption{WithAccessor(func(data any, path []string) (any, bool) {
+				if len(path) == 1 && path[0] == "kind" {
+					return data.(queryData).Type, true
+				}
+				return nil, false
+			})},
+			expIDs: []string{"a", "b"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := Select(queryTree(), MustCompile(tt.expr, tt.opts...))
+
+			var gotIDs []string
+			for _, n := range got {
+				gotIDs = append(gotIDs, n.GetID())
+			}
+			if len(tt.expIDs) == 0 {
+				assert.Empty(t, gotIDs)
+			} else {
+				assert.Equal(t, tt.expIDs, gotIDs)
+			}
+		})
+	}
+}
+
+func TestTreeQuery(t *testing.T) {
+
+	tree := Empty[uint, int]()
+	tree.Add(1, 0, 10)
+	tree.Add(2, 1, 20)
+	tree.Add(3, 2, 30)
+	tree.Add(4, 1, 40)
+
+	got, err := tree.Query("/1//*[data > 25]")
+	if assert.NoError(t, err) {
+		var gotIDs []uint
+		for _, n := range got {
+			gotIDs = append(gotIDs, n.GetID())
+		}
+		assert.Equal(t, []uint{4, 3}, gotIDs)
+	}
+
+	_, err = tree.Query("1")
+	assert.ErrorIs(t, err, ErrQuerySyntax)
+
+	assert.Nil(t, Select(Empty[uint, int](), MustCompile("//*")))
+}
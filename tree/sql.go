@@ -0,0 +1,513 @@
+package tree
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// This file converts trees to and from the rows of the common relational
+// models of a hierarchy:
+//
+//   - adjacency list: one row per node holding its parent key
+//   - closure table: one row per ancestor and descendant pair
+//   - nested set: one row per node holding the left and right bounds of its
+//     subtree in a depth first numbering
+//   - materialized path: one row per node holding the keys of its ancestors
+//
+// Rows are written to a RowWriter and read from a RowReader, so that the
+// conversions are not tied to a database. ExecWriter writes rows to a
+// database/sql statement, and *sql.Rows is itself a RowReader.
+//
+// Node data is written as is and scanned into a T, so by default T must be a
+// type that database/sql handles, such as a string, a number or []byte, or
+// implement driver.Valuer and sql.Scanner. Other data, such as a struct, is
+// converted with the DataColumn or JSONColumn option.
+
+// RowWriter receives the rows of a tree exported to a relational model. The
+// values of each row are given in the column order documented by the
+// exporting method.
+type RowWriter interface {
+	WriteRow(values ...any) error
+}
+
+// RowWriterFunc is a function used as a RowWriter.
+type RowWriterFunc func(values ...any) error
+
+// WriteRow calls f(values...).
+func (f RowWriterFunc) WriteRow(values ...any) error {
+	return f(values...)
+}
+
+// ExecWriter returns a RowWriter that executes a prepared statement once for
+// each row, with the values of the row as the arguments of the statement.
+func ExecWriter(ctx context.Context, stmt *sql.Stmt) RowWriter {
+	return RowWriterFunc(func(values ...any) error {
+		_, err := stmt.ExecContext(ctx, values...)
+		return err
+	})
+}
+
+// RowReader supplies the rows from which a tree is imported. It is
+// implemented by *sql.Rows; the columns of each row must be in the order
+// documented by the importing function.
+type RowReader interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+}
+
+// RowOption configures the conversion of a tree to or from rows.
+type RowOption func(*rowConfig)
+
+type rowConfig struct {
+	// encode and decode are the func(T) (driver.Value, error) and
+	// func(any) (T, error) given to DataColumn, if any
+	encode, decode any
+}
+
+// DataColumn makes the conversions of a tree write the data of each node to
+// the data column as encode(data), and read it back with decode, which is
+// given the value scanned from the column. The type T must be the data type
+// of the tree.
+func DataColumn[T any](encode func(T) (driver.Value, error), decode func(src any) (T, error)) RowOption {
+	return func(c *rowConfig) {
+		c.encode, c.decode = encode, decode
+	}
+}
+
+// JSONColumn makes the conversions of a tree write the data of each node to
+// the data column as JSON text, and read it back from JSON text or bytes. The
+// type T must be the data type of the tree.
+func JSONColumn[T any]() RowOption {
+	return DataColumn(func(data T) (driver.Value, error) {
+		b, err := json.Marshal(data)
+		return string(b), err
+	}, func(src any) (T, error) {
+		var data T
+		var b []byte
+		switch src := src.(type) {
+		case string:
+			b = []byte(src)
+		case []byte:
+			b = src
+		default:
+			return data, fmt.Errorf("cannot decode %T as JSON", src)
+		}
+		err := json.Unmarshal(b, &data)
+		return data, err
+	})
+}
+
+// dataColumn converts the data of nodes to and from the values of the data
+// column, as configured by the options of a conversion.
+type dataColumn[T any] struct {
+	encode func(T) (driver.Value, error)
+	decode func(any) (T, error)
+}
+
+func newDataColumn[T any](opts []RowOption) (dataColumn[T], error) {
+	var c rowConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	var d dataColumn[T]
+	if c.encode == nil {
+		return d, nil
+	}
+	encode, ok := c.encode.(func(T) (driver.Value, error))
+	decode, _ := c.decode.(func(any) (T, error))
+	if !ok || decode == nil {
+		var zero T
+		return d, fmt.Errorf("DataColumn of %T used for data of type %T", c.encode, zero)
+	}
+	d.encode, d.decode = encode, decode
+	return d, nil
+}
+
+// value returns the value written to the data column for data.
+func (d dataColumn[T]) value(data T) (any, error) {
+	if d.encode == nil {
+		return data, nil
+	}
+	return d.encode(data)
+}
+
+// dest returns the destination given to Scan to read the data column into
+// data.
+func (d dataColumn[T]) dest(data *T) any {
+	if d.decode == nil {
+		return data
+	}
+	return &dataScanner[T]{decode: d.decode, data: data}
+}
+
+// dataScanner scans the data column with the decode function of DataColumn.
+type dataScanner[T any] struct {
+	decode func(any) (T, error)
+	data   *T
+}
+
+func (s *dataScanner[T]) Scan(src any) (err error) {
+	*s.data, err = s.decode(src)
+	return err
+}
+
+// PathSeparator separates the keys of the nodes in a materialized path.
+// Keys must not contain the separator in their fmt.Sprint representation.
+const PathSeparator = "/"
+
+// WriteAdjacency writes the tree as an adjacency list, one row per node with
+// the columns (id, parent_id, data). The rows are written in breadth first
+// order, so that every parent is written before its children. The parent of
+// the root is written as nil if the root has no parent key, so that it is
+// read back as a root with no parent key. The data is converted as set by
+// the options.
+func (t *Tree[K, T]) WriteAdjacency(w RowWriter, opts ...RowOption) error {
+	col, err := newDataColumn[T](opts)
+	if err != nil {
+		return err
+	}
+	breadthFirst(func(n Node[K, T]) bool {
+		var parentID any = n.GetParentID()
+		if !n.HasParentID() {
+			parentID = nil
+		}
+		var data any
+		if data, err = col.value(n.GetData()); err != nil {
+			err = fmt.Errorf("error encoding data of %v: %w", n.GetID(), err)
+			return false
+		}
+		err = w.WriteRow(n.GetID(), parentID, data)
+		return err == nil
+	}, t.root)
+	return err
+}
+
+// ReadAdjacency builds a tree from an adjacency list, with the columns
+// (id, parent_id, data) in each row. The rows may be in any order, and the
+// parent of the root may be NULL, in which case the root has no parent key.
+// Children are added in row order. The data is converted as set by the
+// options.
+func ReadAdjacency[K comparable, T any](r RowReader, opts ...RowOption) (*Tree[K, T], error) {
+	col, err := newDataColumn[T](opts)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []serialNode[K, T]
+	for r.Next() {
+		var n serialNode[K, T]
+		var parent nullable[K]
+		if err := r.Scan(&n.Primary, &parent, col.dest(&n.Data)); err != nil {
+			return nil, fmt.Errorf("error reading adjacency list: %w", err)
+		}
+		n.ParentID, n.noParent = parent.v, !parent.valid
+		nodes = append(nodes, n)
+	}
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("error reading adjacency list: %w", err)
+	}
+	return link(nodes)
+}
+
+// WriteClosure writes the tree as a closure table, with the columns
+// (ancestor, descendant, depth). There is one row for every node and each of
+// its ancestors, with the number of levels between them as the depth, and one
+// row of depth zero relating every node to itself. Node data is not written,
+// so the options, which convert the data, have no effect.
+func (t *Tree[K, T]) WriteClosure(w RowWriter, opts ...RowOption) error {
+	var ancestors []K
+	return depthFirst(t.root, func(n Node[K, T], depth int) error {
+		ancestors = append(ancestors[:depth], n.GetID())
+		for i := depth; i >= 0; i-- {
+			if err := w.WriteRow(ancestors[i], n.GetID(), depth-i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil)
+}
+
+// ReadClosure builds a tree from a closure table, with the columns
+// (ancestor, descendant, depth) in each row. The rows may be in any order;
+// only the rows of depth zero and one are needed. Since a closure table does
+// not hold node data, every node is given the zero value of T, and the
+// options have no effect.
+func ReadClosure[K comparable, T any](r RowReader, opts ...RowOption) (*Tree[K, T], error) {
+	var nodes []serialNode[K, T]
+	seen := map[K]int{}
+	parents := map[K]K{}
+
+	for r.Next() {
+		var ancestor, descendant K
+		var depth int
+		if err := r.Scan(&ancestor, &descendant, &depth); err != nil {
+			return nil, fmt.Errorf("error reading closure table: %w", err)
+		}
+		if depth > 1 {
+			continue
+		}
+		if _, ok := seen[descendant]; !ok {
+			seen[descendant] = len(nodes)
+			nodes = append(nodes, serialNode[K, T]{Primary: descendant, ParentID: descendant})
+		}
+		if depth == 1 {
+			parents[descendant] = ancestor
+		}
+	}
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("error reading closure table: %w", err)
+	}
+
+	// the root is related to no ancestor and keeps itself as parent
+	for k, i := range seen {
+		if p, ok := parents[k]; ok {
+			nodes[i].ParentID = p
+		}
+	}
+	return link(nodes)
+}
+
+// WriteNestedSet writes the tree as a nested set, with the columns
+// (id, lft, rgt, data). Nodes are numbered in depth first order starting from
+// one for the left bound of the root, and the bounds of every node enclose
+// those of its descendants. The rows are written in order of their left
+// bound. The data is converted as set by the options.
+func (t *Tree[K, T]) WriteNestedSet(w RowWriter, opts ...RowOption) error {
+	col, err := newDataColumn[T](opts)
+	if err != nil {
+		return err
+	}
+
+	type row struct {
+		n        Node[K, T]
+		lft, rgt int
+	}
+
+	var rows []*row
+	var open []*row
+	counter := 0
+	err = depthFirst(t.root, func(n Node[K, T], depth int) error {
+		counter++
+		r := &row{n: n, lft: counter}
+		rows = append(rows, r)
+		open = append(open[:depth], r)
+		return nil
+	}, func(n Node[K, T], depth int) error {
+		counter++
+		open[depth].rgt = counter
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rows {
+		data, err := col.value(r.n.GetData())
+		if err != nil {
+			return fmt.Errorf("error encoding data of %v: %w", r.n.GetID(), err)
+		}
+		if err := w.WriteRow(r.n.GetID(), r.lft, r.rgt, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadNestedSet builds a tree from a nested set, with the columns
+// (id, lft, rgt, data) in each row. The rows may be in any order. The parent
+// of each node is the node with the nearest enclosing bounds, and children
+// are ordered by their left bound. The data is converted as set by the
+// options.
+func ReadNestedSet[K comparable, T any](r RowReader, opts ...RowOption) (*Tree[K, T], error) {
+	col, err := newDataColumn[T](opts)
+	if err != nil {
+		return nil, err
+	}
+
+	type row struct {
+		n        serialNode[K, T]
+		lft, rgt int
+	}
+
+	var rows []row
+	for r.Next() {
+		var rw row
+		if err := r.Scan(&rw.n.Primary, &rw.lft, &rw.rgt, col.dest(&rw.n.Data)); err != nil {
+			return nil, fmt.Errorf("error reading nested set: %w", err)
+		}
+		rows = append(rows, rw)
+	}
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("error reading nested set: %w", err)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].lft < rows[j].lft
+	})
+
+	nodes := make([]serialNode[K, T], len(rows))
+	var open []row
+	for i, rw := range rows {
+		if rw.rgt <= rw.lft {
+			return nil, fmt.Errorf("error reading nested set: bounds of %v are not increasing", rw.n.Primary)
+		}
+		for len(open) > 0 && open[len(open)-1].rgt < rw.lft {
+			open = open[:len(open)-1]
+		}
+		if len(open) > 0 {
+			if open[len(open)-1].rgt < rw.rgt {
+				return nil, fmt.Errorf("error reading nested set: bounds of %v overlap those of %v", rw.n.Primary, open[len(open)-1].n.Primary)
+			}
+			rw.n.ParentID = open[len(open)-1].n.Primary
+		} else {
+			rw.n.ParentID = rw.n.Primary
+		}
+		nodes[i] = rw.n
+		open = append(open, rw)
+	}
+
+	return link(nodes)
+}
+
+// WriteMaterializedPath writes the tree as materialized paths, with the
+// columns (id, path, data). The path of a node is the keys of the root, each
+// ancestor and the node itself, formatted with fmt.Sprint and joined with
+// PathSeparator. The rows are written in depth first order. The data is
+// converted as set by the options.
+func (t *Tree[K, T]) WriteMaterializedPath(w RowWriter, opts ...RowOption) error {
+	col, err := newDataColumn[T](opts)
+	if err != nil {
+		return err
+	}
+	var path []string
+	return depthFirst(t.root, func(n Node[K, T], depth int) error {
+		path = append(path[:depth], fmt.Sprint(n.GetID()))
+		data, err := col.value(n.GetData())
+		if err != nil {
+			return fmt.Errorf("error encoding data of %v: %w", n.GetID(), err)
+		}
+		return w.WriteRow(n.GetID(), strings.Join(path, PathSeparator), data)
+	}, nil)
+}
+
+// ReadMaterializedPath builds a tree from materialized paths, with the
+// columns (id, path, data) in each row. The rows may be in any order. The
+// parent of each node is the node whose path is the path of the node without
+// its last key. The data is converted as set by the options.
+func ReadMaterializedPath[K comparable, T any](r RowReader, opts ...RowOption) (*Tree[K, T], error) {
+	col, err := newDataColumn[T](opts)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []serialNode[K, T]
+	var paths []string
+	byPath := map[string]K{}
+
+	for r.Next() {
+		var n serialNode[K, T]
+		var path string
+		if err := r.Scan(&n.Primary, &path, col.dest(&n.Data)); err != nil {
+			return nil, fmt.Errorf("error reading materialized path: %w", err)
+		}
+		byPath[path] = n.Primary
+		nodes = append(nodes, n)
+		paths = append(paths, path)
+	}
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("error reading materialized path: %w", err)
+	}
+
+	for i, path := range paths {
+		nodes[i].ParentID = nodes[i].Primary
+		if j := strings.LastIndex(path, PathSeparator); j >= 0 {
+			parent, ok := byPath[path[:j]]
+			if !ok {
+				return nil, fmt.Errorf("error reading materialized path: no parent for %q", path)
+			}
+			nodes[i].ParentID = parent
+		}
+	}
+
+	return link(nodes)
+}
+
+// depthFirst walks the subtree below start in depth first order, calling pre
+// before the children of a node are visited and post after, with the depth of
+// the node below start. Either function may be nil. The walk stops at the
+// first error, which is returned.
+func depthFirst[K comparable, T any](start Node[K, T], pre, post func(Node[K, T], int) error) error {
+	if start == nil {
+		return nil
+	}
+
+	type frame struct {
+		n        Node[K, T]
+		children []Node[K, T]
+		next     int
+	}
+	stack := []frame{{n: start, children: start.GetChildren()}}
+	if pre != nil {
+		if err := pre(start, 0); err != nil {
+			return err
+		}
+	}
+
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next < len(top.children) {
+			c := top.children[top.next]
+			top.next++
+			if pre != nil {
+				if err := pre(c, len(stack)); err != nil {
+					return err
+				}
+			}
+			stack = append(stack, frame{n: c, children: c.GetChildren()})
+			continue
+		}
+
+		stack = stack[:len(stack)-1]
+		if post != nil {
+			if err := post(top.n, len(stack)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nullable scans a key that may be NULL, leaving the zero value in its place.
+type nullable[K any] struct {
+	v     K
+	valid bool
+}
+
+func (n *nullable[K]) Scan(src any) error {
+	var zero K
+	n.v, n.valid = zero, false
+	if src == nil {
+		return nil
+	}
+	n.valid = true
+
+	dst := reflect.ValueOf(&n.v).Elem()
+	sv := reflect.ValueOf(src)
+	switch {
+	case sv.Kind() == reflect.Slice && sv.Type().Elem().Kind() == reflect.Uint8 && dst.Kind() == reflect.String:
+		dst.SetString(string(sv.Bytes()))
+	case dst.Kind() == reflect.String && sv.Kind() != reflect.String:
+		dst.SetString(fmt.Sprint(src))
+	case sv.Type().ConvertibleTo(dst.Type()):
+		dst.Set(sv.Convert(dst.Type()))
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, n.v)
+	}
+	return nil
+}
@@ -0,0 +1,601 @@
+package tree
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDriver is a minimal database/sql driver storing rows in memory. A
+// statement "insert <table>" appends its arguments as a row of the table,
+// and a query "select <table>" returns all rows of the table.
+type fakeDriver struct {
+	mu     sync.Mutex
+	tables map[string][][]driver.Value
+}
+
+var fakeDB = &fakeDriver{tables: map[string][][]driver.Value{}}
+
+func init() {
+	sql.Register("treefake", fakeDB)
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return fakeConn{d}, nil
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	verb, table, ok := strings.Cut(query, " ")
+	if !ok || (verb != "insert" && verb != "select") {
+		return nil, errors.New("fake driver: unsupported query")
+	}
+	return fakeStmt{d: c.d, verb: verb, table: table}, nil
+}
+
+func (c fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fake driver: transactions not supported")
+}
+
+type fakeStmt struct {
+	d     *fakeDriver
+	verb  string
+	table string
+}
+
+func (s fakeStmt) Close() error {
+	return nil
+}
+
+func (s fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.tables[s.table] = append(s.d.tables[s.table], append([]driver.Value{}, args...))
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	rows := s.d.tables[s.table]
+	if len(rows) == 0 {
+		return &fakeRows{}, nil
+	}
+	return &fakeRows{rows: rows, cols: len(rows[0])}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+	cols int
+}
+
+func (r *fakeRows) Columns() []string {
+	return make([]string, r.cols)
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// sliceRows is a RowReader over rows held in memory.
+type sliceRows struct {
+	rows [][]any
+	cur  []any
+}
+
+func (r *sliceRows) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	r.cur, r.rows = r.rows[0], r.rows[1:]
+	return true
+}
+
+func (r *sliceRows) Scan(dest ...any) error {
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *uint:
+			*d = r.cur[i].(uint)
+		case *int:
+			*d = r.cur[i].(int)
+		case *string:
+			*d = r.cur[i].(string)
+		case sql.Scanner:
+			if err := d.Scan(r.cur[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *sliceRows) Err() error {
+	return nil
+}
+
+// collects the rows written to a RowWriter
+func collect(write func(RowWriter) error) ([][]any, error) {
+	var rows [][]any
+	err := write(RowWriterFunc(func(values ...any) error {
+		rows = append(rows, values)
+		return nil
+	}))
+	return rows, err
+}
+
+func sqlTree() *Tree[uint, string] {
+	t := Empty[uint, string]()
+	t.Add(1, 0, "one")
+	t.Add(2, 1, "two")
+	t.Add(3, 2, "three")
+	t.Add(4, 1, "four")
+	t.Add(5, 4, "five")
+	t.Add(6, 2, "six")
+	return t
+}
+
+func TestWriteRows(t *testing.T) {
+
+	var tests = map[string]struct {
+		write   func(*Tree[uint, string], RowWriter, ...RowOption) error
+		expRows [][]any
+	}{
+		"adjacency": {
+			write: (*Tree[uint, string]).WriteAdjacency,
+			expRows: [][]any{
+				{uint(1), uint(0), "one"},
+				{uint(2), uint(1), "two"},
+				{uint(4), uint(1), "four"},
+				{uint(3), uint(2), "three"},
+				{uint(6), uint(2), "six"},
+				{uint(5), uint(4), "five"},
+			},
+		},
+		"closure": {
+			write: (*Tree[uint, string]).WriteClosure,
+			expRows: [][]any{
+				{uint(1), uint(1), 0},
+				{uint(2), uint(2), 0},
+				{uint(1), uint(2), 1},
+				{uint(3), uint(3), 0},
+				{uint(2), uint(3), 1},
+				{uint(1), uint(3), 2},
+				{uint(6), uint(6), 0},
+				{uint(2), uint(6), 1},
+				{uint(1), uint(6), 2},
+				{uint(4), uint(4), 0},
+				{uint(1), uint(4), 1},
+				{uint(5), uint(5), 0},
+				{uint(4), uint(5), 1},
+				{uint(1), uint(5), 2},
+			},
+		},
+		"nested set": {
+			write: (*Tree[uint, string]).WriteNestedSet,
+			expRows: [][]any{
+				{uint(1), 1, 12, "one"},
+				{uint(2), 2, 7, "two"},
+				{uint(3), 3, 4, "three"},
+				{uint(6), 5, 6, "six"},
+				{uint(4), 8, 11, "four"},
+				{uint(5), 9, 10, "five"},
+			},
+		},
+		"materialized path": {
+			write: (*Tree[uint, string]).WriteMaterializedPath,
+			expRows: [][]any{
+				{uint(1), "1", "one"},
+				{uint(2), "1/2", "two"},
+				{uint(3), "1/2/3", "three"},
+				{uint(6), "1/2/6", "six"},
+				{uint(4), "1/4", "four"},
+				{uint(5), "1/4/5", "five"},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotRows, gotErr := collect(func(w RowWriter) error {
+				return tt.write(sqlTree(), w)
+			})
+
+			assert.NoError(t, gotErr)
+			assert.Equal(t, tt.expRows, gotRows)
+
+			emptyRows, emptyErr := collect(func(w RowWriter) error {
+				return tt.write(Empty[uint, string](), w)
+			})
+			assert.NoError(t, emptyErr)
+			assert.Empty(t, emptyRows)
+		})
+	}
+}
+
+func TestWriteRowsError(t *testing.T) {
+
+	expErr := errors.New("write failed")
+	w := RowWriterFunc(func(values ...any) error {
+		return expErr
+	})
+
+	tree := sqlTree()
+	assert.ErrorIs(t, tree.WriteAdjacency(w), expErr)
+	assert.ErrorIs(t, tree.WriteClosure(w), expErr)
+	assert.ErrorIs(t, tree.WriteNestedSet(w), expErr)
+	assert.ErrorIs(t, tree.WriteMaterializedPath(w), expErr)
+}
+
+func TestReadRows(t *testing.T) {
+
+	type reader func(RowReader, ...RowOption) (*Tree[uint, string], error)
+
+	var tests = map[string]struct {
+		read    reader
+		rows    [][]any
+		expErr  error
+		expBFC  []uint
+		expData bool
+	}{
+		"adjacency - out of order": {
+			read: ReadAdjacency[uint, string],
+			rows: [][]any{
+				{uint(3), uint(2), "three"},
+				{uint(2), uint(1), "two"},
+				{uint(4), uint(1), "four"},
+				{uint(1), nil, "one"},
+			},
+			expBFC:  []uint{1, 2, 4, 3},
+			expData: true,
+		},
+		"adjacency - zero key": {
+			read: ReadAdjacency[uint, string],
+			rows: [][]any{
+				{uint(1), uint(0), "one"},
+				{uint(0), nil, "zero"},
+				{uint(2), uint(0), "two"},
+			},
+			expBFC: []uint{0, 1, 2},
+		},
+		"adjacency - multiple roots": {
+			read: ReadAdjacency[uint, string],
+			rows: [][]any{
+				{uint(1), nil, "one"},
+				{uint(2), uint(9), "two"},
+			},
+			expErr: ErrMultipleRoots,
+		},
+		"adjacency - duplicate keys": {
+			read: ReadAdjacency[uint, string],
+			rows: [][]any{
+				{uint(1), nil, "one"},
+				{uint(1), nil, "one"},
+			},
+			expErr: ErrDuplicateKey,
+		},
+		"adjacency - no root": {
+			read: ReadAdjacency[uint, string],
+			rows: [][]any{
+				{uint(1), uint(2), "one"},
+				{uint(2), uint(1), "two"},
+			},
+			expErr: ErrNoRoot,
+		},
+		"adjacency - cycle": {
+			read: ReadAdjacency[uint, string],
+			rows: [][]any{
+				{uint(1), nil, "one"},
+				{uint(2), uint(3), "two"},
+				{uint(3), uint(2), "three"},
+			},
+			expErr: ErrCycle,
+		},
+		"closure - out of order": {
+			read: ReadClosure[uint, string],
+			rows: [][]any{
+				{uint(1), uint(3), 2},
+				{uint(2), uint(3), 1},
+				{uint(3), uint(3), 0},
+				{uint(1), uint(4), 1},
+				{uint(1), uint(2), 1},
+				{uint(1), uint(1), 0},
+			},
+			expBFC: []uint{1, 4, 2, 3},
+		},
+		"nested set - out of order": {
+			read: ReadNestedSet[uint, string],
+			rows: [][]any{
+				{uint(4), 6, 7, "four"},
+				{uint(3), 3, 4, "three"},
+				{uint(1), 1, 8, "one"},
+				{uint(2), 2, 5, "two"},
+			},
+			expBFC:  []uint{1, 2, 4, 3},
+			expData: true,
+		},
+		"nested set - overlapping bounds": {
+			read: ReadNestedSet[uint, string],
+			rows: [][]any{
+				{uint(1), 1, 4, "one"},
+				{uint(2), 2, 5, "two"},
+			},
+			expErr: errors.New("error reading nested set: bounds of 2 overlap those of 1"),
+		},
+		"materialized path - out of order": {
+			read: ReadMaterializedPath[uint, string],
+			rows: [][]any{
+				{uint(3), "1/2/3", "three"},
+				{uint(4), "1/4", "four"},
+				{uint(1), "1", "one"},
+				{uint(2), "1/2", "two"},
+			},
+			expBFC:  []uint{1, 4, 2, 3},
+			expData: true,
+		},
+		"materialized path - missing parent": {
+			read: ReadMaterializedPath[uint, string],
+			rows: [][]any{
+				{uint(1), "1", "one"},
+				{uint(3), "1/2/3", "three"},
+			},
+			expErr: errors.New(`error reading materialized path: no parent for "1/2/3"`),
+		},
+	}
+
+	names := map[uint]string{1: "one", 2: "two", 3: "three", 4: "four"}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotTree, gotErr := tt.read(&sliceRows{rows: tt.rows})
+
+			if tt.expErr != nil {
+				if errors.Is(gotErr, tt.expErr) {
+					return
+				}
+				assert.EqualError(t, gotErr, tt.expErr.Error())
+				return
+			}
+
+			if assert.NoError(t, gotErr) {
+				assert.Equal(t, tt.expBFC, bfc([]Node[uint, string]{gotTree.root}, []uint{}))
+				assert.Equal(t, uint(0), gotTree.root.GetParentID())
+				if tt.expData {
+					for id, data := range names {
+						n, _ := gotTree.Find(id)
+						assert.Equal(t, data, n.GetData())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSQLRoundTrip(t *testing.T) {
+
+	type model struct {
+		write func(*Tree[uint, string], RowWriter, ...RowOption) error
+		read  func(RowReader, ...RowOption) (*Tree[uint, string], error)
+		data  bool
+	}
+
+	var tests = map[string]model{
+		"adjacency": {
+			write: (*Tree[uint, string]).WriteAdjacency,
+			read:  ReadAdjacency[uint, string],
+			data:  true,
+		},
+		"closure": {
+			write: (*Tree[uint, string]).WriteClosure,
+			read:  ReadClosure[uint, string],
+		},
+		"nested set": {
+			write: (*Tree[uint, string]).WriteNestedSet,
+			read:  ReadNestedSet[uint, string],
+			data:  true,
+		},
+		"materialized path": {
+			write: (*Tree[uint, string]).WriteMaterializedPath,
+			read:  ReadMaterializedPath[uint, string],
+			data:  true,
+		},
+	}
+
+	db, err := sql.Open("treefake", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			table := strings.ReplaceAll(name, " ", "_")
+
+			stmt, err := db.PrepareContext(ctx, "insert "+table)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer stmt.Close()
+
+			orig := sqlTree()
+			if !assert.NoError(t, tt.write(orig, ExecWriter(ctx, stmt))) {
+				return
+			}
+
+			rows, err := db.QueryContext(ctx, "select "+table)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer rows.Close()
+
+			gotTree, gotErr := tt.read(rows)
+			if assert.NoError(t, gotErr) {
+				var eq func(a, b string) bool
+				if tt.data {
+					eq = func(a, b string) bool { return a == b }
+				}
+				assert.True(t, Equal(orig, gotTree, eq))
+			}
+		})
+	}
+}
+
+type sqlData struct {
+	Name string
+	Size int
+}
+
+func TestSQLData(t *testing.T) {
+
+	type model struct {
+		write func(*Tree[uint, sqlData], RowWriter, ...RowOption) error
+		read  func(RowReader, ...RowOption) (*Tree[uint, sqlData], error)
+	}
+	models := map[string]model{
+		"adjacency":         {(*Tree[uint, sqlData]).WriteAdjacency, ReadAdjacency[uint, sqlData]},
+		"nested set":        {(*Tree[uint, sqlData]).WriteNestedSet, ReadNestedSet[uint, sqlData]},
+		"materialized path": {(*Tree[uint, sqlData]).WriteMaterializedPath, ReadMaterializedPath[uint, sqlData]},
+	}
+
+	codec := DataColumn(func(d sqlData) (driver.Value, error) {
+		return fmt.Sprintf("%s:%d", d.Name, d.Size), nil
+	}, func(src any) (sqlData, error) {
+		var d sqlData
+		name, size, _ := strings.Cut(src.(string), ":")
+		d.Name = name
+		_, err := fmt.Sscan(size, &d.Size)
+		return d, err
+	})
+
+	var tests = map[string]struct {
+		opts     []RowOption
+		expErr   bool
+		expWrite string
+	}{
+		"json": {
+			opts: []RowOption{JSONColumn[sqlData]()},
+		},
+		"codec": {
+			opts: []RowOption{codec},
+		},
+		"no option": {
+			expErr: true,
+		},
+		"wrong type": {
+			opts:     []RowOption{JSONColumn[string]()},
+			expErr:   true,
+			expWrite: "DataColumn of",
+		},
+	}
+
+	orig := Empty[uint, sqlData]()
+	orig.Add(1, 0, sqlData{"one", 1})
+	orig.Add(2, 1, sqlData{"two", 2})
+	orig.Add(3, 1, sqlData{"three", 3})
+
+	db, err := sql.Open("treefake", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	for name, tt := range tests {
+		for mname, m := range models {
+			t.Run(name+"/"+mname, func(t *testing.T) {
+				table := strings.ReplaceAll("data "+name+" "+mname, " ", "_")
+
+				stmt, err := db.PrepareContext(ctx, "insert "+table)
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer stmt.Close()
+
+				err = m.write(orig, ExecWriter(ctx, stmt), tt.opts...)
+				if tt.expErr {
+					assert.Error(t, err)
+					assert.Contains(t, err.Error(), tt.expWrite)
+					return
+				}
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				rows, err := db.QueryContext(ctx, "select "+table)
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer rows.Close()
+
+				got, err := m.read(rows, tt.opts...)
+				if assert.NoError(t, err) {
+					assert.True(t, Equal(orig, got, func(a, b sqlData) bool { return a == b }))
+				}
+			})
+		}
+	}
+}
+
+func TestAdjacencyRootParent(t *testing.T) {
+
+	var tests = map[string]struct {
+		prep func(*Tree[uint, string])
+	}{
+		"zero parent key": {
+			prep: func(t *Tree[uint, string]) { t.Add(1, 0, "one") },
+		},
+		"parent key": {
+			prep: func(t *Tree[uint, string]) { t.Add(1, 7, "one") },
+		},
+		"no parent key": {
+			prep: func(t *Tree[uint, string]) { t.AddRoot(1, "one") },
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			orig := Empty[uint, string]()
+			tt.prep(orig)
+			orig.Add(2, 1, "two")
+
+			rows, err := collect(func(w RowWriter) error { return orig.WriteAdjacency(w) })
+			assert.NoError(t, err)
+			got, err := ReadAdjacency[uint, string](&sliceRows{rows: rows})
+			if assert.NoError(t, err) {
+				assert.True(t, Equal(orig, got, func(a, b string) bool { return a == b }))
+				assert.Equal(t, orig.root.HasParentID(), got.root.HasParentID())
+				assert.Equal(t, orig.root.GetParentID(), got.root.GetParentID())
+			}
+		})
+	}
+}
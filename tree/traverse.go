@@ -1,7 +1,7 @@
 package tree
 
 import (
-	"github.com/phf/go-queue/queue"
+	"github.com/kingledion/go-tools/queue"
 )
 
 // TraversalType determines the order in which an operation is performed on a tree.
@@ -29,43 +29,77 @@ const (
 //
 // If a tree is modified after the traversal has begun, any node that is
 // added after its correct place in traversal order will not be visited, nor
-// will any of its children.
+// will any of its children. An unknown TraversalType visits no nodes.
 func (t *Tree[K, T]) Traverse(trvsl TraversalType) <-chan Node[K, T] {
 	search := make(chan Node[K, T])
 
-	switch trvsl {
-	case TraverseBreadthFirst:
-		q := queue.New()
-		q.PushBack(t.root)
-		go func() {
-			for {
-				if bfs(q, search) {
-					close(search)
-					break
+	// The work list is used as a queue for a breadth first traversal and as
+	// a stack for a depth first one.
+	var work queue.Deque[Node[K, T]]
+	if t.root != nil {
+		work.PushBack(t.root)
+	}
+
+	go func() {
+		defer close(search)
+		var children []Node[K, T]
+		collect := func(c Node[K, T]) bool {
+			children = append(children, c)
+			return true
+		}
+		push := func(c Node[K, T]) bool {
+			work.PushBack(c)
+			return true
+		}
+		for {
+			var current Node[K, T]
+			var ok bool
+			switch trvsl {
+			case TraverseBreadthFirst:
+				current, ok = work.PopFront()
+			case TraverseDepthFirst:
+				current, ok = work.PopBack()
+			}
+			if !ok {
+				return
+			}
+
+			if trvsl == TraverseDepthFirst {
+				// push in reverse so that the first child is popped first
+				children = children[:0]
+				current.eachChild(collect)
+				for i := len(children) - 1; i >= 0; i-- {
+					work.PushBack(children[i])
 				}
+			} else {
+				current.eachChild(push)
 			}
-		}()
-	}
+			search <- current
+		}
+	}()
 
 	return search
 
 }
 
-func bfs[K comparable, T any](q *queue.Queue, search chan<- Node[K, T]) bool {
-
-	current := q.PopFront()
-	switch c := current.(type) {
-	case Node[K, T]:
-		for _, c := range c.GetChildren() {
-			q.PushBack(c)
+// breadthFirst visits the subtrees below the start nodes, including the
+// start nodes themselves, in breadth first order and without starting a
+// goroutine. Visiting stops early if visit returns false.
+func breadthFirst[K comparable, T any](visit func(Node[K, T]) bool, start ...Node[K, T]) {
+	var q queue.FIFO[Node[K, T]]
+	for _, n := range start {
+		if n != nil {
+			q.Push(n)
 		}
-		search <- c
-		return false
-	case nil:
-		return true
-	default:
-		// Should be unreachable...
+	}
+	push := func(c Node[K, T]) bool {
+		q.Push(c)
 		return true
 	}
-
+	for n, ok := q.Pop(); ok; n, ok = q.Pop() {
+		if !visit(n) {
+			return
+		}
+		n.eachChild(push)
+	}
 }
@@ -15,12 +15,12 @@ func TestBFS(t *testing.T) {
 	}{
 		"success": {
 			tree: func() *Tree[uint, int] {
-				node6 := &node[uint, int]{primary: 6}
-				node5 := &node[uint, int]{primary: 5}
-				node4 := &node[uint, int]{primary: 4}
-				node3 := &node[uint, int]{primary: 3, children: []Node[uint, int]{node4, node5}}
-				node2 := &node[uint, int]{primary: 2, children: []Node[uint, int]{node6}}
-				node1 := &node[uint, int]{primary: 1, children: []Node[uint, int]{node2, node3}}
+				node6 := &BaseNode[uint, int]{primary: 6}
+				node5 := &BaseNode[uint, int]{primary: 5}
+				node4 := &BaseNode[uint, int]{primary: 4}
+				node3 := &BaseNode[uint, int]{primary: 3, children: []Node[uint, int]{node4, node5}}
+				node2 := &BaseNode[uint, int]{primary: 2, children: []Node[uint, int]{node6}}
+				node1 := &BaseNode[uint, int]{primary: 1, children: []Node[uint, int]{node2, node3}}
 				return &Tree[uint, int]{root: node1}
 			},
 			traversal: TraverseBreadthFirst,
@@ -39,3 +39,40 @@ func TestBFS(t *testing.T) {
 		})
 	}
 }
+
+func TestDFS(t *testing.T) {
+
+	tests := map[string]struct {
+		tree      func() *Tree[uint, int]
+		expSearch []uint
+	}{
+		"success": {
+			tree: func() *Tree[uint, int] {
+				node6 := &BaseNode[uint, int]{primary: 6}
+				node5 := &BaseNode[uint, int]{primary: 5}
+				node4 := &BaseNode[uint, int]{primary: 4}
+				node3 := &BaseNode[uint, int]{primary: 3, children: []Node[uint, int]{node4, node5}}
+				node2 := &BaseNode[uint, int]{primary: 2, children: []Node[uint, int]{node6}}
+				node1 := &BaseNode[uint, int]{primary: 1, children: []Node[uint, int]{node2, node3}}
+				return &Tree[uint, int]{root: node1}
+			},
+			expSearch: []uint{1, 2, 6, 3, 4, 5},
+		},
+		"empty tree": {
+			tree: func() *Tree[uint, int] {
+				return &Tree[uint, int]{}
+			},
+			expSearch: []uint{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := []uint{}
+			for g := range tt.tree().Traverse(TraverseDepthFirst) {
+				got = append(got, g.GetID())
+			}
+			assert.Equal(t, tt.expSearch, got)
+		})
+	}
+}
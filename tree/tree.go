@@ -15,11 +15,34 @@ and an index allowing fast lookup of nodes by their primary key. Each node
 holds the primary key of itself and its parent, as well as a pointer to its
 parent and an array of pointers to its children. All nodes also store an
 arbitrary set of node data, which can be any structure. Children of a node are
-not ordered, and therefore are traversed in an order determined by the order
-in which they are added to the tree.
+ordered. By default a new child is placed after its existing siblings, so
+children are traversed in the order in which they are added to the tree; the
+order can be controlled with InsertAt, MoveBefore, MoveAfter and SortChildren.
+
+Nodes are read-only apart from their data. The structure of a tree is changed
+only through the methods of Tree: Add and InsertAt add nodes, Move moves a
+node to another parent, ReplaceChildren replaces or reorders the children of a
+node, and Merge and Remove graft and detach subtrees. A root added with
+AddRoot has no parent key, which lets the zero value of the key type be used
+as the key of a node.
+
+The nodes of a tree are BaseNode values. A tree created with New and the
+NodeFactory option uses a custom node type embedding BaseNode instead, to
+attach other fields to its nodes.
+
+For trees of millions of nodes, the Arena option stores the nodes of a tree
+in contiguous slices, linked by number rather than by pointer, which takes
+less memory and puts less load on the garbage collector.
+A tree too large for memory can be written to chunk files with
+SerializeChunks and read on demand as a Lazy tree, which loads the children of
+a node when they are needed and evicts those least recently used.
 
 This package includes tree traversal algorithms for breadth-first and depth-
 first search.
+
+The invariants linking the nodes and indexes of a tree can be checked with
+Validate. A tree created with New and the Debug option checks them after
+every change, which is useful in tests.
 */
 package tree
 
@@ -27,13 +50,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+
+	"github.com/kingledion/go-tools/queue"
 )
 
 // Tree is a data structure representing a tree. It contains a pointer to
-// a root node and an index of primary keys implemented as a hash map.
+// a root node and an index of primary keys implemented as a hash map, as
+// well as any secondary indexes over node data added with AddIndex.
 type Tree[K comparable, T any] struct {
-	root    Node[K, T]
-	primary *index[K, T]
+	root      Node[K, T]
+	primary   keyIndex[K, T]
+	secondary map[string]*dataIndex[K, T]
+	config    treeConfig
+	// arena holds the nodes of a tree with the Arena option
+	arena *arena[K, T]
 }
 
 // Empty creates and returns an empty tree. The empty tree has a nil pointer
@@ -44,6 +74,57 @@ func Empty[K comparable, T any]() *Tree[K, T] {
 	}
 }
 
+// withConfig creates and returns an empty tree with the configuration c.
+func withConfig[K comparable, T any](c treeConfig) *Tree[K, T] {
+	t := Empty[K, T]()
+	t.config = c
+	if c.arena > 0 && c.factory == nil {
+		t.arena = newArena(t, c.arena)
+		t.primary = newSlotIndex(t.arena)
+	}
+	return t
+}
+
+// New creates and returns an empty tree configured by the given options. With
+// no options it is the same as Empty.
+//
+// New panics if it is given a NodeFactory for nodes of other key or data types
+// than the tree.
+func New[K comparable, T any](opts ...TreeOption) *Tree[K, T] {
+	var c treeConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.factory != nil {
+		if _, ok := c.factory.(func() Node[K, T]); !ok {
+			panic(fmt.Sprintf("tree: NodeFactory of %T used for a tree of %T", c.factory, &Tree[K, T]{}))
+		}
+	}
+	return withConfig[K, T](c)
+}
+
+// newNode creates a node of the tree, in its arena if it has the Arena
+// option, or with the NodeFactory of the tree if it has one.
+func (t *Tree[K, T]) newNode(nodeID K, parentID K, noParent bool, data T) Node[K, T] {
+	if t.arena != nil {
+		return t.arena.alloc(nodeID, parentID, noParent, data)
+	}
+
+	var n Node[K, T]
+	if f, ok := t.config.factory.(func() Node[K, T]); ok {
+		n = f()
+	} else {
+		n = &BaseNode[K, T]{}
+	}
+
+	b := n.base()
+	*b = BaseNode[K, T]{primary: nodeID, parentID: parentID, noParent: noParent, data: data, tree: t}
+	if Node[K, T](b) != n {
+		b.self = n
+	}
+	return n
+}
+
 // Root returns the root node of a tree. If the tree has no nodes, this
 // function returns nil.
 func (t *Tree[K, T]) Root() Node[K, T] {
@@ -62,7 +143,8 @@ func (t *Tree[K, T]) Root() Node[K, T] {
 // false. If the element's primary key already exists in the index, then
 // added will be false and exists will be true.
 // If the element is added as expected, then added will be true and exists
-// will be false.
+// will be false. Insertion also fails, with added and exists both false, if
+// the element's data violates a unique secondary index of the tree.
 //
 // If the element to be added has a primary key that matches the parent key
 // of the root node, the tree will be re-rooted by adding this element as the
@@ -70,11 +152,37 @@ func (t *Tree[K, T]) Root() Node[K, T] {
 // parent of the existing root is the new node and the parent of the new node
 // exists elsewhere in the tree, the element will fail to add.
 //
-// Do not set a primaryID to zero, as this value should be reserved for the
-// case where a node has no parent.
+// The first element added to an empty tree becomes its root and keeps
+// parentID as its parent key, even if it is the zero value; a node later
+// added with that key re-roots the tree. To use the zero value as a key, add
+// the root with AddRoot instead, so that it has no parent key.
 func (t *Tree[K, T]) Add(nodeID K, parentID K, data T) (added bool, exists bool) {
+	defer t.check("Add")
+	return t.add(t.newNode(nodeID, parentID, false, data))
+}
 
-	child := &node[K, T]{primary: nodeID, parentID: parentID, data: data}
+// AddRoot inserts an element into a tree as a root node with no parent key.
+// If the tree is empty, the element becomes its root. Otherwise, the element
+// re-roots the tree if its primary key matches the parent key of the root, as
+// in Add, and fails to add if not. The return values are the same as for Add.
+//
+// A root with no parent key is serialized with no parent, so that every value
+// of K, including the zero value, can be used as a key of its tree.
+func (t *Tree[K, T]) AddRoot(nodeID K, data T) (added bool, exists bool) {
+	defer t.check("AddRoot")
+	var zero K
+	return t.add(t.newNode(nodeID, zero, true, data))
+}
+
+func (t *Tree[K, T]) add(child Node[K, T]) (added bool, exists bool) {
+	nodeID, data := child.GetID(), child.GetData()
+	if an, ok := child.(*arenaNode[K, T]); ok {
+		defer func() {
+			if !added {
+				t.arena.discard(an)
+			}
+		}()
+	}
 
 	// Return false if this element has already been added
 	if t.primary.find(nodeID) != nil {
@@ -82,29 +190,39 @@ func (t *Tree[K, T]) Add(nodeID K, parentID K, data T) (added bool, exists bool)
 		return
 	}
 
+	if t.checkIndexes(child, data) != nil {
+		return
+	}
+
 	if t.root == nil { // always insert the first element
 		t.root = child
 	} else {
 
-		parent := t.primary.find(parentID)
+		var parent Node[K, T]
+		if child.HasParentID() {
+			parent = t.primary.find(child.GetParentID())
+		}
+		parentOfRoot := t.root.HasParentID() && t.root.GetParentID() == nodeID
+
 		if parent == nil {
-			if t.root.GetParentID() == nodeID { // parent does not exist but incoming node is parent of root
+			if parentOfRoot { // parent does not exist but incoming node is parent of root
 				t.reroot(child)
 			} else { // parent does not exist, do not add
 				return
 			}
 		} else {
-			if t.root.GetParentID() == nodeID { // parent exists, but incoming node causes cycle
+			if parentOfRoot { // parent exists, but incoming node causes cycle
 				return
 			}
 			// parent exists, add
 			child.setParent(parent)
-			parent.AddChildren(child)
+			parent.appendChild(child)
 		}
 	}
 
-	// add to primary index
+	// add to primary and secondary indexes
 	t.primary.insert(nodeID, child)
+	t.indexData(child, data)
 
 	added = true
 	return
@@ -112,7 +230,7 @@ func (t *Tree[K, T]) Add(nodeID K, parentID K, data T) (added bool, exists bool)
 
 func (t *Tree[K, T]) reroot(newHead Node[K, T]) {
 	t.root.setParent(newHead)
-	newHead.AddChildren(t.root)
+	newHead.appendChild(t.root)
 	t.root = newHead
 }
 
@@ -124,13 +242,22 @@ func (t *Tree[K, T]) reroot(newHead Node[K, T]) {
 //
 // If the merge is successful, returns true, otherwise return false. The merge can
 // fail if there are duplicate primary keys between the two trees. The merge
-// can also fail if the parent of the head of the other tree is not found in the
-// target tree.
+// can also fail if the other tree is empty, if its head has no parent key or
+// its parent is not found in the target tree, or if the data of the other tree
+// violates a unique secondary index of the target tree. The secondary indexes
+// of the other tree are not carried over to the target tree.
+//
+// The nodes of the other tree are not copied; after a successful merge they
+// are shared between both trees, and further changes made through either tree
+// will be visible in the other. Merge a Clone of the other tree if the two
+// trees must remain independent. If either tree has the Arena option, the
+// nodes are copied instead.
 func (t *Tree[K, T]) Merge(other *Tree[K, T]) bool {
 
-	if other == nil {
+	if other == nil || other.root == nil || !other.root.HasParentID() {
 		return false
 	}
+	defer t.check("Merge")
 
 	headParent := other.root.GetParentID()
 
@@ -138,19 +265,49 @@ func (t *Tree[K, T]) Merge(other *Tree[K, T]) bool {
 	if f != nil {
 
 		// check for duplicate primary ids
-		for k := range *other.primary {
-			if t.primary.find(k) != nil {
+		duplicate := false
+		other.primary.each(func(k K, _ Node[K, T]) bool {
+			duplicate = t.primary.find(k) != nil
+			return !duplicate
+		})
+		if duplicate {
+			return false
+		}
+
+		// check for duplicate keys in unique secondary indexes, both against
+		// the target tree and within the other tree
+		for _, idx := range t.secondary {
+			if !idx.unique {
+				continue
+			}
+			seen := map[any]bool{}
+			conflict := false
+			other.primary.each(func(_ K, n Node[K, T]) bool {
+				key := idx.keyFn(n.GetData())
+				conflict = key != nil && (seen[key] || idx.conflicts(key, n))
+				seen[key] = true
+				return !conflict
+			})
+			if conflict {
 				return false
 			}
 		}
 
-		f.AddChildren(other.root)
+		if t.arena != nil || other.arena != nil {
+			t.graft(other.root, f, nil)
+			return true
+		}
+
+		f.appendChild(other.root)
 		other.root.setParent(f)
 
 		// copy other index to new tree
-		for k, n := range *other.primary {
-			t.primary.insert(k, n)
-		}
+		breadthFirst(func(n Node[K, T]) bool {
+			t.primary.insert(n.GetID(), n)
+			t.indexData(n, n.GetData())
+			setTree(n, t)
+			return true
+		}, other.root)
 		return true
 	}
 
@@ -158,6 +315,112 @@ func (t *Tree[K, T]) Merge(other *Tree[K, T]) bool {
 
 }
 
+// Remove detaches a node, identified by its primary key, together with all of
+// its descendants from the tree. The removed nodes are taken out of the
+// primary and secondary indexes of the tree and returned as a new tree whose
+// root is the removed node. The returned tree has no secondary indexes.
+//
+// The root of the returned tree keeps the primary key of its former parent,
+// so that merging the returned tree back into this one with Merge restores it
+// as the last child of that parent.
+//
+// If the node is not found, then ok is false and a nil tree is returned. If
+// the node is the root of the tree, this tree becomes empty.
+func (t *Tree[K, T]) Remove(id K) (removed *Tree[K, T], ok bool) {
+	f := t.primary.find(id)
+	if f == nil {
+		return
+	}
+	defer func() {
+		t.check("Remove")
+		removed.check("Remove")
+	}()
+
+	if parent := f.GetParent(); parent != nil {
+		siblings := parent.GetChildren()
+		i := childPosition(siblings, id)
+		parent.setChildren(append(siblings[:i:i], siblings[i+1:]...))
+		f.detach()
+	} else {
+		t.root = nil
+	}
+
+	removed = withConfig[K, T](t.config)
+	if t.arena == nil {
+		removed.root = f
+	} else {
+		removed.graft(f, nil, nil)
+	}
+
+	var slots []int32
+	breadthFirst(func(n Node[K, T]) bool {
+		t.primary.remove(n.GetID())
+		t.unindexData(n, n.GetData())
+		if t.arena == nil {
+			removed.primary.insert(n.GetID(), n)
+			setTree(n, removed)
+		} else {
+			slots = append(slots, t.arena.own(n))
+		}
+		return true
+	}, f)
+
+	if t.arena != nil {
+		for _, i := range slots {
+			t.arena.kill(i)
+		}
+		if t.root == nil {
+			// nothing is left in the arena; start a new one
+			t.arena = newArena(t, t.config.arena)
+			t.primary = newSlotIndex(t.arena)
+		}
+	}
+
+	return removed, true
+}
+
+// graft copies the subtree below n into the tree, as the last child of parent
+// or as the root if parent is nil, and indexes the copies. The data of each
+// copy is the result of copyData, or the data of the original if copyData is
+// nil.
+func (t *Tree[K, T]) graft(n, parent Node[K, T], copyData func(T) T) {
+	copyNode := func(n Node[K, T]) Node[K, T] {
+		data := n.GetData()
+		if copyData != nil {
+			data = copyData(data)
+		}
+		cp := t.newNode(n.GetID(), n.GetParentID(), !n.HasParentID(), data)
+		t.primary.insert(cp.GetID(), cp)
+		t.indexData(cp, data)
+		return cp
+	}
+
+	root := copyNode(n)
+	if parent == nil {
+		t.root = root
+	} else {
+		root.setParent(parent)
+		parent.appendChild(root)
+	}
+
+	// walk both trees in step; each original node is paired with its copy
+	type pair struct {
+		orig Node[K, T]
+		cp   Node[K, T]
+	}
+	var q queue.FIFO[pair]
+	q.Push(pair{n, root})
+	for p, ok := q.Pop(); ok; p, ok = q.Pop() {
+		p.orig.eachChild(func(c Node[K, T]) bool {
+			cc := copyNode(c)
+			cc.setParent(p.cp)
+			p.cp.appendChild(cc)
+			q.Push(pair{c, cc})
+			return true
+		})
+	}
+}
+
 // Find looks up a node by its primary key. If the node is found, then
 // ok is true and a Node is returned. If the node is not found, then
 // ok is false an a nil pointer is returned.
@@ -196,7 +459,44 @@ type serialNode[K comparable, T any] struct {
 	// translates the important fields of a node for serialization
 	Primary  K
 	ParentID K
-	Data     T
+	// SiblingIndex is the position of the node among its parent's children
+	SiblingIndex int
+	Data         T
+	// noParent is set for a root with no parent key, whose ParentID is then
+	// encoded as null
+	noParent bool
+}
+
+// serialJSON is the JSON encoding of a serialNode.
+type serialJSON[K comparable, T any] struct {
+	Primary      K
+	ParentID     *K
+	SiblingIndex int
+	Data         T
+}
+
+func (n serialNode[K, T]) MarshalJSON() ([]byte, error) {
+	s := serialJSON[K, T]{Primary: n.Primary, SiblingIndex: n.SiblingIndex, Data: n.Data}
+	if !n.noParent {
+		s.ParentID = &n.ParentID
+	}
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON decodes a node, which has no parent key if its ParentID is
+// null or missing.
+func (n *serialNode[K, T]) UnmarshalJSON(b []byte) error {
+	var s serialJSON[K, T]
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*n = serialNode[K, T]{Primary: s.Primary, SiblingIndex: s.SiblingIndex, Data: s.Data}
+	if s.ParentID == nil {
+		n.noParent = true
+	} else {
+		n.ParentID = *s.ParentID
+	}
+	return nil
 }
 
 // Serialize encodes the tree as a byte stream.
@@ -205,7 +505,9 @@ type serialNode[K comparable, T any] struct {
 // the tree is serialized. TraversalType does not matter for deserialization;
 // the internal metadata of the nodes will create the shape of the tree when
 // it is deserialized, not the order in which the nodes are serialized
-// to storage.
+// to storage. The position of each node among its siblings is recorded, so
+// that the order of children is also restored. A root with no parent key,
+// added with AddRoot, is serialized with a null parent key.
 //
 // The associated data of each node is serialized with it. This data may be
 // set the the caller and may not be serializable. If the associated data
@@ -222,11 +524,28 @@ func (t *Tree[K, T]) Serialize(trvsl TraversalType) (io.ReadCloser, <-chan error
 
 	go func() {
 		encoder := json.NewEncoder(writer)
+
+		// a node is visited before its children, so the positions of the
+		// children are recorded when their parent is visited, whatever the
+		// order of the traversal
+		positions := map[K]int{}
+
 		for n := range t.Traverse(trvsl) {
+			idx := positions[n.GetID()]
+			delete(positions, n.GetID())
+			i := 0
+			n.eachChild(func(c Node[K, T]) bool {
+				positions[c.GetID()] = i
+				i++
+				return true
+			})
+
 			err := encoder.Encode(serialNode[K, T]{
-				Primary:  n.GetID(),
-				ParentID: n.GetParentID(),
-				Data:     n.GetData(),
+				Primary:      n.GetID(),
+				ParentID:     n.GetParentID(),
+				SiblingIndex: idx,
+				Data:         n.GetData(),
+				noParent:     !n.HasParentID(),
 			})
 			if err != nil {
 				errchan <- err
@@ -251,10 +570,14 @@ func (t *Tree[K, T]) Serialize(trvsl TraversalType) (io.ReadCloser, <-chan error
 //
 // The argument ReadCloser is a stream with data from a serialized tree. If any
 // node of the tree fails to deserialize, this function will abord and return an
-// error.
-func Deserialize[K comparable, T any](stream io.ReadCloser) (*Tree[K, T], error) {
+// error. The tree is created by New with the given options.
+//
+// The nodes are decoded one at a time; DeserializeParallel decodes large
+// streams on several goroutines.
+func Deserialize[K comparable, T any](stream io.ReadCloser, opts ...TreeOption) (*Tree[K, T], error) {
 	decoder := json.NewDecoder(stream)
-	t := Empty[K, T]()
+	t := New[K, T](opts...)
+	positions := map[K]int{}
 
 	for {
 
@@ -262,15 +585,39 @@ func Deserialize[K comparable, T any](stream io.ReadCloser) (*Tree[K, T], error)
 
 		err := decoder.Decode(&n)
 		if err == io.EOF {
-			return t, nil
+			break
 		}
 
 		if err != nil {
 			return nil, fmt.Errorf("error deserializing: %w", err)
 		}
 
-		t.Add(n.Primary, n.ParentID, n.Data)
+		var added bool
+		if n.noParent {
+			added, _ = t.AddRoot(n.Primary, n.Data)
+		} else {
+			added, _ = t.Add(n.Primary, n.ParentID, n.Data)
+		}
+		if added {
+			positions[n.Primary] = n.SiblingIndex
+		}
 
 	}
 
+	t.restoreOrder(positions)
+
+	return t, nil
+
+}
+
+// restoreOrder sorts the children of every node of a tree built from
+// serialized nodes, which were linked in the order they were read, into the
+// serialized order, given by the sibling index of each node in positions.
+func (t *Tree[K, T]) restoreOrder(positions map[K]int) {
+	if t.root == nil {
+		return
+	}
+	t.SortChildren(t.root.GetID(), func(a, b Node[K, T]) bool {
+		return positions[a.GetID()] < positions[b.GetID()]
+	}, true)
 }
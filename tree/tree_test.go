@@ -3,6 +3,7 @@ package tree
 import (
 	"encoding/json"
 	"io"
+	"strings"
 	"sync"
 	"testing"
 
@@ -11,7 +12,7 @@ import (
 
 func TestRoot(t *testing.T) {
 
-	node1 := &node[uint, int]{primary: 1}
+	node1 := &BaseNode[uint, int]{primary: 1}
 
 	var tests = map[string]struct {
 		tree *Tree[uint, int]
@@ -52,7 +53,7 @@ func TestAdd(t *testing.T) {
 	}{
 		"primary exists": {
 			prep: func() *Tree[uint, int] {
-				n := &node[uint, int]{primary: 1}
+				n := &BaseNode[uint, int]{primary: 1}
 				return &Tree[uint, int]{root: n, primary: &index[uint, int]{1: n}}
 			},
 			add:       addInput{1, 0},
@@ -69,7 +70,7 @@ func TestAdd(t *testing.T) {
 		},
 		"re-root": {
 			prep: func() *Tree[uint, int] {
-				n := &node[uint, int]{primary: 1, parentID: 2}
+				n := &BaseNode[uint, int]{primary: 1, parentID: 2}
 				return &Tree[uint, int]{root: n, primary: &index[uint, int]{1: n}}
 			},
 			add:       addInput{2, 3},
@@ -78,7 +79,7 @@ func TestAdd(t *testing.T) {
 		},
 		"re-root with cycle": {
 			prep: func() *Tree[uint, int] {
-				n := &node[uint, int]{primary: 1, parentID: 2}
+				n := &BaseNode[uint, int]{primary: 1, parentID: 2}
 				return &Tree[uint, int]{root: n, primary: &index[uint, int]{1: n}}
 			},
 			add:       addInput{2, 1},
@@ -87,7 +88,7 @@ func TestAdd(t *testing.T) {
 		},
 		"parent does not exist": {
 			prep: func() *Tree[uint, int] {
-				n := &node[uint, int]{primary: 1}
+				n := &BaseNode[uint, int]{primary: 1}
 				return &Tree[uint, int]{root: n, primary: &index[uint, int]{1: n}}
 			},
 			add:       addInput{2, 3},
@@ -96,7 +97,7 @@ func TestAdd(t *testing.T) {
 		},
 		"added": {
 			prep: func() *Tree[uint, int] {
-				n := &node[uint, int]{primary: 1}
+				n := &BaseNode[uint, int]{primary: 1}
 				return &Tree[uint, int]{root: n, primary: &index[uint, int]{1: n}}
 			},
 			add:       addInput{2, 1},
@@ -196,7 +197,7 @@ func TestFind(t *testing.T) {
 	}{
 		"primary does not exist": {
 			prep: func() *Tree[uint, string] {
-				n := &node[uint, string]{primary: 1}
+				n := &BaseNode[uint, string]{primary: 1}
 				t := &Tree[uint, string]{root: n, primary: &index[uint, string]{1: n}}
 				t.Add(2, 1, "")
 				return t
@@ -207,7 +208,7 @@ func TestFind(t *testing.T) {
 		},
 		"primary exists - branch end": {
 			prep: func() *Tree[uint, string] {
-				n := &node[uint, string]{primary: 1}
+				n := &BaseNode[uint, string]{primary: 1}
 				t := &Tree[uint, string]{root: n, primary: &index[uint, string]{1: n}}
 				t.Add(2, 1, "")
 				t.Add(3, 2, "")
@@ -220,7 +221,7 @@ func TestFind(t *testing.T) {
 		},
 		"primary exists - mid tree": {
 			prep: func() *Tree[uint, string] {
-				n := &node[uint, string]{primary: 1}
+				n := &BaseNode[uint, string]{primary: 1}
 				t := &Tree[uint, string]{root: n, primary: &index[uint, string]{1: n}}
 				t.Add(2, 1, "")
 				t.Add(3, 2, "")
@@ -233,7 +234,7 @@ func TestFind(t *testing.T) {
 		},
 		"primary exists - root": {
 			prep: func() *Tree[uint, string] {
-				n := &node[uint, string]{primary: 1}
+				n := &BaseNode[uint, string]{primary: 1}
 				t := &Tree[uint, string]{root: n, primary: &index[uint, string]{1: n}}
 				t.Add(2, 1, "")
 				t.Add(3, 2, "")
@@ -272,7 +273,7 @@ func TestFindParents(t *testing.T) {
 	}{
 		"primary does not exist": {
 			prep: func() *Tree[uint, string] {
-				n := &node[uint, string]{primary: 1}
+				n := &BaseNode[uint, string]{primary: 1}
 				t := &Tree[uint, string]{root: n, primary: &index[uint, string]{1: n}}
 				t.Add(2, 1, "")
 				return t
@@ -283,7 +284,7 @@ func TestFindParents(t *testing.T) {
 		},
 		"primary exists - branch end": {
 			prep: func() *Tree[uint, string] {
-				n := &node[uint, string]{primary: 1}
+				n := &BaseNode[uint, string]{primary: 1}
 				t := &Tree[uint, string]{root: n, primary: &index[uint, string]{1: n}}
 				t.Add(2, 1, "")
 				t.Add(3, 2, "")
@@ -296,7 +297,7 @@ func TestFindParents(t *testing.T) {
 		},
 		"primary exists - mid tree": {
 			prep: func() *Tree[uint, string] {
-				n := &node[uint, string]{primary: 1}
+				n := &BaseNode[uint, string]{primary: 1}
 				t := &Tree[uint, string]{root: n, primary: &index[uint, string]{1: n}}
 				t.Add(2, 1, "")
 				t.Add(3, 2, "")
@@ -309,7 +310,7 @@ func TestFindParents(t *testing.T) {
 		},
 		"primary exists - root": {
 			prep: func() *Tree[uint, string] {
-				n := &node[uint, string]{primary: 1}
+				n := &BaseNode[uint, string]{primary: 1}
 				t := &Tree[uint, string]{root: n, primary: &index[uint, string]{1: n}}
 				t.Add(2, 1, "")
 				t.Add(3, 2, "")
@@ -351,13 +352,13 @@ func TestMerge(t *testing.T) {
 	}{
 		"other parent not in tree": {
 			prepRoot: func() *Tree[uint, string] {
-				n := &node[uint, string]{primary: 1}
+				n := &BaseNode[uint, string]{primary: 1}
 				t := &Tree[uint, string]{root: n, primary: &index[uint, string]{1: n}}
 				t.Add(2, 1, "")
 				return t
 			},
 			prepOther: func() *Tree[uint, string] {
-				n := &node[uint, string]{primary: 3}
+				n := &BaseNode[uint, string]{primary: 3}
 				t := &Tree[uint, string]{root: n, primary: &index[uint, string]{3: n}}
 				t.Add(4, 3, "")
 				return t
@@ -368,7 +369,7 @@ func TestMerge(t *testing.T) {
 		},
 		"dulicate keys": {
 			prepRoot: func() *Tree[uint, string] {
-				n := &node[uint, string]{primary: 1}
+				n := &BaseNode[uint, string]{primary: 1}
 				t := &Tree[uint, string]{root: n, primary: &index[uint, string]{1: n}}
 				t.Add(2, 1, "")
 				return t
@@ -385,7 +386,7 @@ func TestMerge(t *testing.T) {
 		},
 		"merged - branch end": {
 			prepRoot: func() *Tree[uint, string] {
-				n := &node[uint, string]{primary: 1}
+				n := &BaseNode[uint, string]{primary: 1}
 				t := &Tree[uint, string]{root: n, primary: &index[uint, string]{1: n}}
 				t.Add(2, 1, "")
 				t.Add(3, 2, "")
@@ -405,7 +406,7 @@ func TestMerge(t *testing.T) {
 		},
 		"merged - mid tree": {
 			prepRoot: func() *Tree[uint, string] {
-				n := &node[uint, string]{primary: 1}
+				n := &BaseNode[uint, string]{primary: 1}
 				t := &Tree[uint, string]{root: n, primary: &index[uint, string]{1: n}}
 				t.Add(2, 1, "")
 				t.Add(3, 2, "")
@@ -706,3 +707,286 @@ func TestDeserializeStruct(t *testing.T) {
 		})
 	}
 }
+
+func TestDeserializeOrder(t *testing.T) {
+
+	var tests = map[string]struct {
+		prep   func() *Tree[uint, int]
+		stream string
+		expBFC []uint
+		expDFC []uint
+	}{
+		"round trip": {
+			prep: func() *Tree[uint, int] {
+				t := Empty[uint, int]()
+				t.Add(1, 0, 0)
+				t.Add(2, 1, 0)
+				t.Add(3, 1, 0)
+				t.InsertAt(4, 1, 0, 0)
+				t.Add(5, 3, 0)
+				t.InsertAt(6, 3, 0, 0)
+				return t
+			},
+			expBFC: []uint{1, 4, 2, 3, 6, 5},
+			expDFC: []uint{1, 4, 2, 3, 6, 5},
+		},
+		"siblings out of order": {
+			stream: `{"Primary":1,"ParentID":0,"SiblingIndex":0,"Data":0}
+{"Primary":3,"ParentID":1,"SiblingIndex":2,"Data":0}
+{"Primary":2,"ParentID":1,"SiblingIndex":1,"Data":0}
+{"Primary":5,"ParentID":3,"SiblingIndex":0,"Data":0}
+{"Primary":4,"ParentID":1,"SiblingIndex":0,"Data":0}
+`,
+			expBFC: []uint{1, 4, 2, 3, 5},
+			expDFC: []uint{1, 4, 2, 3, 5},
+		},
+		"no sibling index": {
+			stream: `{"Primary":1,"ParentID":0,"Data":0}
+{"Primary":3,"ParentID":1,"Data":0}
+{"Primary":2,"ParentID":1,"Data":0}
+`,
+			expBFC: []uint{1, 3, 2},
+			expDFC: []uint{1, 3, 2},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+
+			var rdr io.ReadCloser
+			if tt.prep != nil {
+				rdr, _ = tt.prep().Serialize(TraverseBreadthFirst)
+			} else {
+				rdr = io.NopCloser(strings.NewReader(tt.stream))
+			}
+
+			gotTree, gotErr := Deserialize[uint, int](rdr)
+
+			if assert.NoError(t, gotErr) {
+				assert.Equal(t, tt.expBFC, bfc([]Node[uint, int]{gotTree.root}, []uint{}))
+				assert.Equal(t, tt.expDFC, dfc(gotTree.root, []uint{}))
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+
+	var tests = map[string]struct {
+		argID      uint
+		expOK      bool
+		expBFC     []uint
+		expRemoved []uint
+	}{
+		"does not exist": {
+			argID:  9,
+			expBFC: []uint{1, 2, 5, 3, 4},
+		},
+		"leaf": {
+			argID:      5,
+			expOK:      true,
+			expBFC:     []uint{1, 2, 3, 4},
+			expRemoved: []uint{5},
+		},
+		"subtree": {
+			argID:      2,
+			expOK:      true,
+			expBFC:     []uint{1, 5},
+			expRemoved: []uint{2, 3, 4},
+		},
+		"root": {
+			argID:      1,
+			expOK:      true,
+			expBFC:     []uint{},
+			expRemoved: []uint{1, 2, 5, 3, 4},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tree := Empty[uint, string]()
+			tree.Add(1, 0, "")
+			tree.Add(2, 1, "")
+			tree.Add(3, 2, "")
+			tree.Add(4, 2, "")
+			tree.Add(5, 1, "")
+
+			gotRemoved, gotOK := tree.Remove(tt.argID)
+
+			assert.Equal(t, tt.expOK, gotOK)
+			assert.Equal(t, tt.expBFC, bfc([]Node[uint, string]{tree.root}, []uint{}))
+			assert.Equal(t, len(tt.expBFC), tree.primary.len())
+
+			if !tt.expOK {
+				assert.Nil(t, gotRemoved)
+				return
+			}
+
+			assert.Equal(t, tt.expRemoved, bfc([]Node[uint, string]{gotRemoved.root}, []uint{}))
+			assert.Nil(t, gotRemoved.root.GetParent())
+			for _, key := range tt.expRemoved {
+				_, found := tree.Find(key)
+				assert.False(t, found)
+				_, found = gotRemoved.Find(key)
+				assert.True(t, found)
+			}
+
+			// a removed subtree can be merged back in
+			if tt.argID != 1 {
+				assert.True(t, tree.Merge(gotRemoved))
+				assert.Equal(t, 5, tree.primary.len())
+			}
+		})
+	}
+}
+
+func TestAddRoot(t *testing.T) {
+
+	var tests = map[string]struct {
+		prep      func() *Tree[uint, int]
+		nodeID    uint
+		expAdded  bool
+		expExists bool
+		expBFC    []uint
+	}{
+		"empty tree": {
+			prep:     Empty[uint, int],
+			nodeID:   1,
+			expAdded: true,
+			expBFC:   []uint{1},
+		},
+		"zero key": {
+			prep:     Empty[uint, int],
+			nodeID:   0,
+			expAdded: true,
+			expBFC:   []uint{0},
+		},
+		"parent of root": {
+			prep: func() *Tree[uint, int] {
+				t := Empty[uint, int]()
+				t.Add(1, 5, 0)
+				t.Add(2, 1, 0)
+				return t
+			},
+			nodeID:   5,
+			expAdded: true,
+			expBFC:   []uint{5, 1, 2},
+		},
+		"not parent of root": {
+			prep: func() *Tree[uint, int] {
+				t := Empty[uint, int]()
+				t.Add(1, 5, 0)
+				return t
+			},
+			nodeID: 6,
+			expBFC: []uint{1},
+		},
+		"root has no parent": {
+			prep: func() *Tree[uint, int] {
+				t := Empty[uint, int]()
+				t.AddRoot(1, 0)
+				return t
+			},
+			nodeID: 0,
+			expBFC: []uint{1},
+		},
+		"primary exists": {
+			prep: func() *Tree[uint, int] {
+				t := Empty[uint, int]()
+				t.AddRoot(1, 0)
+				return t
+			},
+			nodeID:    1,
+			expExists: true,
+			expBFC:    []uint{1},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tree := tt.prep()
+			gotAdded, gotExists := tree.AddRoot(tt.nodeID, 0)
+
+			assert.Equal(t, tt.expAdded, gotAdded)
+			assert.Equal(t, tt.expExists, gotExists)
+			assert.Equal(t, tt.expBFC, bfc([]Node[uint, int]{tree.root}, []uint{}))
+			if tt.expAdded {
+				assert.False(t, tree.root.HasParentID())
+				assert.Equal(t, uint(0), tree.root.GetParentID())
+			}
+			assert.Empty(t, tree.Validate())
+		})
+	}
+}
+
+func TestZeroKeys(t *testing.T) {
+
+	// with a root added by Add, a node keyed by the zero value takes the
+	// place of the missing parent of the root
+	tree := Empty[uint, string]()
+	tree.Add(1, 0, "one")
+	tree.Add(0, 7, "zero")
+	assert.Equal(t, uint(0), tree.root.GetID())
+
+	// with a root added by AddRoot, it is a node like any other
+	tree = Empty[uint, string]()
+	tree.AddRoot(1, "one")
+	added, _ := tree.Add(0, 1, "zero")
+	assert.True(t, added)
+	tree.Add(2, 0, "two")
+	assert.Equal(t, []uint{1, 0, 2}, bfc([]Node[uint, string]{tree.root}, []uint{}))
+
+	rdr, errs := tree.Serialize(TraverseBreadthFirst)
+	b, err := io.ReadAll(rdr)
+	assert.NoError(t, err)
+	assert.NoError(t, <-errs)
+	assert.Equal(t, `{"Primary":1,"ParentID":null,"SiblingIndex":0,"Data":"one"}
+{"Primary":0,"ParentID":1,"SiblingIndex":0,"Data":"zero"}
+{"Primary":2,"ParentID":0,"SiblingIndex":0,"Data":"two"}
+`, string(b))
+
+	got, err := Deserialize[uint, string](io.NopCloser(strings.NewReader(string(b))))
+	assert.NoError(t, err)
+	assert.True(t, Equal(tree, got, func(a, b string) bool { return a == b }))
+	assert.False(t, got.root.HasParentID())
+
+	// the root may be read last
+	lines := strings.SplitAfter(string(b), "\n")
+	got, err = Deserialize[uint, string](io.NopCloser(strings.NewReader(lines[2] + lines[1] + lines[0])))
+	assert.NoError(t, err)
+	assert.Empty(t, got.Validate())
+	assert.Equal(t, []uint{1, 0, 2}, bfc([]Node[uint, string]{got.root}, []uint{}))
+
+	// a root with a parent key, as written before roots were marked, keeps it
+	got, err = Deserialize[uint, string](io.NopCloser(strings.NewReader(`{"Primary":1,"ParentID":0}`)))
+	assert.NoError(t, err)
+	assert.True(t, got.root.HasParentID())
+}
+
+func TestZeroKeysLink(t *testing.T) {
+
+	got, err := link([]serialNode[string, int]{
+		{Primary: "a", ParentID: ""},
+		{Primary: "", noParent: true},
+		{Primary: "b", ParentID: "a"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "a", "b"}, bfc([]Node[string, int]{got.root}, []string{}))
+	assert.False(t, got.root.HasParentID())
+	assert.Empty(t, got.Validate())
+
+	// a root that is its own parent has no parent key
+	got, err = link([]serialNode[string, int]{{Primary: "a", ParentID: "a"}})
+	assert.NoError(t, err)
+	assert.False(t, got.root.HasParentID())
+}
+
+func TestMergeNoParent(t *testing.T) {
+
+	tree := Empty[uint, int]()
+	tree.AddRoot(1, 0)
+	other := Empty[uint, int]()
+	other.AddRoot(2, 0)
+	assert.False(t, tree.Merge(other))
+	assert.False(t, tree.Merge(Empty[uint, int]()))
+}
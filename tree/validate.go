@@ -0,0 +1,241 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidTree is the error a tree created with the Debug option panics
+// with, wrapped with the violations found, when a mutation leaves it invalid.
+var ErrInvalidTree = errors.New("tree: invariant violated")
+
+// TreeOption configures a tree when it is created with New.
+type TreeOption func(*treeConfig)
+
+type treeConfig struct {
+	debug bool
+	// factory is the func() Node[K, T] given to NodeFactory, if any
+	factory any
+	// arena is the initial capacity of the arena of a tree with the Arena
+	// option, or zero
+	arena int
+}
+
+// Debug makes the tree run Validate after every method that changes it, and
+// after Node.SetData on any of its nodes, and panic with ErrInvalidTree if a
+// violation is found. The trees returned by Remove and Clone inherit this
+// option.
+//
+// Validation visits every node of the tree, so this option is meant for
+// tests, to catch a corruption at the change that causes it.
+func Debug() TreeOption {
+	return func(c *treeConfig) {
+		c.debug = true
+	}
+}
+
+// ViolationKind identifies the invariant broken by a Violation.
+type ViolationKind int
+
+const (
+	// Unreachable is a node in the primary index that cannot be reached from
+	// the root.
+	Unreachable ViolationKind = iota + 1
+	// Unindexed is a node reachable from the root that the primary index does
+	// not hold under its key.
+	Unindexed
+	// ParentMismatch is a node whose parent pointer is not the node holding
+	// it as a child, or a root with a parent.
+	ParentMismatch
+	// ParentIDMismatch is a node whose parent key is not the key of its
+	// parent.
+	ParentIDMismatch
+	// Cycle is a node reached more than once from the root, because it is its
+	// own ancestor or is a child of more than one node.
+	Cycle
+	// IndexMismatch is an entry of an index that does not match the node it
+	// holds: a node under a key other than its own in the primary index, or
+	// under a key other than the key of its data in a secondary index.
+	IndexMismatch
+)
+
+func (k ViolationKind) String() string {
+	switch k {
+	case Unreachable:
+		return "unreachable"
+	case Unindexed:
+		return "unindexed"
+	case ParentMismatch:
+		return "parent mismatch"
+	case ParentIDMismatch:
+		return "parent id mismatch"
+	case Cycle:
+		return "cycle"
+	case IndexMismatch:
+		return "index mismatch"
+	}
+	return fmt.Sprintf("ViolationKind(%d)", int(k))
+}
+
+// Violation is a broken invariant of a tree, found by Validate.
+type Violation struct {
+	Kind ViolationKind
+	// ID is the primary key of the node breaking the invariant.
+	ID any
+	// Detail describes the violation.
+	Detail string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%v: node %v %s", v.Kind, v.ID, v.Detail)
+}
+
+// Validate checks the invariants of a tree, returning the violations found or
+// nil if there are none. The methods of Tree keep these invariants, but they
+// can be broken through Node.AddChildren and Node.ReplaceChildren. The
+// invariants are that
+//   - every node in the primary index is reachable from the root, and is
+//     indexed under its own key
+//   - every node reachable from the root is in the primary index
+//   - the parent pointer of every node is the node holding it as a child, and
+//     the root has no parent
+//   - the parent key of every node but the root is the key of its parent
+//   - no node is reached more than once from the root
+//   - every node is in each secondary index under the key of its data, and no
+//     other node is
+//
+// Violations found while walking the tree from the root come first, in depth
+// first order; those found going through the indexes follow, ordered by key.
+func (t *Tree[K, T]) Validate() []Violation {
+	var vs []Violation
+	report := func(kind ViolationKind, id K, format string, args ...any) {
+		vs = append(vs, Violation{Kind: kind, ID: id, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	reached := map[Node[K, T]]bool{}
+	if t.root != nil {
+		if p := t.root.GetParent(); p != nil {
+			report(ParentMismatch, t.root.GetID(), "is the root but has parent %v", p.GetID())
+		}
+		reached[t.root] = true
+
+		stack := []Node[K, T]{t.root}
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			id := n.GetID()
+			if f := t.primary.find(id); f == nil {
+				report(Unindexed, id, "is missing from the primary index")
+			} else if f != n {
+				report(Unindexed, id, "is a different node from the one indexed under its key")
+			}
+
+			children := n.GetChildren()
+			for i := len(children) - 1; i >= 0; i-- {
+				c := children[i]
+				if reached[c] {
+					report(Cycle, c.GetID(), "is reached again as a child of %v", id)
+					continue
+				}
+				reached[c] = true
+				if p := c.GetParent(); p != n {
+					report(ParentMismatch, c.GetID(), "is a child of %v but its parent is %s", id, describeNode(p))
+				}
+				if !c.HasParentID() {
+					report(ParentIDMismatch, c.GetID(), "is a child of %v but has no parent key", id)
+				} else if c.GetParentID() != id {
+					report(ParentIDMismatch, c.GetID(), "is a child of %v but has parent key %v", id, c.GetParentID())
+				}
+				stack = append(stack, c)
+			}
+		}
+	}
+
+	// the rest of the checks go through maps, so sort their violations
+	var rest []Violation
+	restReport := func(kind ViolationKind, id K, format string, args ...any) {
+		rest = append(rest, Violation{Kind: kind, ID: id, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	t.primary.each(func(k K, n Node[K, T]) bool {
+		if n.GetID() != k {
+			restReport(IndexMismatch, n.GetID(), "is in the primary index under key %v", k)
+		}
+		if !reached[n] {
+			restReport(Unreachable, n.GetID(), "is indexed but not reachable from the root")
+		}
+		return true
+	})
+
+	for name, idx := range t.secondary {
+		for key, nodes := range idx.keys {
+			for _, n := range nodes {
+				if !reached[n] {
+					restReport(IndexMismatch, n.GetID(), "is in secondary index %q but not reachable from the root", name)
+				} else if k := idx.keyFn(n.GetData()); k != key {
+					restReport(IndexMismatch, n.GetID(), "is in secondary index %q under key %v instead of %v", name, key, k)
+				}
+			}
+			if idx.unique && len(nodes) > 1 {
+				restReport(IndexMismatch, nodes[1].GetID(), "shares key %v of unique secondary index %q", key, name)
+			}
+		}
+		for n := range reached {
+			if !idx.holds(n) {
+				restReport(IndexMismatch, n.GetID(), "is missing from secondary index %q", name)
+			}
+		}
+	}
+
+	sort.SliceStable(rest, func(i, j int) bool {
+		a, b := fmt.Sprint(rest[i].ID), fmt.Sprint(rest[j].ID)
+		if a != b {
+			return a < b
+		}
+		return rest[i].String() < rest[j].String()
+	})
+	return append(vs, rest...)
+}
+
+// holds reports whether n is in the index under the key of its data, or
+// whether that key is nil and so n is rightly left out.
+func (d *dataIndex[K, T]) holds(n Node[K, T]) bool {
+	key := d.keyFn(n.GetData())
+	if key == nil {
+		return true
+	}
+	for _, m := range d.keys[key] {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
+
+// describeNode formats the key of a node that may be nil.
+func describeNode[K comparable, T any](n Node[K, T]) string {
+	if n == nil {
+		return "nil"
+	}
+	return fmt.Sprint(n.GetID())
+}
+
+// check panics with the violations of the tree after the operation op, if the
+// tree was created with the Debug option.
+func (t *Tree[K, T]) check(op string) {
+	if t == nil || !t.config.debug {
+		return
+	}
+	vs := t.Validate()
+	if len(vs) == 0 {
+		return
+	}
+	lines := make([]string, len(vs))
+	for i, v := range vs {
+		lines[i] = v.String()
+	}
+	panic(fmt.Errorf("%w after %s:\n%s", ErrInvalidTree, op, strings.Join(lines, "\n")))
+}
@@ -0,0 +1,192 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+
+	var tests = map[string]struct {
+		corrupt func(tr *Tree[uint, string])
+		exp     []string
+	}{
+		"valid": {
+			corrupt: func(tr *Tree[uint, string]) {},
+		},
+		"foreign child": {
+			corrupt: func(tr *Tree[uint, string]) {
+				tr.primary.find(2).AddChildren(&BaseNode[uint, string]{primary: 9})
+			},
+			exp: []string{
+				"parent mismatch: node 9 is a child of 2 but its parent is nil",
+				"parent id mismatch: node 9 is a child of 2 but has parent key 0",
+				"unindexed: node 9 is missing from the primary index",
+			},
+		},
+		"dropped children": {
+			corrupt: func(tr *Tree[uint, string]) {
+				tr.primary.find(3).ReplaceChildren()
+			},
+			exp: []string{
+				"unreachable: node 5 is indexed but not reachable from the root",
+				"unreachable: node 6 is indexed but not reachable from the root",
+			},
+		},
+		"cycle": {
+			corrupt: func(tr *Tree[uint, string]) {
+				tr.primary.find(5).AddChildren(tr.root)
+			},
+			exp: []string{
+				"cycle: node 1 is reached again as a child of 5",
+			},
+		},
+		"two parents": {
+			corrupt: func(tr *Tree[uint, string]) {
+				tr.primary.find(2).AddChildren(tr.primary.find(5))
+			},
+			exp: []string{
+				"parent mismatch: node 5 is a child of 2 but its parent is 3",
+				"parent id mismatch: node 5 is a child of 2 but has parent key 3",
+				"cycle: node 5 is reached again as a child of 3",
+			},
+		},
+		"root with parent": {
+			corrupt: func(tr *Tree[uint, string]) {
+				tr.root.setParent(tr.primary.find(2))
+			},
+			exp: []string{
+				"parent mismatch: node 1 is the root but has parent 2",
+			},
+		},
+		"missing from primary index": {
+			corrupt: func(tr *Tree[uint, string]) {
+				tr.primary.remove(4)
+			},
+			exp: []string{
+				"unindexed: node 4 is missing from the primary index",
+			},
+		},
+		"wrong primary key": {
+			corrupt: func(tr *Tree[uint, string]) {
+				tr.primary.insert(7, tr.primary.find(6))
+			},
+			exp: []string{
+				"index mismatch: node 6 is in the primary index under key 7",
+			},
+		},
+		"stale secondary index": {
+			corrupt: func(tr *Tree[uint, string]) {
+				tr.AddIndex("data", func(s string) any { return s }, true)
+				tr.primary.find(4).(*BaseNode[uint, string]).data = "z"
+			},
+			exp: []string{
+				`index mismatch: node 4 is in secondary index "data" under key b instead of z`,
+				`index mismatch: node 4 is missing from secondary index "data"`,
+			},
+		},
+		"unique secondary index": {
+			corrupt: func(tr *Tree[uint, string]) {
+				tr.AddIndex("data", func(s string) any { return s }, true)
+				tr.secondary["data"].insert("d", tr.primary.find(2))
+			},
+			exp: []string{
+				`index mismatch: node 2 shares key d of unique secondary index "data"`,
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tree := orderedTree()
+			tt.corrupt(tree)
+
+			var got []string
+			for _, v := range tree.Validate() {
+				got = append(got, v.String())
+			}
+			assert.Equal(t, tt.exp, got)
+		})
+	}
+}
+
+func TestValidateEmpty(t *testing.T) {
+
+	assert.Nil(t, Empty[uint, string]().Validate())
+}
+
+func TestViolation(t *testing.T) {
+
+	v := Violation{Kind: Cycle, ID: uint(3), Detail: "is reached again as a child of 4"}
+	assert.Equal(t, "cycle: node 3 is reached again as a child of 4", v.String())
+	assert.Equal(t, "ViolationKind(0)", ViolationKind(0).String())
+}
+
+// recovered runs fn and returns the value it panics with, if any.
+func recovered(fn func()) (r any) {
+	defer func() {
+		r = recover()
+	}()
+	fn()
+	return nil
+}
+
+func TestDebug(t *testing.T) {
+
+	tree := New[uint, string](Debug())
+	assert.Nil(t, recovered(func() {
+		tree.Add(1, 0, "a")
+		tree.Add(2, 1, "b")
+		tree.Add(3, 1, "c")
+		tree.InsertAt(4, 1, 0, "d")
+		tree.MoveAfter(4, 3)
+		tree.Move(4, 2, 0)
+		tree.ReplaceChildren(2, 4)
+		tree.SortChildren(1, func(a, b Node[uint, string]) bool { return a.GetData() > b.GetData() }, true)
+		assert.NoError(t, tree.AddIndex("data", func(s string) any { return s }, true))
+		tree.SetData(2, "e")
+		tree.primary.find(3).SetData("f")
+
+		removed, _ := tree.Remove(3)
+		assert.True(t, removed.config.debug)
+		assert.True(t, tree.Merge(removed))
+		assert.True(t, tree.Clone(nil).config.debug)
+	}))
+
+	// the corruption is caught by the next change to the tree
+	tree.primary.find(2).AddChildren(tree.primary.find(4))
+	r := recovered(func() {
+		tree.Add(5, 1, "g")
+	})
+	err, ok := r.(error)
+	assert.True(t, ok)
+	assert.True(t, errors.Is(err, ErrInvalidTree))
+	assert.Contains(t, err.Error(), "after Add")
+	assert.Contains(t, err.Error(), "cycle: node 4 is reached again as a child of 2")
+}
+
+func TestDebugSetData(t *testing.T) {
+
+	tree := New[uint, string](Debug())
+	tree.Add(1, 0, "a")
+	tree.Add(2, 1, "b")
+	tree.primary.find(1).ReplaceChildren()
+
+	r := recovered(func() {
+		tree.primary.find(1).SetData("c")
+	})
+	assert.Equal(t, fmt.Sprintf("%v after SetData:\nunreachable: node 2 is indexed but not reachable from the root", ErrInvalidTree), fmt.Sprint(r))
+}
+
+func TestNoDebug(t *testing.T) {
+
+	tree := orderedTree()
+	tree.primary.find(3).ReplaceChildren()
+	assert.Nil(t, recovered(func() {
+		tree.Add(7, 1, "g")
+	}))
+	assert.Len(t, tree.Validate(), 2)
+}